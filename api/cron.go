@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common/ctxdata"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/config"
+	"github.com/frasnym/go-expense-telebot/jobs"
+	"github.com/frasnym/go-expense-telebot/pkg/telebot"
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+// CronHandler handles requests to /cron/<job> and runs the named job if it is due.
+// Adding "force=true" to the query runs the job even if it already ran for its current schedule.
+// The request must carry the configured CRON_SECRET as a bearer token, which is what Vercel cron jobs send.
+// If any errors occur during the process, they are logged and answered with a 500.
+// Otherwise it writes a "Cron OK" message to the response writer (w).
+func CronHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	ctx := ctxdata.EnsureCorrelationIDExist(r)
+
+	cfg := config.GetConfig()
+
+	// Reject callers without the cron secret
+	expected := "Bearer " + cfg.CronSecret
+	if cfg.CronSecret == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+		logger.LogService(ctx, "CronHandler", errors.New("unauthorized cron request"))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// The Vercel route passes the job as query, the standalone server only has the path
+	jobName := r.URL.Query().Get("job")
+	if jobName == "" {
+		jobName = strings.TrimPrefix(r.URL.Path, "/cron/")
+	}
+	force := r.URL.Query().Get("force") == "true"
+
+	// Init repo
+	botRepo := repository.NewBotRepository(cfg, telebot.GetBot())
+	gsheetRepo, expenseStore, err := repository.NewStorage(cfg)
+	if err != nil {
+		err = fmt.Errorf("err repository.NewStorage: %w", err)
+		logger.LogService(ctx, "CronHandler", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	scheduler, err := jobs.NewDefaultScheduler(cfg, botRepo, gsheetRepo, expenseStore)
+	if err != nil {
+		err = fmt.Errorf("err jobs.NewDefaultScheduler: %w", err)
+		logger.LogService(ctx, "CronHandler", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	ran, err := scheduler.Trigger(ctx, jobName, time.Now(), force)
+	if errors.Is(err, jobs.ErrUnknownJob) {
+		logger.LogService(ctx, "CronHandler", err)
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	// Log any errors and fail the request so the cron dashboard shows it, otherwise write "Cron OK"
+	logger.LogService(ctx, "CronHandler", err)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cron %s failed: %s", jobName, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if !ran {
+		fmt.Fprintf(w, "Cron %s not due", jobName)
+		return
+	}
+	fmt.Fprintf(w, "Cron %s OK", jobName)
+}
@@ -1,15 +1,17 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/frasnym/go-expense-telebot/common"
 	"github.com/frasnym/go-expense-telebot/common/ctxdata"
 	"github.com/frasnym/go-expense-telebot/common/logger"
 	"github.com/frasnym/go-expense-telebot/common/notification"
 	"github.com/frasnym/go-expense-telebot/config"
-	"github.com/frasnym/go-expense-telebot/pkg/gsheet"
+	"github.com/frasnym/go-expense-telebot/pkg/callback"
 	"github.com/frasnym/go-expense-telebot/pkg/session"
 	"github.com/frasnym/go-expense-telebot/pkg/telebot"
 	"github.com/frasnym/go-expense-telebot/repository"
@@ -35,13 +37,32 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Init repo
 	botRepo := repository.NewBotRepository(cfg, telebot.GetBot())
-	gsheetRepo := repository.NewGSheetRepository(cfg, gsheet.GetService())
+	gsheetRepo, expenseStore, err := repository.NewStorage(cfg)
+	if err != nil {
+		err = fmt.Errorf("err repository.NewStorage: %w", err)
+		return
+	}
 
 	// Init client
 	notificationClient := notification.New(botRepo)
 
 	// Init service
-	spendeeSvc := service.NewSpendeeService(&botRepo, &gsheetRepo, &notificationClient)
+	budgetSvc := service.NewBudgetService(cfg, &botRepo, &gsheetRepo, &expenseStore, &notificationClient)
+	reportSvc := service.NewReportService(&botRepo, &gsheetRepo, &expenseStore)
+	recurringSvc := service.NewRecurringService(&botRepo, &gsheetRepo, &expenseStore, &notificationClient, budgetSvc)
+	exportSvc := service.NewExportService(cfg, &botRepo, &gsheetRepo, &expenseStore)
+	suggestSvc := service.NewSuggestService(&botRepo, &gsheetRepo, &expenseStore, budgetSvc)
+	spendeeSvc := service.NewSpendeeService(&botRepo, &gsheetRepo, &expenseStore, &notificationClient, budgetSvc, suggestSvc)
+	importSvc := service.NewImportService(&botRepo, &gsheetRepo, &expenseStore, &notificationClient, budgetSvc, suggestSvc)
+	csvImportSvc := service.NewCSVImportService(cfg, &botRepo, &gsheetRepo, &expenseStore, &notificationClient, budgetSvc, suggestSvc)
+	ruleSvc := service.NewRuleService(&botRepo, &gsheetRepo)
+	categoryMapSvc := service.NewCategoryMapService(&botRepo, &gsheetRepo)
+	categorySvc := service.NewCategoryService(&botRepo, &gsheetRepo, &expenseStore)
+	importJournalSvc := service.NewImportJournalService(&botRepo, &gsheetRepo, &expenseStore)
+	expenseEditSvc := service.NewExpenseEditService(&botRepo, &gsheetRepo, &expenseStore, budgetSvc)
+	findSvc := service.NewFindService(&botRepo, &expenseStore)
+	bankTextSvc := service.NewBankTextService(&botRepo, &gsheetRepo, &expenseStore, budgetSvc, suggestSvc)
+	uploadSvc := service.NewUploadService(cfg, &botRepo, &notificationClient, spendeeSvc, importSvc, csvImportSvc)
 
 	// Get the update from the request body
 	update, err := botRepo.GetUpdate(ctx, r.Body)
@@ -50,6 +71,91 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Handle inline keyboard button presses
+	if update.CallbackQuery != nil {
+		query := update.CallbackQuery
+		if query.Message == nil {
+			err = fmt.Errorf("callback query without message: %s", query.ID)
+			return
+		}
+
+		userID := query.From.ID
+		chatID := query.Message.Chat.ID
+		messageID := query.Message.MessageID
+
+		// Always acknowledge the callback so the client stops showing a loading state
+		answerText := ""
+		defer func() {
+			if errAnswer := botRepo.AnswerCallbackQuery(ctx, query.ID, answerText); errAnswer != nil {
+				logger.Warn(ctx, fmt.Sprintf("botRepo.AnswerCallbackQuery: %s", errAnswer.Error()))
+			}
+		}()
+
+		action, args, errCallback := callback.Decode(query.Data)
+		if errCallback != nil {
+			answerText = "Invalid action"
+			err = fmt.Errorf("err callback.Decode: %w", errCallback)
+			return
+		}
+
+		switch action {
+		case common.CallbackCancel:
+			if err = spendeeSvc.Cancel(ctx, userID, chatID, messageID); err != nil {
+				err = fmt.Errorf("err spendeeSvc.Cancel: %w", err)
+			}
+			return
+		case common.CallbackSelectSheet:
+			if len(args) != 1 {
+				answerText = "Invalid action"
+				err = fmt.Errorf("invalid callback args: %v", args)
+				return
+			}
+			if err = uploadSvc.SelectSheet(ctx, userID, chatID, messageID, args[0]); err != nil {
+				err = fmt.Errorf("err uploadSvc.SelectSheet: %w", err)
+			}
+			return
+		case common.CallbackCSVMapping:
+			if err = csvImportSvc.Map(ctx, userID, chatID, messageID, args); err != nil {
+				err = fmt.Errorf("err csvImportSvc.Map: %w", err)
+			}
+			return
+		case common.CallbackSuggest:
+			if err = suggestSvc.Pick(ctx, chatID, messageID, args); err != nil {
+				err = fmt.Errorf("err suggestSvc.Pick: %w", err)
+			}
+			return
+		case common.CallbackSuggestMore:
+			if err = suggestSvc.More(ctx, chatID, messageID, args); err != nil {
+				err = fmt.Errorf("err suggestSvc.More: %w", err)
+			}
+			return
+		case common.CallbackEdit:
+			if err = expenseEditSvc.Show(ctx, chatID, messageID, args); err != nil {
+				err = fmt.Errorf("err expenseEditSvc.Show: %w", err)
+			}
+			return
+		case common.CallbackEditField:
+			if err = expenseEditSvc.Field(ctx, chatID, messageID, args); err != nil {
+				err = fmt.Errorf("err expenseEditSvc.Field: %w", err)
+			}
+			return
+		case common.CallbackDelete:
+			if err = expenseEditSvc.Delete(ctx, chatID, messageID, args); err != nil {
+				err = fmt.Errorf("err expenseEditSvc.Delete: %w", err)
+			}
+			return
+		case common.CallbackFindPage:
+			if err = findSvc.Page(ctx, chatID, messageID, query.Message.Text, args); err != nil {
+				err = fmt.Errorf("err findSvc.Page: %w", err)
+			}
+			return
+		default:
+			answerText = "Unknown action"
+			err = fmt.Errorf("invalid callback action: %s", action)
+			return
+		}
+	}
+
 	// Handle messages and commands
 	if update.Message != nil {
 		userID := update.Message.From.ID
@@ -58,19 +164,124 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 		// Handle commands
 		if update.Message.IsCommand() {
 			switch update.Message.Command() {
+			case common.CommandUpload:
+				if err = uploadSvc.Request(ctx, userID, chatID); err != nil {
+					err = fmt.Errorf("err uploadSvc.Request: %w", err)
+				}
+				return
 			case common.CommandUploadSpendee:
 				if err = spendeeSvc.Request(ctx, userID, chatID); err != nil {
 					err = fmt.Errorf("err spendeeSvc.Request: %w", err)
 				}
 				return
+			case common.CommandUploadOFX, common.CommandUploadCamt:
+				if err = importSvc.Request(ctx, userID, chatID, update.Message.Command(), update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err importSvc.Request: %w", err)
+				}
+				return
+			case common.CommandReport:
+				if err = reportSvc.Monthly(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err reportSvc.Monthly: %w", err)
+				}
+				return
+			case common.CommandTrend:
+				if err = reportSvc.Trend(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err reportSvc.Trend: %w", err)
+				}
+				return
+			case common.CommandBudget:
+				if err = budgetSvc.Command(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err budgetSvc.Command: %w", err)
+				}
+				return
+			case common.CommandRecurring:
+				if err = recurringSvc.Command(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err recurringSvc.Command: %w", err)
+				}
+				return
+			case common.CommandExport:
+				if err = exportSvc.Export(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err exportSvc.Export: %w", err)
+				}
+				return
+			case common.CommandLedger:
+				if err = exportSvc.Ledger(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err exportSvc.Ledger: %w", err)
+				}
+				return
+			case common.CommandRules:
+				if err = ruleSvc.Command(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err ruleSvc.Command: %w", err)
+				}
+				return
+			case common.CommandMapCategory:
+				if err = categoryMapSvc.Command(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err categoryMapSvc.Command: %w", err)
+				}
+				return
+			case common.CommandCategories:
+				if err = categorySvc.Command(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err categorySvc.Command: %w", err)
+				}
+				return
+			case common.CommandUndoImport:
+				if err = importJournalSvc.Undo(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err importJournalSvc.Undo: %w", err)
+				}
+				return
+			case common.CommandLast:
+				if err = expenseEditSvc.Last(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err expenseEditSvc.Last: %w", err)
+				}
+				return
+			case common.CommandFind:
+				if err = findSvc.Find(ctx, chatID, update.Message.CommandArguments()); err != nil {
+					err = fmt.Errorf("err findSvc.Find: %w", err)
+				}
+				return
+			case common.CommandTemplates:
+				if err = bankTextSvc.Templates(ctx, chatID); err != nil {
+					err = fmt.Errorf("err bankTextSvc.Templates: %w", err)
+				}
+				return
+			case common.CommandBank:
+				if err = bankTextSvc.Record(ctx, chatID, update.Message.CommandArguments(), time.Unix(int64(update.Message.Date), 0)); err != nil {
+					err = fmt.Errorf("err bankTextSvc.Record: %w", err)
+				}
+				return
 			default:
 				err = fmt.Errorf("invalid command: %s", update.Message.Command())
 				return
 			}
 		}
 
+		// Replies to the bot's messages about an expense correct it
+		if reply := update.Message.ReplyToMessage; reply != nil && reply.From != nil && reply.From.ID == telebot.GetBot().Self.ID {
+			err = expenseEditSvc.Correct(ctx, chatID, reply.Text, update.Message.Text)
+			if err == nil {
+				return
+			}
+			if !errors.Is(err, common.ErrNoExpenseRef) && !errors.Is(err, common.ErrNoCorrection) {
+				err = fmt.Errorf("err expenseEditSvc.Correct: %w", err)
+				return
+			}
+			err = nil
+		}
+
 		// Get the user's current action
 		action, errSession := session.GetAction(userID)
+		if errSession == common.ErrNoSession && update.Message.Text != "" {
+			// Text outside of any session may be a forwarded or pasted bank notification
+			err = bankTextSvc.Parse(ctx, chatID, update.Message.Text, time.Unix(int64(update.Message.Date), 0))
+			if err == nil {
+				return
+			}
+			if !errors.Is(err, common.ErrNoTemplateMatch) {
+				err = fmt.Errorf("err bankTextSvc.Parse: %w", err)
+				return
+			}
+			err = nil
+		}
 		if errSession != nil {
 			logger.Warn(ctx, fmt.Sprintf("session.GetAction: %s", errSession.Error()))
 			return
@@ -78,17 +289,19 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 
 		// Handle requests based on the user's current action
 		switch action {
-		case common.CommandUploadSpendee:
-			if update.Message.Document != nil {
-				fileID := update.Message.Document.FileID
-				if err = spendeeSvc.Processor(ctx, userID, fileID); err != nil {
-					err = fmt.Errorf("err spendeeSvc.Processor: %w", err)
+		case common.CommandUpload, common.CommandUploadSpendee, common.CommandUploadOFX, common.CommandUploadCamt:
+			// Text is the profile name asked at the end of the column mapping
+			if update.Message.Document == nil {
+				if err = csvImportSvc.Name(ctx, userID, update.Message.Text); err != nil {
+					err = fmt.Errorf("err csvImportSvc.Name: %w", err)
 				}
 				return
-			} else {
-				notificationClient.NotifySendToChat(ctx, userID, "no file uploaded")
 			}
 
+			// The format is detected from the content, whichever upload command started the session
+			if err = uploadSvc.Processor(ctx, userID, update.Message.Document); err != nil {
+				err = fmt.Errorf("err uploadSvc.Processor: %w", err)
+			}
 			return
 		}
 
@@ -0,0 +1,58 @@
+// Command ledger-export writes the expenses of the spreadsheet as a ledger, hledger or beancount journal.
+//
+// Usage:
+//
+//	go run ./cmd/ledger-export [-o file] <YYYY-MM>[..<YYYY-MM>] [ledger|hledger|beancount] [category=<name>] [label=<name>] [wallet=<name>]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/frasnym/go-expense-telebot/config"
+	"github.com/frasnym/go-expense-telebot/repository"
+	"github.com/frasnym/go-expense-telebot/service"
+)
+
+func main() {
+	output := flag.String("o", "", "output file, defaults to stdout")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ledger-export [-o file] <YYYY-MM>[..<YYYY-MM>] [ledger|hledger|beancount] [category=<name>] [label=<name>] [wallet=<name>]")
+		os.Exit(2)
+	}
+
+	cfg := config.GetConfig()
+
+	// Init repo, the bot isn't needed to write to a file
+	var botRepo repository.BotRepository
+	gsheetRepo, expenseStore, err := repository.NewStorage(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to init storage: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Init service
+	exportSvc := service.NewExportService(cfg, &botRepo, &gsheetRepo, &expenseStore)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to create %s: %s\n", *output, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := exportSvc.WriteLedger(context.Background(), w, strings.Join(flag.Args(), " ")); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SplitArgs splits command arguments on spaces, keeping double quoted arguments whole,
+// e.g. `test "Food & Drink" 150000` -> ["test", "Food & Drink", "150000"].
+// Telegram clients often turn quotes into typographic ones, those are accepted as well.
+func SplitArgs(s string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes, hasArg := false, false
+
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '“' || r == '”':
+			inQuotes = !inQuotes
+			hasArg = true
+		case unicode.IsSpace(r) && !inQuotes:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+
+	return args
+}
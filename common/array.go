@@ -3,3 +3,13 @@ package common
 func InsertAndShift[T any](slice []T, element T) []T {
 	return append([]T{element}, slice...)
 }
+
+// Contains reports whether element is in slice.
+func Contains[T comparable](slice []T, element T) bool {
+	for _, v := range slice {
+		if v == element {
+			return true
+		}
+	}
+	return false
+}
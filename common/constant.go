@@ -3,7 +3,81 @@ package common
 import "time"
 
 const (
+	CommandUpload        = "upload"
 	CommandUploadSpendee = "upload_spendee"
+	CommandUploadOFX     = "upload_ofx"
+	CommandUploadCamt    = "upload_camt"
+	CommandReport        = "report"
+	CommandTrend         = "trend"
+	CommandBudget        = "budget"
+	CommandRecurring     = "recurring"
+	CommandExport        = "export"
+	CommandLedger        = "ledger"
+	CommandTemplates     = "templates"
+	CommandBank          = "bank"
+	CommandRules         = "rules"
+	CommandMapCategory   = "map_category"
+	CommandCategories    = "categories"
+	CommandUndoImport    = "undo_import"
+	CommandLast          = "last"
+	CommandFind          = "find"
+
+	CallbackCancel      = "cancel"
+	CallbackSelectSheet = "sheet"
+	CallbackCSVMapping  = "csvmap"
+	CallbackSuggest     = "sg"
+	CallbackSuggestMore = "sgm"
+	CallbackEdit        = "ed"
+	CallbackEditField   = "edf"
+	CallbackDelete      = "del"
+	CallbackFindPage    = "fp"
+
+	SessionKeyWallet     = "wallet"
+	SessionKeyFileID     = "file_id"
+	SessionKeySheet      = "sheet"
+	SessionKeyCSVHeader  = "csv_header"
+	SessionKeyCSVSample  = "csv_sample"
+	SessionKeyCSVProfile = "csv_profile"
+	SessionKeyCSVStep    = "csv_step"
+	SessionKeyFileName   = "file_name"
 
 	SessionTimeout = 10 * time.Second
+	// PromptSessionTimeout is how long a session waits for the answer to a question,
+	// e.g. a step of the CSV column mapping or the worksheet to import
+	PromptSessionTimeout = 5 * time.Minute
+
+	// JobsInterval is how often the in-process scheduler looks for due jobs
+	JobsInterval = time.Minute
+
+	SheetBudgets     = "_budgets"
+	SheetRecurring   = "_recurring"
+	SheetJobs        = "_jobs"
+	SheetAccounts    = "_accounts"
+	SheetCSVProfiles = "_csv_profiles"
+	SheetTemplates   = "_templates"
+	SheetUnparsed    = "_unparsed"
+	SheetRules       = "_rules"
+	SheetCategoryMap = "_category_map"
+	SheetCategories  = "_categories"
+	SheetSync        = "_sync"
+	SheetImports     = "_imports"
+
+	// DefaultCurrency is used for expenses without currency when DEFAULT_CURRENCY is not set
+	DefaultCurrency = "IDR"
+
+	// DefaultMaxUploadSize is the upload limit when MAX_UPLOAD_SIZE is not set, bots can't download larger files
+	DefaultMaxUploadSize = 20 << 20
+
+	// DefaultGsheetRateLimit is the number of Sheets API calls a minute when GSHEET_RATE_LIMIT is not set,
+	// the default quota of the API
+	DefaultGsheetRateLimit = 60
+
+	// StorageGSheet keeps everything in the Google spreadsheet, the default STORAGE
+	StorageGSheet = "gsheet"
+	// StorageLocal keeps everything in JSON files under LOCAL_DATA_DIR, no Google credentials needed
+	StorageLocal = "local"
+	// StoragePostgres keeps expenses in the DATABASE_URL database, optionally mirrored to the spreadsheet
+	StoragePostgres = "postgres"
+	// DefaultLocalDataDir is used when LOCAL_DATA_DIR is not set
+	DefaultLocalDataDir = "data"
 )
@@ -5,6 +5,23 @@ import (
 	"time"
 )
 
+const (
+	// SheetDateLayout is the layout used for the date column of month tabs.
+	SheetDateLayout = "2006-01-02 15:04:05"
+	// PeriodLayout is the layout users type to refer to a month, e.g. "2023-09".
+	PeriodLayout = "2006-01"
+)
+
+// sheetDateLayouts lists the layouts a date cell may come back as, depending on the sheet's locale formatting.
+var sheetDateLayouts = []string{
+	SheetDateLayout,
+	"2006-01-02",
+	"1/2/2006 15:04:05",
+	"1/2/2006",
+	"2/1/2006 15:04:05",
+	time.RFC3339,
+}
+
 // Helper function to parse the date string into a time.Time object
 func ParseSpendeeDate(dateString string) time.Time {
 	date, err := time.Parse(time.RFC3339, dateString)
@@ -13,3 +30,34 @@ func ParseSpendeeDate(dateString string) time.Time {
 	}
 	return date
 }
+
+// ParseSheetDate parses the date column of a month tab.
+func ParseSheetDate(dateString string) (time.Time, error) {
+	for _, layout := range sheetDateLayouts {
+		if date, err := time.Parse(layout, dateString); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unknown date format: %s", dateString)
+}
+
+// ParsePeriod parses a "YYYY-MM" period, defaulting to the current month when empty.
+func ParsePeriod(period string) (time.Time, error) {
+	if period == "" {
+		year, month, _ := time.Now().Date()
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	return time.Parse(PeriodLayout, period)
+}
+
+// SheetNameForPeriod returns the month tab name holding the given period, e.g. "09".
+func SheetNameForPeriod(period time.Time) string {
+	return period.Format("01")
+}
+
+// DaysInMonth returns the number of days of the period's month.
+func DaysInMonth(period time.Time) int {
+	return time.Date(period.Year(), period.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -6,4 +6,13 @@ var (
 	ErrNoChanges = errors.New("no changes")
 	ErrTimeout   = errors.New("timeout")
 	ErrNoSession = errors.New("no active session")
+	ErrTooLarge  = errors.New("file too large")
+	ErrNotFound  = errors.New("not found")
+
+	// ErrNoExpenseRef is returned for replies to messages that don't locate an expense
+	ErrNoExpenseRef = errors.New("no expense reference")
+	// ErrNoCorrection is returned for replies to a message about an expense that don't name a field to correct
+	ErrNoCorrection = errors.New("no expense correction")
+	// ErrNoTemplateMatch is returned for text no bank notification template recognizes
+	ErrNoTemplateMatch = errors.New("no bank notification template matches")
 )
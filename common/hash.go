@@ -0,0 +1,13 @@
+package common
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+)
+
+// Fingerprint returns a short stable hash of parts, used to identify rows that have no ID of their own.
+func Fingerprint(parts ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(sum[:8])
+}
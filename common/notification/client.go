@@ -11,6 +11,7 @@ import (
 
 type NotificationClient interface {
 	NotifySendToChat(ctx context.Context, userID int, msg string) error
+	NotifyChat(ctx context.Context, chatID int64, msg string) error
 }
 
 type notificationClient struct {
@@ -38,6 +39,22 @@ func (c *notificationClient) NotifySendToChat(ctx context.Context, userID int, m
 	return nil
 }
 
+// NotifyChat sends msg to chatID directly, for notifications not tied to a user's session (e.g. group alerts).
+func (c *notificationClient) NotifyChat(ctx context.Context, chatID int64, msg string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "NotifyChat", err)
+	}()
+
+	_, err = c.botRepo.SendTextMessage(ctx, chatID, msg)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
 func New(botRepo repository.BotRepository) NotificationClient {
 	return &notificationClient{botRepo: botRepo}
 }
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseAmount parses a sheet or CSV amount such as "150000", "150,000.00" or "-25000".
+func ParseAmount(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, ",", "")
+	s = strings.ReplaceAll(s, " ", "")
+	return strconv.ParseFloat(s, 64)
+}
+
+// FormatAmount formats an amount with thousand separators and no decimals, e.g. 1500000 -> "1,500,000".
+func FormatAmount(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatFloat(math.Round(amount), 'f', 0, 64)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+
+	return sign + b.String()
+}
+
+// FormatPercentChange formats the change from previous to current, e.g. "+12.5%".
+// It returns "n/a" when there is nothing to compare against.
+func FormatPercentChange(current, previous float64) string {
+	if previous == 0 {
+		return "n/a"
+	}
+
+	change := (current - previous) / previous * 100
+	prefix := ""
+	if change >= 0 {
+		prefix = "+"
+	}
+
+	return prefix + strconv.FormatFloat(change, 'f', 1, 64) + "%"
+}
@@ -0,0 +1,11 @@
+package common
+
+// Truncate shortens s to at most n runes, ending it with mark when it is cut.
+func Truncate(s string, n int, mark string) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	return string(runes[:n-len([]rune(mark))]) + mark
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 
 	"github.com/joho/godotenv"
@@ -16,8 +18,8 @@ func init() {
 func initConfig() {
 	// Check if the code is running on Vercel
 	if os.Getenv("VERCEL") != "1" {
-		// Load environment variables from .env file if not vercel
-		if err := godotenv.Load(); err != nil {
+		// Load environment variables from .env file if not vercel, they may as well be set already
+		if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			panic(fmt.Errorf("error loading .env file: %w", err))
 		}
 	}
@@ -33,6 +35,18 @@ func initConfig() {
 		GsheetUserPrivateKey:   os.Getenv("GSHEET_USER_PRIVATE_KEY"),
 		GsheetUserClientEmail:  os.Getenv("GSHEET_USER_CLIENT_EMAIL"),
 		GsheetUserClientID:     os.Getenv("GSHEET_USER_CLIENT_ID"),
+		GsheetRateLimit:        os.Getenv("GSHEET_RATE_LIMIT"),
+		CallbackSecret:         os.Getenv("CALLBACK_SECRET"),
+		BudgetAlertThresholds:  os.Getenv("BUDGET_ALERT_THRESHOLDS"),
+		BudgetAlertChatID:      os.Getenv("BUDGET_ALERT_CHAT_ID"),
+		CronSecret:             os.Getenv("CRON_SECRET"),
+		JobsChatID:             os.Getenv("JOBS_CHAT_ID"),
+		DefaultCurrency:        os.Getenv("DEFAULT_CURRENCY"),
+		MaxUploadSize:          os.Getenv("MAX_UPLOAD_SIZE"),
+		Storage:                os.Getenv("STORAGE"),
+		LocalDataDir:           os.Getenv("LOCAL_DATA_DIR"),
+		DatabaseURL:            os.Getenv("DATABASE_URL"),
+		StorageMirror:          os.Getenv("STORAGE_MIRROR"),
 	}
 }
 
@@ -11,4 +11,16 @@ type Config struct {
 	GsheetUserPrivateKey   string `env:"GSHEET_USER_PRIVATE_KEY"`
 	GsheetUserClientEmail  string `env:"GSHEET_USER_CLIENT_EMAIL"`
 	GsheetUserClientID     string `env:"GSHEET_USER_CLIENT_ID"`
+	GsheetRateLimit        string `env:"GSHEET_RATE_LIMIT"`
+	CallbackSecret         string `env:"CALLBACK_SECRET"`
+	BudgetAlertThresholds  string `env:"BUDGET_ALERT_THRESHOLDS"`
+	BudgetAlertChatID      string `env:"BUDGET_ALERT_CHAT_ID"`
+	CronSecret             string `env:"CRON_SECRET"`
+	JobsChatID             string `env:"JOBS_CHAT_ID"`
+	DefaultCurrency        string `env:"DEFAULT_CURRENCY"`
+	MaxUploadSize          string `env:"MAX_UPLOAD_SIZE"`
+	Storage                string `env:"STORAGE"`
+	LocalDataDir           string `env:"LOCAL_DATA_DIR"`
+	DatabaseURL            string `env:"DATABASE_URL"`
+	StorageMirror          string `env:"STORAGE_MIRROR"`
 }
@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/common/notification"
+	"github.com/frasnym/go-expense-telebot/config"
+	"github.com/frasnym/go-expense-telebot/pkg/session"
+	"github.com/frasnym/go-expense-telebot/repository"
+	"github.com/frasnym/go-expense-telebot/service"
+)
+
+// Job names, also used as the path of the cron endpoint: /cron/<name>
+const (
+	JobMonthlyReport = "monthly-report"
+	JobRecurring     = "recurring"
+	JobReminder      = "reminder"
+	JobSessionSweep  = "session-sweep"
+	JobSheetSync     = "sheet-sync"
+)
+
+const reminderText = "Reminder: last month has ended, upload your Spendee export with /" + common.CommandUploadSpendee
+
+// NewDefaultScheduler creates a Scheduler with all the bot's jobs registered.
+// Jobs sending messages to a chat are skipped when JOBS_CHAT_ID is not configured.
+func NewDefaultScheduler(cfg *config.Config, botRepo repository.BotRepository, gsheetRepo repository.GSheetRepository, expenseStore repository.ExpenseStore) (*Scheduler, error) {
+	// Init client
+	notificationClient := notification.New(botRepo)
+
+	// Init service
+	budgetSvc := service.NewBudgetService(cfg, &botRepo, &gsheetRepo, &expenseStore, &notificationClient)
+	recurringSvc := service.NewRecurringService(&botRepo, &gsheetRepo, &expenseStore, &notificationClient, budgetSvc)
+	reportSvc := service.NewReportService(&botRepo, &gsheetRepo, &expenseStore)
+	syncSvc := service.NewSyncService(&gsheetRepo, &expenseStore, &notificationClient)
+
+	jobChat := func(ctx context.Context) (int64, bool) {
+		if cfg.JobsChatID == "" {
+			logger.Warn(ctx, "JOBS_CHAT_ID not set, skipping")
+			return 0, false
+		}
+
+		chatID, err := strconv.ParseInt(cfg.JobsChatID, 10, 64)
+		if err != nil {
+			logger.Warn(ctx, fmt.Sprintf("invalid JOBS_CHAT_ID: %s", cfg.JobsChatID))
+			return 0, false
+		}
+
+		return chatID, true
+	}
+
+	scheduler := NewScheduler(NewSheetStateStore(gsheetRepo))
+	registrations := []struct {
+		name string
+		spec string
+		fn   Func
+	}{
+		{JobMonthlyReport, "0 2 1 * *", func(ctx context.Context, now time.Time) error {
+			chatID, ok := jobChat(ctx)
+			if !ok {
+				return nil
+			}
+			previousMonth := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+			return reportSvc.Monthly(ctx, chatID, previousMonth.Format(common.PeriodLayout))
+		}},
+		{JobRecurring, "0 1 * * *", recurringSvc.RunDue},
+		{JobReminder, "0 3 1 * *", func(ctx context.Context, now time.Time) error {
+			chatID, ok := jobChat(ctx)
+			if !ok {
+				return nil
+			}
+			return notificationClient.NotifyChat(ctx, chatID, reminderText)
+		}},
+		// Sessions live in the memory of the process, only the standalone server has something to sweep;
+		// a cron invocation on Vercel runs in a process of its own
+		{JobSessionSweep, "*/5 * * * *", func(ctx context.Context, now time.Time) error {
+			logger.Info(ctx, fmt.Sprintf("swept %d expired sessions", session.SweepExpired()))
+			return nil
+		}},
+		// The standalone server syncs hourly. The Vercel Hobby plan only runs crons once a day, so vercel.json calls
+		// /cron/sheet-sync daily, every call being due; on a plan allowing it, schedule it hourly there too
+		{JobSheetSync, "0 * * * *", func(ctx context.Context, now time.Time) error {
+			// Changes are synced even without a chat to report conflicts to
+			var chatID int64
+			if cfg.JobsChatID != "" {
+				chatID, _ = jobChat(ctx)
+			}
+			return syncSvc.Sync(ctx, chatID, now)
+		}},
+	}
+
+	for _, r := range registrations {
+		if err := scheduler.Register(r.name, r.spec, r.fn); err != nil {
+			return nil, fmt.Errorf("err scheduler.Register: %w", err)
+		}
+	}
+
+	return scheduler, nil
+}
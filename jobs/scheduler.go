@@ -0,0 +1,194 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/pkg/cron"
+)
+
+// missedRunLookback is how far back a job without any recorded run looks for a missed activation.
+const missedRunLookback = time.Hour
+
+var ErrUnknownJob = errors.New("unknown job")
+
+// Func is the work done by a job. now is the time the run was triggered.
+type Func func(ctx context.Context, now time.Time) error
+
+// StateStore persists the last run of each job so a run is never repeated,
+// whether it's triggered by the in-process ticker or the cron endpoint.
+type StateStore interface {
+	LastRun(ctx context.Context, name string) (time.Time, error)
+	SetLastRun(ctx context.Context, name string, at time.Time) error
+}
+
+type job struct {
+	name     string
+	schedule cron.Schedule
+	run      Func
+
+	// next is the next activation known from the last run read or done by this process, zero until then.
+	// running is set while the job runs.
+	next    time.Time
+	running bool
+}
+
+// Scheduler runs registered jobs on their cron schedules.
+// mu guards the jobs and their state, it is never held during I/O.
+type Scheduler struct {
+	store StateStore
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// Register adds a job running fn on the cron expression spec.
+func (s *Scheduler) Register(name, spec string, fn Func) error {
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("err cron.Parse %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[name] = &job{name: name, schedule: schedule, run: fn}
+	return nil
+}
+
+// Names returns the registered job names in alphabetical order.
+func (s *Scheduler) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// RunDue runs every job whose schedule fired since its last run.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) error {
+	var errs []error
+	for _, name := range s.Names() {
+		if _, err := s.Trigger(ctx, name, now, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Trigger runs the named job if its schedule fired since its last run, or unconditionally when force is set.
+// It reports whether the job ran. The last run is only read from the store once the next activation known to
+// the process has come, so idle polling costs nothing; it is read again then, since another process (e.g. the
+// cron endpoint) may have run the job meanwhile. A job already running in this process is not started again.
+func (s *Scheduler) Trigger(ctx context.Context, name string, now time.Time, force bool) (bool, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, fmt.Sprintf("JobTrigger %s", name), err)
+	}()
+
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	var next time.Time
+	if ok {
+		next = j.next
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("%w: %s", ErrUnknownJob, name)
+		return false, err
+	}
+
+	if !force {
+		if !next.IsZero() && next.After(now) {
+			return false, nil
+		}
+
+		lastRun, errState := s.store.LastRun(ctx, name)
+		if errState != nil {
+			err = fmt.Errorf("err store.LastRun: %w", errState)
+			return false, err
+		}
+		if lastRun.IsZero() {
+			lastRun = now.Add(-missedRunLookback)
+		}
+
+		next, err = j.schedule.Next(lastRun)
+		if err != nil {
+			err = fmt.Errorf("err schedule.Next: %w", err)
+			return false, err
+		}
+
+		s.mu.Lock()
+		j.next = next
+		s.mu.Unlock()
+		if next.After(now) {
+			return false, nil
+		}
+	}
+
+	s.mu.Lock()
+	if j.running {
+		s.mu.Unlock()
+		return false, nil
+	}
+	j.running = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		j.running = false
+		s.mu.Unlock()
+	}()
+
+	// Record the run before doing the work, a failed run is retried on the next activation rather than immediately
+	if err = s.store.SetLastRun(ctx, name, now); err != nil {
+		err = fmt.Errorf("err store.SetLastRun: %w", err)
+		return false, err
+	}
+
+	if next, errNext := j.schedule.Next(now); errNext == nil {
+		s.mu.Lock()
+		j.next = next
+		s.mu.Unlock()
+	}
+
+	if err = j.run(ctx, now); err != nil {
+		err = fmt.Errorf("err run: %w", err)
+		return true, err
+	}
+
+	return true, nil
+}
+
+// Start runs due jobs on every interval until ctx is done.
+func (s *Scheduler) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Errors are logged per job
+			s.RunDue(ctx, now)
+		}
+	}
+}
+
+// NewScheduler creates a Scheduler persisting its state in store.
+func NewScheduler(store StateStore) *Scheduler {
+	return &Scheduler{store: store, jobs: map[string]*job{}}
+}
@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+type sheetStateStore struct {
+	gsheetRepo repository.GSheetRepository
+}
+
+// LastRun implements StateStore.
+func (s *sheetStateStore) LastRun(ctx context.Context, name string) (time.Time, error) {
+	runs, err := s.getLastRuns(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return runs[name], nil
+}
+
+// SetLastRun implements StateStore.
+func (s *sheetStateStore) SetLastRun(ctx context.Context, name string, at time.Time) error {
+	runs, err := s.getLastRuns(ctx)
+	if err != nil {
+		return err
+	}
+	runs[name] = at
+
+	rows := [][]any{{"job", "last_run"}}
+	for _, jobName := range sortedKeys(runs) {
+		rows = append(rows, []any{jobName, runs[jobName].Format(time.RFC3339)})
+	}
+
+	if err := s.gsheetRepo.UpdateValues(ctx, fmt.Sprintf("%s!A:B", common.SheetJobs), rows); err != nil {
+		return fmt.Errorf("err gsheetRepo.UpdateValues: %w", err)
+	}
+
+	return nil
+}
+
+// getLastRuns reads the jobs tab as job name -> last run.
+func (s *sheetStateStore) getLastRuns(ctx context.Context) (map[string]time.Time, error) {
+	if err := s.gsheetRepo.EnsureSheet(ctx, common.SheetJobs); err != nil {
+		return nil, fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+
+	gsheetValues, err := s.gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:B", common.SheetJobs))
+	if err != nil {
+		return nil, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	runs := map[string]time.Time{}
+	for _, row := range gsheetValues.Values {
+		if len(row) < 2 {
+			continue
+		}
+
+		// The header doesn't parse as a time
+		at, err := time.Parse(time.RFC3339, fmt.Sprint(row[1]))
+		if err != nil {
+			continue
+		}
+		runs[fmt.Sprint(row[0])] = at
+	}
+
+	return runs, nil
+}
+
+func sortedKeys(m map[string]time.Time) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// NewSheetStateStore creates a StateStore keeping the last runs in the jobs tab of the spreadsheet.
+func NewSheetStateStore(gsheetRepo repository.GSheetRepository) StateStore {
+	return &sheetStateStore{gsheetRepo: gsheetRepo}
+}
@@ -1,18 +1,36 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	handler "github.com/frasnym/go-expense-telebot/api"
+	"github.com/frasnym/go-expense-telebot/common"
 	"github.com/frasnym/go-expense-telebot/config"
+	"github.com/frasnym/go-expense-telebot/jobs"
+	"github.com/frasnym/go-expense-telebot/pkg/telebot"
+	"github.com/frasnym/go-expense-telebot/repository"
 )
 
 func main() {
 	cfg := config.GetConfig()
 
+	// Run scheduled jobs in-process, the Vercel deployment relies on the cron endpoint instead
+	botRepo := repository.NewBotRepository(cfg, telebot.GetBot())
+	gsheetRepo, expenseStore, err := repository.NewStorage(cfg)
+	if err != nil {
+		panic(fmt.Errorf("unable to init storage: %w", err))
+	}
+	scheduler, err := jobs.NewDefaultScheduler(cfg, botRepo, gsheetRepo, expenseStore)
+	if err != nil {
+		panic(fmt.Errorf("unable to init scheduler: %w", err))
+	}
+	go scheduler.Start(context.Background(), common.JobsInterval)
+
 	http.HandleFunc("/", handler.IndexHandler)
 	http.HandleFunc("/webhook", handler.WebhookHandler)
+	http.HandleFunc("/cron/", handler.CronHandler)
 	fmt.Printf("Server is running on port %s...\n", cfg.Port)
 	http.ListenAndServe(fmt.Sprint(":", cfg.Port), nil)
 }
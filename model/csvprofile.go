@@ -0,0 +1,18 @@
+package model
+
+// CSVProfile maps the columns of a bank CSV export to expenses.
+// Columns are referenced by header name, an empty name means the column is not mapped.
+// Amounts come either from AmountColumn or from the DebitColumn/CreditColumn pair.
+type CSVProfile struct {
+	Signature        string
+	Name             string
+	DateColumn       string
+	DateLayout       string
+	AmountColumn     string
+	NegativeExpenses bool
+	DebitColumn      string
+	CreditColumn     string
+	NoteColumn       string
+	CategoryColumn   string
+	DecimalComma     bool
+}
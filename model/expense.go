@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Expense is a single row of a month tab.
+// Ref identifies the source of the row (e.g. a bank transaction ID) and is used to skip duplicates on import.
+type Expense struct {
+	Date     time.Time
+	Category string
+	Amount   float64
+	Note     string
+	Label    string
+	Wallet   string
+	Currency string
+	Ref      string
+}
+
+// StoredExpense is an expense together with its location in the expense store: its month tab and row.
+// Row is 1-based, like the row numbers shown in the sheet.
+type StoredExpense struct {
+	Expense
+	Sheet string
+	Row   int
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// ImportRecord is the journal entry of an import: where it came from and which rows it wrote.
+// Ranges are A1 ranges of the expense store such as "09!A12:H14", Fingerprints identify the rows
+// written in the order of Ranges so undoing leaves alone the rows changed since.
+type ImportRecord struct {
+	ID           string
+	Source       string
+	UserID       int
+	ChatID       int64
+	Imported     time.Time
+	Periods      []time.Time
+	Ranges       []string
+	Fingerprints []string
+	Undone       time.Time
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Recurring schedules
+const (
+	ScheduleMonthly = "monthly"
+	ScheduleWeekly  = "weekly"
+	ScheduleYearly  = "yearly"
+)
+
+// Recurring is an expense entered automatically on a schedule.
+// Day holds the schedule specific day: "1".."31" for monthly, "mon".."sun" for weekly and "MM-DD" for yearly.
+type Recurring struct {
+	ID       string
+	Schedule string
+	Day      string
+	Amount   float64
+	Category string
+	Note     string
+	ChatID   int64
+	Created  time.Time
+	LastRun  time.Time
+}
@@ -9,4 +9,7 @@ type Session struct {
 	ChatID    int64
 	MessageID int
 	StartTime time.Time
+	// Timeout overrides common.SessionTimeout when set
+	Timeout time.Duration
+	Data    map[string]string
 }
@@ -0,0 +1,140 @@
+package banktext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Named groups a template pattern may capture. Only amount is required.
+const (
+	GroupAmount   = "amount"
+	GroupCurrency = "currency"
+	GroupMerchant = "merchant"
+	GroupDate     = "date"
+	GroupAccount  = "account"
+)
+
+// Template recognizes the notifications of one bank.
+// DateLayouts are tried in order on the date group, layouts without a year leave it to the caller.
+// Credit marks notifications of money received rather than spent.
+type Template struct {
+	Bank        string
+	Name        string
+	Pattern     *regexp.Regexp
+	DateLayouts []string
+	Credit      bool
+}
+
+// Match holds the groups captured from a notification, as written in it.
+type Match struct {
+	Template Template
+	Amount   string
+	Currency string
+	Merchant string
+	Date     string
+	Account  string
+}
+
+// dayMonthLayouts are the layouts of the default templates, day first like Indonesian and European banks write them.
+var dayMonthLayouts = []string{"2/1/2006", "2/1/06", "2/1", "2-1-2006", "2-1-06", "2-1", "2 Jan 2006", "2 Jan"}
+
+const (
+	amountPattern   = `(?P<currency>IDR|Rp|[A-Z]{3})\.?\s*(?P<amount>\d[\d.,]*)`
+	datePattern     = `(?P<date>\d{1,2}[/-]\d{1,2}(?:[/-]\d{2,4})?|\d{1,2}\s+[A-Z][a-z]{2}(?:\s+\d{4})?)`
+	merchantPattern = `(?P<merchant>[^.,]+?)`
+	accountPattern  = `(?:[.,]?\s+(?:(?:with|using|from|via|dari)\s+)?(?:your\s+|kartu\s+)?(?:card|acct|account|rek(?:ening)?)\b\D*?(?P<account>\d{3,}))?`
+	endPattern      = `(?:[.,]|\s*$)`
+)
+
+// defaults are tried after the user defined templates.
+var defaults = []Template{
+	{
+		Bank:    "generic",
+		Name:    "debit",
+		Pattern: regexp.MustCompile(`(?i)\bdebit\s+` + amountPattern + `\s+at\s+` + merchantPattern + `\s+on\s+` + datePattern + accountPattern + endPattern),
+	},
+	{
+		Bank:    "generic",
+		Name:    "purchase",
+		Pattern: regexp.MustCompile(`(?i)\b(?:purchase|payment|spent)\s+(?:of\s+)?` + amountPattern + `\s+(?:at|to)\s+` + merchantPattern + `(?:\s+on\s+` + datePattern + `)?` + accountPattern + endPattern),
+	},
+	{
+		Bank:    "generic",
+		Name:    "pembayaran",
+		Pattern: regexp.MustCompile(`(?i)\b(?:pembayaran|transaksi|pembelian)\s+(?:sebesar\s+)?` + amountPattern + `\s+(?:di|ke)\s+` + merchantPattern + `(?:\s+(?:pada|tgl|tanggal)\s+` + datePattern + `)?` + accountPattern + endPattern),
+	},
+	{
+		Bank:    "generic",
+		Name:    "credit",
+		Pattern: regexp.MustCompile(`(?i)\bcredit\s+` + amountPattern + `\s+from\s+` + merchantPattern + `\s+on\s+` + datePattern + accountPattern + endPattern),
+		Credit:  true,
+	},
+}
+
+func init() {
+	for i := range defaults {
+		defaults[i].DateLayouts = dayMonthLayouts
+	}
+}
+
+// Defaults returns the built-in templates.
+func Defaults() []Template {
+	return append([]Template(nil), defaults...)
+}
+
+// NewTemplate compiles a user defined template. pattern must capture the amount group.
+// layouts is a comma separated list of date layouts, the day first defaults are used when empty.
+func NewTemplate(bank, name, pattern, layouts string, credit bool) (Template, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Template{}, fmt.Errorf("err regexp.Compile: %w", err)
+	}
+	if re.SubexpIndex(GroupAmount) < 0 {
+		return Template{}, fmt.Errorf("pattern of %s/%s has no %q group", bank, name, GroupAmount)
+	}
+
+	template := Template{Bank: bank, Name: name, Pattern: re, DateLayouts: dayMonthLayouts, Credit: credit}
+	if strings.TrimSpace(layouts) != "" {
+		template.DateLayouts = nil
+		for _, layout := range strings.Split(layouts, ",") {
+			template.DateLayouts = append(template.DateLayouts, strings.TrimSpace(layout))
+		}
+	}
+
+	return template, nil
+}
+
+// Parse matches text against templates in order and returns the groups of the first match.
+func Parse(text string, templates []Template) (Match, bool) {
+	text = strings.Join(strings.Fields(text), " ")
+
+	for _, template := range templates {
+		groups := template.Pattern.FindStringSubmatch(text)
+		if groups == nil {
+			continue
+		}
+
+		group := func(name string) string {
+			if i := template.Pattern.SubexpIndex(name); i >= 0 {
+				return strings.TrimSpace(groups[i])
+			}
+			return ""
+		}
+
+		match := Match{
+			Template: template,
+			Amount:   strings.TrimRight(group(GroupAmount), ".,"),
+			Currency: group(GroupCurrency),
+			Merchant: group(GroupMerchant),
+			Date:     group(GroupDate),
+			Account:  group(GroupAccount),
+		}
+		if match.Amount == "" {
+			continue
+		}
+		return match, true
+	}
+
+	return Match{}, false
+}
@@ -0,0 +1,71 @@
+package callback
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/frasnym/go-expense-telebot/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// MaxDataLength is the maximum callback data size accepted by Telegram.
+const MaxDataLength = 64
+
+const (
+	fieldSeparator = "|"
+	signSeparator  = "~"
+	signLength     = 6 // bytes of HMAC kept, encoded to 8 base64 characters
+)
+
+var (
+	ErrInvalidData      = errors.New("invalid callback data")
+	ErrInvalidSignature = errors.New("invalid callback signature")
+)
+
+// Encode builds compact callback data in the form "action|arg1|arg2~signature".
+// The signature prevents clients from forging callback data for actions the bot never offered.
+// Callers must keep action and args short, Telegram rejects data longer than MaxDataLength bytes.
+func Encode(action string, args ...string) string {
+	payload := strings.Join(append([]string{action}, args...), fieldSeparator)
+	return payload + signSeparator + sign(payload)
+}
+
+// Decode verifies the signature of callback data and returns its action and arguments.
+func Decode(data string) (string, []string, error) {
+	idx := strings.LastIndex(data, signSeparator)
+	if idx <= 0 {
+		return "", nil, ErrInvalidData
+	}
+
+	payload, signature := data[:idx], data[idx+1:]
+	if !hmac.Equal([]byte(signature), []byte(sign(payload))) {
+		return "", nil, ErrInvalidSignature
+	}
+
+	fields := strings.Split(payload, fieldSeparator)
+	return fields[0], fields[1:], nil
+}
+
+// NewButton creates an inline keyboard button carrying signed callback data.
+func NewButton(text, action string, args ...string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(text, Encode(action, args...))
+}
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret()))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signLength])
+}
+
+func secret() string {
+	cfg := config.GetConfig()
+	if cfg.CallbackSecret != "" {
+		return cfg.CallbackSecret
+	}
+
+	return cfg.TelegramBotToken
+}
@@ -0,0 +1,64 @@
+package callback
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		args   []string
+	}{
+		{name: "no args", action: "cancel", args: []string{}},
+		{name: "args", action: "edit", args: []string{"09", "12", "a1b2c3"}},
+		{name: "empty arg", action: "pick", args: []string{"", "Food"}},
+		{name: "tilde in an arg", action: "note", args: []string{"a~b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := Encode(tt.action, tt.args...)
+			if len(data) > MaxDataLength {
+				t.Errorf("Encode = %q, longer than %d bytes", data, MaxDataLength)
+			}
+
+			action, args, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode(%q): %v", data, err)
+			}
+			if action != tt.action || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("Decode(%q) = %q %q, want %q %q", data, action, args, tt.action, tt.args)
+			}
+		})
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	valid := Encode("delete", "09", "12", "a1b2c3")
+	payload, signature, _ := strings.Cut(valid, signSeparator)
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{name: "changed argument", data: strings.Replace(payload, "12", "13", 1) + signSeparator + signature, wantErr: ErrInvalidSignature},
+		{name: "changed signature", data: payload + signSeparator + strings.Repeat("A", len(signature)), wantErr: ErrInvalidSignature},
+		{name: "signature of another payload", data: payload + signSeparator + sign("cancel"), wantErr: ErrInvalidSignature},
+		{name: "no signature", data: payload, wantErr: ErrInvalidData},
+		{name: "signature only", data: signSeparator + signature, wantErr: ErrInvalidData},
+		{name: "empty", data: "", wantErr: ErrInvalidData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Decode(tt.data); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decode(%q) error = %v, want %v", tt.data, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package camt
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Credit/debit indicators
+const (
+	Credit = "CRDT"
+	Debit  = "DBIT"
+)
+
+var ErrNoStatements = errors.New("no camt.053 statements found")
+
+// Entry is a single booked entry (Ntry) of a statement.
+type Entry struct {
+	StatementID string
+	Account     string
+	Amount      float64
+	Currency    string
+	Indicator   string
+	BookingDate time.Time
+	ValueDate   time.Time
+	Reference   string
+	Counterpart string
+	Remittance  string
+}
+
+// SignedAmount returns the amount as seen from the account: negative for debits.
+func (e Entry) SignedAmount() float64 {
+	if e.Indicator == Debit {
+		return -e.Amount
+	}
+	return e.Amount
+}
+
+type document struct {
+	Statements []statement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type statement struct {
+	ID      string  `xml:"Id"`
+	IBAN    string  `xml:"Acct>Id>IBAN"`
+	Other   string  `xml:"Acct>Id>Othr>Id"`
+	Entries []entry `xml:"Ntry"`
+}
+
+type entry struct {
+	Reference   string     `xml:"NtryRef"`
+	Amount      amount     `xml:"Amt"`
+	Indicator   string     `xml:"CdtDbtInd"`
+	Status      status     `xml:"Sts"`
+	BookingDate dateChoice `xml:"BookgDt"`
+	ValueDate   dateChoice `xml:"ValDt"`
+	ServicerRef string     `xml:"AcctSvcrRef"`
+	Details     []details  `xml:"NtryDtls>TxDtls"`
+}
+
+type amount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// status is plain text before camt.053.001.08 and a Cd element since.
+type status struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+// dateChoice is either a date (Dt) or a date time (DtTm).
+type dateChoice struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type details struct {
+	EndToEndID   string   `xml:"Refs>EndToEndId"`
+	Unstructured []string `xml:"RmtInf>Ustrd"`
+	CreditorName string   `xml:"RltdPties>Cdtr>Nm"`
+	DebtorName   string   `xml:"RltdPties>Dbtr>Nm"`
+}
+
+// Parse reads the booked entries of every statement in a camt.053 document.
+// Pending entries are skipped since they may still change. Entries with neither a booking
+// nor a value date are returned with zero dates, for the caller to report.
+func Parse(r io.Reader) ([]Entry, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("err xml.Decode: %w", err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, ErrNoStatements
+	}
+
+	var entries []Entry
+	for _, stmt := range doc.Statements {
+		account := stmt.IBAN
+		if account == "" {
+			account = stmt.Other
+		}
+
+		for _, ntry := range stmt.Entries {
+			if firstNonEmpty(ntry.Status.Code, ntry.Status.Text) == "PDNG" {
+				continue
+			}
+
+			value, err := strconv.ParseFloat(strings.TrimSpace(ntry.Amount.Value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("statement %s: invalid amount %q: %w", stmt.ID, ntry.Amount.Value, err)
+			}
+
+			booking, err := ntry.BookingDate.parse()
+			if err != nil {
+				return nil, fmt.Errorf("statement %s: invalid booking date: %w", stmt.ID, err)
+			}
+			// Either date may be missing, fall back to the other one
+			valueDate, err := ntry.ValueDate.parse()
+			if err != nil || valueDate.IsZero() {
+				valueDate = booking
+			}
+			if booking.IsZero() {
+				booking = valueDate
+			}
+
+			e := Entry{
+				StatementID: stmt.ID,
+				Account:     account,
+				Amount:      value,
+				Currency:    ntry.Amount.Currency,
+				Indicator:   strings.TrimSpace(ntry.Indicator),
+				BookingDate: booking,
+				ValueDate:   valueDate,
+				Reference:   firstNonEmpty(ntry.ServicerRef, ntry.Reference),
+			}
+
+			var remittance []string
+			for _, d := range ntry.Details {
+				remittance = append(remittance, d.Unstructured...)
+				if e.Counterpart == "" {
+					// The other party is the creditor of a debit and the debtor of a credit
+					e.Counterpart = d.CreditorName
+					if e.Indicator == Credit {
+						e.Counterpart = d.DebtorName
+					}
+				}
+				if e.Reference == "" {
+					e.Reference = d.EndToEndID
+				}
+			}
+			e.Remittance = strings.Join(remittance, " ")
+
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+func (d dateChoice) parse() (time.Time, error) {
+	switch {
+	case d.Date != "":
+		return time.Parse("2006-01-02", strings.TrimSpace(d.Date))
+	case d.DateTime != "":
+		value := strings.TrimSpace(d.DateTime)
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		return time.Parse("2006-01-02T15:04:05", value)
+	}
+
+	return time.Time{}, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package camt
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStatement wraps entries in a camt.053 document of the account NL91ABNA0417164300.
+func testStatement(entries string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Id>STMT-1</Id>
+      <Acct><Id><IBAN>NL91ABNA0417164300</IBAN></Id></Acct>` + entries + `
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+}
+
+func TestParse(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 9, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name    string
+		entries string
+		want    []Entry
+	}{
+		{
+			name: "debit paid to a creditor",
+			entries: `<Ntry><Amt Ccy="EUR">12.50</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>BOOK</Sts>
+				<BookgDt><Dt>2024-09-05</Dt></BookgDt><ValDt><Dt>2024-09-06</Dt></ValDt><AcctSvcrRef>REF-1</AcctSvcrRef>
+				<NtryDtls><TxDtls><RmtInf><Ustrd>coffee</Ustrd><Ustrd>and cake</Ustrd></RmtInf>
+				<RltdPties><Dbtr><Nm>Me</Nm></Dbtr><Cdtr><Nm>Cafe</Nm></Cdtr></RltdPties></TxDtls></NtryDtls></Ntry>`,
+			want: []Entry{{StatementID: "STMT-1", Account: "NL91ABNA0417164300", Amount: 12.5, Currency: "EUR", Indicator: Debit,
+				BookingDate: day(5), ValueDate: day(6), Reference: "REF-1", Counterpart: "Cafe", Remittance: "coffee and cake"}},
+		},
+		{
+			name: "credit received from a debtor",
+			entries: `<Ntry><NtryRef>N-2</NtryRef><Amt Ccy="EUR">100</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts><Cd>BOOK</Cd></Sts>
+				<BookgDt><DtTm>2024-09-07T10:00:00+02:00</DtTm></BookgDt>
+				<NtryDtls><TxDtls><RltdPties><Dbtr><Nm>Employer</Nm></Dbtr><Cdtr><Nm>Me</Nm></Cdtr></RltdPties></TxDtls></NtryDtls></Ntry>`,
+			want: []Entry{{StatementID: "STMT-1", Account: "NL91ABNA0417164300", Amount: 100, Currency: "EUR", Indicator: Credit,
+				BookingDate: time.Date(2024, 9, 7, 10, 0, 0, 0, time.FixedZone("", 2*60*60)),
+				ValueDate:   time.Date(2024, 9, 7, 10, 0, 0, 0, time.FixedZone("", 2*60*60)),
+				Reference:   "N-2", Counterpart: "Employer"}},
+		},
+		{
+			name: "value date only",
+			entries: `<Ntry><Amt Ccy="EUR">5</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>BOOK</Sts><ValDt><Dt>2024-09-08</Dt></ValDt>
+				<NtryDtls><TxDtls><Refs><EndToEndId>E2E-3</EndToEndId></Refs></TxDtls></NtryDtls></Ntry>`,
+			want: []Entry{{StatementID: "STMT-1", Account: "NL91ABNA0417164300", Amount: 5, Currency: "EUR", Indicator: Debit,
+				BookingDate: day(8), ValueDate: day(8), Reference: "E2E-3"}},
+		},
+		{
+			name:    "undated entry",
+			entries: `<Ntry><Amt Ccy="EUR">7</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>BOOK</Sts></Ntry>`,
+			want:    []Entry{{StatementID: "STMT-1", Account: "NL91ABNA0417164300", Amount: 7, Currency: "EUR", Indicator: Debit}},
+		},
+		{
+			name: "pending entries are skipped",
+			entries: `<Ntry><Amt Ccy="EUR">1</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>PDNG</Sts><BookgDt><Dt>2024-09-09</Dt></BookgDt></Ntry>
+				<Ntry><Amt Ccy="EUR">2</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts><Cd>PDNG</Cd></Sts><BookgDt><Dt>2024-09-09</Dt></BookgDt></Ntry>`,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(testStatement(tt.entries)))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Parse returned %d entries, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if !g.BookingDate.Equal(w.BookingDate) || !g.ValueDate.Equal(w.ValueDate) {
+					t.Errorf("entry %d dates = %v, %v, want %v, %v", i, g.BookingDate, g.ValueDate, w.BookingDate, w.ValueDate)
+				}
+				g.BookingDate, g.ValueDate, w.BookingDate, w.ValueDate = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+				if !reflect.DeepEqual(g, w) {
+					t.Errorf("entry %d = %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{name: "no statements", input: `<Document><BkToCstmrStmt></BkToCstmrStmt></Document>`, wantErr: ErrNoStatements},
+		{name: "invalid amount", input: testStatement(`<Ntry><Amt Ccy="EUR">1,5</Amt><CdtDbtInd>DBIT</CdtDbtInd></Ntry>`)},
+		{name: "invalid booking date", input: testStatement(`<Ntry><Amt Ccy="EUR">1</Amt><BookgDt><Dt>05-09-2024</Dt></BookgDt></Ntry>`)},
+		{name: "not XML", input: "date,amount"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			if err == nil {
+				t.Fatal("Parse succeeded, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignedAmount(t *testing.T) {
+	tests := []struct {
+		indicator string
+		want      float64
+	}{
+		{indicator: Debit, want: -10},
+		{indicator: Credit, want: 10},
+	}
+
+	for _, tt := range tests {
+		if got := (Entry{Amount: 10, Indicator: tt.indicator}).SignedAmount(); got != tt.want {
+			t.Errorf("SignedAmount of %s = %v, want %v", tt.indicator, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package category
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tree is a category hierarchy, e.g. Food with the children Groceries and Restaurants.
+// Names are matched ignoring case. The zero value is an empty tree.
+type Tree struct {
+	names   map[string]string
+	parents map[string]string
+}
+
+// New builds a tree from category -> parent pairs, an empty parent making a top-level category.
+// Parents must be categories themselves and the hierarchy can't contain cycles.
+func New(parents map[string]string) (*Tree, error) {
+	tree := &Tree{names: map[string]string{}, parents: map[string]string{}}
+	for name := range parents {
+		tree.names[key(name)] = name
+	}
+	for name, parent := range parents {
+		if parent == "" {
+			continue
+		}
+		if _, ok := tree.names[key(parent)]; !ok {
+			return nil, fmt.Errorf("parent %s of %s is not a category", parent, name)
+		}
+		tree.parents[key(name)] = key(parent)
+	}
+
+	for name := range tree.names {
+		seen := map[string]bool{}
+		for current := name; current != ""; current = tree.parents[current] {
+			if seen[current] {
+				return nil, fmt.Errorf("category %s is its own ancestor", tree.names[name])
+			}
+			seen[current] = true
+		}
+	}
+
+	return tree, nil
+}
+
+// Empty reports whether the tree has no category.
+func (t *Tree) Empty() bool {
+	return t == nil || len(t.names) == 0
+}
+
+// Lookup returns the name of category as written in the tree.
+func (t *Tree) Lookup(category string) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	name, ok := t.names[key(category)]
+	return name, ok
+}
+
+// Parent returns the parent of category, empty for top-level and unknown categories.
+func (t *Tree) Parent(category string) string {
+	if t == nil {
+		return ""
+	}
+	return t.names[t.parents[key(category)]]
+}
+
+// Path returns the ancestors of category followed by the category itself, e.g. [Food Groceries].
+// Unknown categories are their own path.
+func (t *Tree) Path(category string) []string {
+	name, ok := t.Lookup(category)
+	if !ok {
+		return []string{category}
+	}
+
+	path := []string{name}
+	for parent := t.Parent(name); parent != ""; parent = t.Parent(parent) {
+		path = append([]string{parent}, path...)
+	}
+	return path
+}
+
+// Under returns the ancestor of category, or category itself, that is a direct child of parent,
+// and whether category is parent or one of its descendants. An empty parent stands for the top level.
+func (t *Tree) Under(category, parent string) (string, bool) {
+	path := t.Path(category)
+	if parent == "" {
+		return path[0], true
+	}
+
+	for i, name := range path {
+		if !strings.EqualFold(name, parent) {
+			continue
+		}
+		if i == len(path)-1 {
+			return name, true
+		}
+		return path[i+1], true
+	}
+	return "", false
+}
+
+// Children returns the direct children of parent sorted by name, the top-level categories for an empty parent.
+func (t *Tree) Children(parent string) []string {
+	if t == nil {
+		return nil
+	}
+
+	var children []string
+	for k, name := range t.names {
+		if t.parents[k] == key(parent) {
+			children = append(children, name)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+func key(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,39 @@
+package chart
+
+import "image"
+
+// Bar renders a PNG bar chart with one bar per point, labelled below and valued above.
+func Bar(title string, data []Point) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrNoData
+	}
+
+	max := 0.0
+	for _, p := range data {
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	if max == 0 {
+		return nil, ErrNoData
+	}
+
+	c := newCanvas(title)
+	area := plotArea()
+	scale := c.axes(area, niceMax(max))
+
+	slot := area.Dx() / len(data)
+	barWidth := slot * 2 / 3
+	for i, p := range data {
+		x := area.Min.X + i*slot + (slot-barWidth)/2
+		top := area.Max.Y - int(p.Value*scale)
+		if p.Value > 0 {
+			c.rect(image.Rect(x, top, x+barWidth, area.Max.Y), paletteColor(0))
+			value := Compact(p.Value)
+			c.text(x+barWidth/2-textWidth(value)/2, top-4, value, colorText)
+		}
+		c.text(x+barWidth/2-textWidth(p.Label)/2, area.Max.Y+lineHeight, p.Label, colorText)
+	}
+
+	return c.encode()
+}
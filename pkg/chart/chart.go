@@ -0,0 +1,205 @@
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	width  = 800
+	height = 500
+
+	titleHeight = 40
+	margin      = 50
+	lineHeight  = 16
+)
+
+var ErrNoData = errors.New("no data to chart")
+
+var (
+	colorBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	colorText       = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	colorAxis       = color.RGBA{0x99, 0x99, 0x99, 0xff}
+	colorGrid       = color.RGBA{0xe5, 0xe5, 0xe5, 0xff}
+
+	// palette is used in order for slices, bars and series
+	palette = []color.RGBA{
+		{0x42, 0x85, 0xf4, 0xff},
+		{0xea, 0x43, 0x35, 0xff},
+		{0xfb, 0xbc, 0x05, 0xff},
+		{0x34, 0xa8, 0x53, 0xff},
+		{0xff, 0x6d, 0x01, 0xff},
+		{0x46, 0xbd, 0xc6, 0xff},
+		{0xab, 0x47, 0xbc, 0xff},
+		{0x7e, 0x57, 0xc2, 0xff},
+		{0x9e, 0x9e, 0x9e, 0xff},
+	}
+)
+
+// Point is a single labelled value, e.g. the spending of a category or a month.
+type Point struct {
+	Label string
+	Value float64
+}
+
+// Series is a named sequence of points drawn as one line.
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// canvas wraps an image with the drawing helpers shared by all charts.
+type canvas struct {
+	img *image.RGBA
+}
+
+func newCanvas(title string) *canvas {
+	c := &canvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+	draw.Draw(c.img, c.img.Bounds(), &image.Uniform{colorBackground}, image.Point{}, draw.Src)
+	c.text(width/2-textWidth(title)/2, titleHeight/2+lineHeight/2, title, colorText)
+	return c
+}
+
+func (c *canvas) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, fmt.Errorf("err png.Encode: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// text draws s with its baseline starting at (x, y).
+func (c *canvas) text(x, y int, s string, col color.Color) {
+	d := &font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(col),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+func (c *canvas) rect(r image.Rectangle, col color.Color) {
+	draw.Draw(c.img, r, &image.Uniform{col}, image.Point{}, draw.Src)
+}
+
+// line draws a line of the given thickness using Bresenham's algorithm.
+func (c *canvas) line(x0, y0, x1, y1, thickness int, col color.Color) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	e := dx + dy
+	half := thickness / 2
+
+	for {
+		c.rect(image.Rect(x0-half, y0-half, x0-half+thickness, y0-half+thickness), col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// legend draws colored boxes with labels, one per line, starting at (x, y).
+func (c *canvas) legend(x, y int, labels []string) {
+	for i, label := range labels {
+		top := y + i*(lineHeight+4)
+		c.rect(image.Rect(x, top, x+12, top+12), paletteColor(i))
+		c.text(x+18, top+11, label, colorText)
+	}
+}
+
+// plotArea returns the rectangle available for axes based charts.
+func plotArea() image.Rectangle {
+	return image.Rect(margin+30, titleHeight+10, width-margin, height-margin)
+}
+
+// axes draws the y axis with horizontal grid lines up to max and returns the scale in pixels per unit.
+func (c *canvas) axes(area image.Rectangle, max float64) float64 {
+	const ticks = 4
+	for i := 0; i <= ticks; i++ {
+		value := max * float64(i) / ticks
+		y := area.Max.Y - int(float64(area.Dy())*float64(i)/ticks)
+		c.line(area.Min.X, y, area.Max.X, y, 1, colorGrid)
+		label := Compact(value)
+		c.text(area.Min.X-textWidth(label)-6, y+4, label, colorText)
+	}
+	c.line(area.Min.X, area.Min.Y, area.Min.X, area.Max.Y, 1, colorAxis)
+	c.line(area.Min.X, area.Max.Y, area.Max.X, area.Max.Y, 1, colorAxis)
+
+	return float64(area.Dy()) / max
+}
+
+// Compact formats a value with a K/M/B suffix, e.g. 1250000 -> "1.3M".
+func Compact(value float64) string {
+	units := []struct {
+		size   float64
+		suffix string
+	}{{1e9, "B"}, {1e6, "M"}, {1e3, "K"}}
+
+	for _, unit := range units {
+		if math.Abs(value) >= unit.size {
+			return strconv.FormatFloat(value/unit.size, 'f', 1, 64) + unit.suffix
+		}
+	}
+
+	return strconv.FormatFloat(value, 'f', 0, 64)
+}
+
+// niceMax rounds max up to a value that gives readable axis ticks.
+func niceMax(max float64) float64 {
+	if max <= 0 {
+		return 1
+	}
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(max)))
+	for _, step := range []float64{1, 1.5, 2, 2.5, 3, 4, 5, 6, 8, 10} {
+		if step*magnitude >= max {
+			return step * magnitude
+		}
+	}
+
+	return 10 * magnitude
+}
+
+func paletteColor(i int) color.RGBA {
+	return palette[i%len(palette)]
+}
+
+func textWidth(s string) int {
+	return font.MeasureString(basicfont.Face7x13, s).Round()
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	if v < 0 {
+		return -1
+	}
+	return 1
+}
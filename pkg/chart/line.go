@@ -0,0 +1,59 @@
+package chart
+
+// maxXLabels limits how many x axis labels are drawn so they don't overlap.
+const maxXLabels = 16
+
+// Line renders a PNG line chart with one line per series.
+// All series share the x axis labels of the longest series.
+func Line(title string, series ...Series) ([]byte, error) {
+	points, max := 0, 0.0
+	for _, s := range series {
+		if len(s.Points) > points {
+			points = len(s.Points)
+		}
+		for _, p := range s.Points {
+			if p.Value > max {
+				max = p.Value
+			}
+		}
+	}
+	if points == 0 || max == 0 {
+		return nil, ErrNoData
+	}
+
+	c := newCanvas(title)
+	area := plotArea()
+	scale := c.axes(area, niceMax(max))
+
+	step := float64(area.Dx())
+	if points > 1 {
+		step = float64(area.Dx()) / float64(points-1)
+	}
+	x := func(i int) int { return area.Min.X + int(float64(i)*step) }
+	y := func(v float64) int { return area.Max.Y - int(v*scale) }
+
+	labelEvery := (points + maxXLabels - 1) / maxXLabels
+	var longest []Point
+	for _, s := range series {
+		if len(s.Points) == points {
+			longest = s.Points
+			break
+		}
+	}
+	for i, p := range longest {
+		if i%labelEvery == 0 {
+			c.text(x(i)-textWidth(p.Label)/2, area.Max.Y+lineHeight, p.Label, colorText)
+		}
+	}
+
+	names := make([]string, len(series))
+	for i, s := range series {
+		names[i] = s.Name
+		for j := 1; j < len(s.Points); j++ {
+			c.line(x(j-1), y(s.Points[j-1].Value), x(j), y(s.Points[j].Value), 3, paletteColor(i))
+		}
+	}
+	c.legend(area.Min.X+10, area.Min.Y, names)
+
+	return c.encode()
+}
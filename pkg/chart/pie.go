@@ -0,0 +1,86 @@
+package chart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/frasnym/go-expense-telebot/common"
+)
+
+// maxSlices is the number of slices drawn before the rest is grouped as "Other".
+const maxSlices = 8
+
+// Pie renders a PNG pie chart of the positive values of data, with a legend showing each share.
+func Pie(title string, data []Point) ([]byte, error) {
+	slices, total := pieSlices(data)
+	if total == 0 {
+		return nil, ErrNoData
+	}
+
+	c := newCanvas(title)
+
+	cx, cy := 250, titleHeight+(height-titleHeight)/2
+	radius := float64(height-titleHeight)/2 - 30
+
+	// Cumulative end angle of each slice, starting at 12 o'clock and going clockwise
+	ends := make([]float64, len(slices))
+	sum := 0.0
+	for i, s := range slices {
+		sum += s.Value
+		ends[i] = sum / total * 2 * math.Pi
+	}
+
+	for y := cy - int(radius); y <= cy+int(radius); y++ {
+		for x := cx - int(radius); x <= cx+int(radius); x++ {
+			dx, dy := float64(x-cx), float64(y-cy)
+			if dx*dx+dy*dy > radius*radius {
+				continue
+			}
+
+			angle := math.Atan2(dx, -dy)
+			if angle < 0 {
+				angle += 2 * math.Pi
+			}
+
+			i := sort.SearchFloat64s(ends, angle)
+			if i >= len(slices) {
+				i = len(slices) - 1
+			}
+			c.img.Set(x, y, paletteColor(i))
+		}
+	}
+
+	// The ASCII font has no ellipsis
+	labels := make([]string, len(slices))
+	for i, s := range slices {
+		labels[i] = fmt.Sprintf("%-16s %5.1f%% %8s", common.Truncate(s.Label, 16, "~"), s.Value/total*100, Compact(s.Value))
+	}
+	c.legend(cx+int(radius)+40, titleHeight+30, labels)
+
+	return c.encode()
+}
+
+// pieSlices keeps positive values sorted biggest first, grouping the tail as "Other".
+func pieSlices(data []Point) ([]Point, float64) {
+	var slices []Point
+	total := 0.0
+	for _, p := range data {
+		if p.Value > 0 {
+			slices = append(slices, p)
+			total += p.Value
+		}
+	}
+
+	sort.SliceStable(slices, func(i, j int) bool { return slices[i].Value > slices[j].Value })
+
+	if len(slices) > maxSlices {
+		other := Point{Label: "Other"}
+		for _, p := range slices[maxSlices-1:] {
+			other.Value += p.Value
+		}
+		slices = append(slices[:maxSlices-1], other)
+	}
+
+	return slices, total
+}
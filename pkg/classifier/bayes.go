@@ -0,0 +1,131 @@
+package classifier
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Suggestion is a category with the probability the model gives it.
+type Suggestion struct {
+	Category    string
+	Probability float64
+}
+
+// Model is a multinomial naive Bayes classifier over the words of notes and merchants.
+// The zero value is an empty model ready to train.
+type Model struct {
+	docs       int
+	classDocs  map[string]int
+	wordCounts map[string]map[string]int
+	classWords map[string]int
+	vocabulary map[string]bool
+}
+
+// Train adds a categorized text to the model.
+func (m *Model) Train(text, category string) {
+	tokens := Tokenize(text)
+	if len(tokens) == 0 || category == "" {
+		return
+	}
+
+	if m.classDocs == nil {
+		m.classDocs = map[string]int{}
+		m.wordCounts = map[string]map[string]int{}
+		m.classWords = map[string]int{}
+		m.vocabulary = map[string]bool{}
+	}
+
+	m.docs++
+	m.classDocs[category]++
+	if m.wordCounts[category] == nil {
+		m.wordCounts[category] = map[string]int{}
+	}
+	for _, token := range tokens {
+		m.wordCounts[category][token]++
+		m.classWords[category]++
+		m.vocabulary[token] = true
+	}
+}
+
+// Categories returns the known categories, the most frequent first.
+func (m *Model) Categories() []string {
+	categories := make([]string, 0, len(m.classDocs))
+	for category := range m.classDocs {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if m.classDocs[categories[i]] != m.classDocs[categories[j]] {
+			return m.classDocs[categories[i]] > m.classDocs[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	return categories
+}
+
+// Predict returns up to n categories for text, the most likely first.
+// Texts sharing no word with the training data get no suggestion.
+func (m *Model) Predict(text string, n int) []Suggestion {
+	var tokens []string
+	for _, token := range Tokenize(text) {
+		if m.vocabulary[token] {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 || m.docs == 0 {
+		return nil
+	}
+
+	// Log probabilities with Laplace smoothing
+	vocabulary := float64(len(m.vocabulary))
+	scores := make(map[string]float64, len(m.classDocs))
+	best := math.Inf(-1)
+	for category, docs := range m.classDocs {
+		score := math.Log(float64(docs) / float64(m.docs))
+		for _, token := range tokens {
+			count := float64(m.wordCounts[category][token])
+			score += math.Log((count + 1) / (float64(m.classWords[category]) + vocabulary))
+		}
+		scores[category] = score
+		best = math.Max(best, score)
+	}
+
+	// Normalize to probabilities
+	total := 0.0
+	for category, score := range scores {
+		scores[category] = math.Exp(score - best)
+		total += scores[category]
+	}
+
+	suggestions := make([]Suggestion, 0, len(scores))
+	for category, score := range scores {
+		suggestions = append(suggestions, Suggestion{Category: category, Probability: score / total})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Probability != suggestions[j].Probability {
+			return suggestions[i].Probability > suggestions[j].Probability
+		}
+		return suggestions[i].Category < suggestions[j].Category
+	})
+
+	if len(suggestions) > n {
+		suggestions = suggestions[:n]
+	}
+	return suggestions
+}
+
+// Tokenize splits text into lower case words, dropping numbers and single characters
+// which are mostly references and amounts rather than merchant names.
+func Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) < 2 || strings.IndexFunc(word, unicode.IsLetter) < 0 {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
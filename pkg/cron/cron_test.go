@@ -0,0 +1,90 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatalf("time.Parse: %v", err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from string
+		want string
+	}{
+		{name: "every minute", spec: "* * * * *", from: "2024-09-05 10:15", want: "2024-09-05 10:16"},
+		{name: "strictly after", spec: "30 2 * * *", from: "2024-09-05 02:30", want: "2024-09-06 02:30"},
+		{name: "every 15 minutes", spec: "*/15 * * * *", from: "2024-09-05 10:16", want: "2024-09-05 10:30"},
+		{name: "every 15 minutes across the hour", spec: "*/15 * * * *", from: "2024-09-05 10:45", want: "2024-09-05 11:00"},
+		{name: "every 6 hours", spec: "0 */6 * * *", from: "2024-09-05 19:00", want: "2024-09-06 00:00"},
+		{name: "stepped range", spec: "0 9-17/4 * * *", from: "2024-09-05 13:00", want: "2024-09-05 17:00"},
+		{name: "list", spec: "0 8,20 * * *", from: "2024-09-05 09:00", want: "2024-09-05 20:00"},
+		{name: "first of the month across the year", spec: "0 2 1 * *", from: "2024-12-15 00:00", want: "2025-01-01 02:00"},
+		{name: "31st skips short months", spec: "0 0 31 * *", from: "2024-09-01 00:00", want: "2024-10-31 00:00"},
+		{name: "leap day", spec: "0 0 29 2 *", from: "2024-03-01 00:00", want: "2028-02-29 00:00"},
+		{name: "monday across the month", spec: "0 9 * * 1", from: "2024-09-30 10:00", want: "2024-10-07 09:00"},
+		{name: "sunday as 7", spec: "0 9 * * 7", from: "2024-09-05 00:00", want: "2024-09-08 09:00"},
+		{name: "weekdays over the weekend", spec: "0 9 * * 1-5", from: "2024-09-06 10:00", want: "2024-09-09 09:00"},
+		{name: "day of month or day of week", spec: "0 0 15 * 1", from: "2024-09-10 00:00", want: "2024-09-15 00:00"},
+		{name: "day of week or day of month", spec: "0 0 15 * 1", from: "2024-09-15 00:00", want: "2024-09-16 00:00"},
+		{name: "every other month", spec: "0 0 1 */2 *", from: "2024-09-05 00:00", want: "2024-11-01 00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			// Seconds past the minute are truncated
+			got, err := schedule.Next(at(tt.from).Add(20 * time.Second))
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			if want := at(tt.want); !got.Equal(want) {
+				t.Errorf("Next(%s) of %q = %s, want %s", tt.from, tt.spec, got.Format("2006-01-02 15:04 Mon"), want.Format("2006-01-02 15:04 Mon"))
+			}
+		})
+	}
+}
+
+func TestNextNeverFires(t *testing.T) {
+	schedule, err := Parse("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, err := schedule.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrNoActivation) {
+		t.Errorf("Next error = %v, want ErrNoActivation", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
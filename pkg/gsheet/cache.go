@@ -0,0 +1,75 @@
+package gsheet
+
+import (
+	"sync"
+	"time"
+)
+
+// cache holds the values read from each spreadsheet, shared by every repository of the process like the rate limiters.
+var cache = struct {
+	mu sync.Mutex
+	// entries are keyed by spreadsheet, then by key
+	entries map[string]map[string]cacheEntry
+	// invalidated is when the tabs of each spreadsheet were last written, keyed by spreadsheet, then by tab
+	invalidated map[string]map[string]time.Time
+}{
+	entries:     map[string]map[string]cacheEntry{},
+	invalidated: map[string]map[string]time.Time{},
+}
+
+type cacheEntry struct {
+	value     any
+	sheetName string
+	readAt    time.Time
+}
+
+// Cached returns the value stored under key for spreadsheetID, unless it was read maxAge or longer ago.
+func Cached(spreadsheetID, key string, maxAge time.Duration) (any, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, ok := cache.entries[spreadsheetID][key]
+	if !ok || time.Since(entry.readAt) >= maxAge {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Store caches value under key for spreadsheetID. The value was read from the tab sheetName at readAt, an empty
+// sheetName stands for the spreadsheet itself, e.g. its list of tabs. Values read before the tab was last
+// invalidated are not stored, they may miss the write.
+func Store(spreadsheetID, key, sheetName string, value any, readAt time.Time) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if readAt.Before(cache.invalidated[spreadsheetID][sheetName]) {
+		return
+	}
+	if cache.entries[spreadsheetID] == nil {
+		cache.entries[spreadsheetID] = map[string]cacheEntry{}
+	}
+	cache.entries[spreadsheetID][key] = cacheEntry{value: value, sheetName: sheetName, readAt: readAt}
+}
+
+// Invalidate drops the values cached for the tabs sheetNames of spreadsheetID, to be called on every write.
+func Invalidate(spreadsheetID string, sheetNames ...string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.invalidated[spreadsheetID] == nil {
+		cache.invalidated[spreadsheetID] = map[string]time.Time{}
+	}
+	now := time.Now()
+	for _, sheetName := range sheetNames {
+		cache.invalidated[spreadsheetID][sheetName] = now
+	}
+
+	for key, entry := range cache.entries[spreadsheetID] {
+		for _, sheetName := range sheetNames {
+			if entry.sheetName == sheetName {
+				delete(cache.entries[spreadsheetID], key)
+				break
+			}
+		}
+	}
+}
@@ -6,15 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sync"
 
 	"github.com/frasnym/go-expense-telebot/config"
 	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
 )
 
-var sheetService *sheets.Service
+var (
+	sheetService *sheets.Service
+	initOnce     sync.Once
+)
 
-func init() {
+// initService connects to Google Sheets. It runs on first use so the bot can run on local storage without credentials.
+func initService() {
 	cfg := config.GetConfig()
 
 	sheetKey := Key{
@@ -45,6 +50,7 @@ func init() {
 }
 
 func GetService() *sheets.Service {
+	initOnce.Do(initService)
 	if sheetService == nil {
 		panic(errors.New("please init gsheet service first"))
 	}
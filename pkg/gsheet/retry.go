@@ -0,0 +1,135 @@
+package gsheet
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Retry settings of Do. The delays double from retryBaseDelay up to retryMaxDelay, with full jitter.
+const (
+	retryAttempts  = 6
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+
+	// limiterBurst is the number of calls allowed at once before the rate limit applies
+	limiterBurst = 10
+)
+
+// limiters holds the rate limiter of each spreadsheet, shared by every repository of the process.
+var limiters sync.Map
+
+// limiter is a token bucket refilled at rate tokens per second.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.last.IsZero() {
+		l.tokens = limiterBurst
+	} else {
+		l.tokens = math.Min(limiterBurst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Do calls fn once the rate limit of spreadsheetID, in calls per minute, allows it, and retries fn with exponential
+// backoff while it fails with a rate limit (429) or server (5xx) error. A Retry-After sent by the API is honored.
+// fn must be safe to repeat, e.g. a read or a write of fixed cells, a server error may come after it was applied.
+func Do(ctx context.Context, spreadsheetID string, perMinute int, fn func() error) error {
+	return do(ctx, spreadsheetID, perMinute, retryable, fn)
+}
+
+// DoOnce is Do for calls that must not be applied twice, such as appending or deleting rows. It only retries
+// rate limit errors, which reject the call before it runs.
+func DoOnce(ctx context.Context, spreadsheetID string, perMinute int, fn func() error) error {
+	return do(ctx, spreadsheetID, perMinute, rateLimited, fn)
+}
+
+func do(ctx context.Context, spreadsheetID string, perMinute int, retry func(error) bool, fn func() error) error {
+	value, _ := limiters.LoadOrStore(spreadsheetID, &limiter{rate: float64(perMinute) / 60})
+	l := value.(*limiter)
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err := sleep(ctx, l.reserve(time.Now())); err != nil {
+			return err
+		}
+
+		err = fn()
+		if err == nil || !retry(err) || attempt == retryAttempts-1 {
+			return err
+		}
+
+		if err := sleep(ctx, backoff(attempt, err)); err != nil {
+			return err
+		}
+	}
+}
+
+// retryable reports whether err is a rate limit or server error of the Sheets API.
+func retryable(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= http.StatusInternalServerError
+}
+
+// rateLimited reports whether err is a rate limit error of the Sheets API.
+func rateLimited(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests
+}
+
+// backoff returns the delay before retrying after the attempt-th failure with err.
+func backoff(attempt int, err error) time.Duration {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Header != nil {
+		if seconds, errParse := strconv.Atoi(apiErr.Header.Get("Retry-After")); errParse == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	ceiling := retryBaseDelay << attempt
+	if ceiling > retryMaxDelay {
+		ceiling = retryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,221 @@
+package ledger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/frasnym/go-expense-telebot/model"
+)
+
+// Format is a plain-text accounting journal format.
+type Format string
+
+const (
+	FormatLedger    Format = "ledger"
+	FormatHledger   Format = "hledger"
+	FormatBeancount Format = "beancount"
+)
+
+const (
+	defaultExpenseRoot = "Expenses"
+	defaultAssetRoot   = "Assets"
+	defaultWallet      = "Cash"
+	defaultCategory    = "Uncategorized"
+)
+
+// Accounts maps categories and wallets to journal accounts.
+// Unmapped categories become "Expenses:<Category>" and unmapped wallets "Assets:<Wallet>".
+type Accounts struct {
+	Categories      map[string]string
+	Wallets         map[string]string
+	DefaultCurrency string
+}
+
+// Extension returns the conventional file extension of the format.
+func (f Format) Extension() string {
+	switch f {
+	case FormatBeancount:
+		return "beancount"
+	case FormatHledger:
+		return "journal"
+	default:
+		return "ledger"
+	}
+}
+
+// ParseFormat validates a format name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatLedger, FormatHledger, FormatBeancount:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("unknown journal format: %s", s)
+}
+
+// Write renders expenses as a journal in the given format. Each expense becomes a transaction
+// moving its amount from the wallet account to the category account, tagged with its labels.
+func Write(w io.Writer, format Format, accounts Accounts, expenses []model.Expense) error {
+	bw := bufio.NewWriter(w)
+
+	if format == FormatBeancount {
+		writeBeancountOpens(bw, accounts, expenses)
+	}
+
+	for _, expense := range expenses {
+		expenseAccount := accounts.category(expense.Category)
+		assetAccount := accounts.wallet(expense.Wallet)
+		amount := fmt.Sprintf("%s %s", strconv.FormatFloat(expense.Amount, 'f', 2, 64), accounts.currency(expense.Currency))
+		tags := tags(expense.Label)
+		date := expense.Date.Format("2006-01-02")
+
+		switch format {
+		case FormatBeancount:
+			fmt.Fprintf(bw, "%s * %q", date, narration(expense))
+			for _, tag := range tags {
+				fmt.Fprintf(bw, " #%s", tag)
+			}
+			fmt.Fprintf(bw, "\n  %-40s %s\n  %s\n\n", expenseAccount, amount, assetAccount)
+
+		case FormatHledger:
+			fmt.Fprintf(bw, "%s * %s", date, narration(expense))
+			if len(tags) > 0 {
+				fmt.Fprintf(bw, "  ; %s:", strings.Join(tags, ":, "))
+			}
+			fmt.Fprintf(bw, "\n    %-40s %s\n    %s\n\n", expenseAccount, amount, assetAccount)
+
+		default:
+			fmt.Fprintf(bw, "%s * %s\n", date, narration(expense))
+			if len(tags) > 0 {
+				fmt.Fprintf(bw, "    ; :%s:\n", strings.Join(tags, ":"))
+			}
+			fmt.Fprintf(bw, "    %-40s %s\n    %s\n\n", expenseAccount, amount, assetAccount)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("err bw.Flush: %w", err)
+	}
+
+	return nil
+}
+
+// writeBeancountOpens declares every account used, dated at its first use, as beancount requires.
+func writeBeancountOpens(w io.Writer, accounts Accounts, expenses []model.Expense) {
+	opened := map[string]time.Time{}
+	for _, expense := range expenses {
+		for _, account := range []string{accounts.category(expense.Category), accounts.wallet(expense.Wallet)} {
+			if first, ok := opened[account]; !ok || expense.Date.Before(first) {
+				opened[account] = expense.Date
+			}
+		}
+	}
+
+	names := make([]string, 0, len(opened))
+	for name := range opened {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%s open %s\n", opened[name].Format("2006-01-02"), name)
+	}
+	if len(names) > 0 {
+		fmt.Fprintln(w)
+	}
+}
+
+func (a Accounts) category(category string) string {
+	if account, ok := lookup(a.Categories, category); ok {
+		return account
+	}
+	if category == "" {
+		category = defaultCategory
+	}
+	return defaultExpenseRoot + ":" + AccountComponent(category)
+}
+
+func (a Accounts) wallet(wallet string) string {
+	if account, ok := lookup(a.Wallets, wallet); ok {
+		return account
+	}
+	if wallet == "" {
+		wallet = defaultWallet
+	}
+	return defaultAssetRoot + ":" + AccountComponent(wallet)
+}
+
+func (a Accounts) currency(currency string) string {
+	if currency == "" {
+		currency = a.DefaultCurrency
+	}
+	return strings.ToUpper(currency)
+}
+
+// lookup finds name in mapping ignoring case.
+func lookup(mapping map[string]string, name string) (string, bool) {
+	for k, v := range mapping {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// AccountComponent turns a free-form name into a valid account component, e.g. "food & drink" -> "FoodDrink".
+func AccountComponent(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		runes := []rune(word)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+
+	component := b.String()
+	if component == "" {
+		return defaultCategory
+	}
+	// Beancount components must start with a capital letter
+	if !unicode.IsUpper([]rune(component)[0]) {
+		component = "X" + component
+	}
+
+	return component
+}
+
+// tags converts a comma separated label cell to tag names without spaces or separators.
+func tags(label string) []string {
+	var result []string
+	for _, l := range strings.Split(label, ",") {
+		tag := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+				return r
+			}
+			if unicode.IsSpace(r) {
+				return '-'
+			}
+			return -1
+		}, strings.ToLower(strings.TrimSpace(l)))
+
+		if tag != "" {
+			result = append(result, tag)
+		}
+	}
+
+	return result
+}
+
+// narration describes the transaction, falling back to the category when it has no note.
+func narration(expense model.Expense) string {
+	text := strings.Join(strings.Fields(expense.Note), " ")
+	if text == "" {
+		text = expense.Category
+	}
+	return text
+}
@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// filePattern matches migration files such as "0001_create_expenses.sql", the number being the version.
+var filePattern = regexp.MustCompile(`^(\d+)_[\w-]+\.sql$`)
+
+// Migration is a versioned SQL script.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Load reads the migrations of the root directory of migrations, ordered by version.
+// Files not named like a migration are ignored.
+func Load(migrations fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrations, ".")
+	if err != nil {
+		return nil, fmt.Errorf("err fs.ReadDir: %w", err)
+	}
+
+	var list []Migration
+	seen := map[int]string{}
+	for _, entry := range entries {
+		match := filePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+
+		content, err := fs.ReadFile(migrations, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("err fs.ReadFile: %w", err)
+		}
+		list = append(list, Migration{Version: version, Name: entry.Name(), SQL: string(content)})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Up applies the migrations newer than the current schema version, each in its own transaction,
+// and returns the names of the applied ones. Applied versions are recorded in the schema_migrations table.
+func Up(ctx context.Context, db *sql.DB, migrations []Migration) ([]string, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("err create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return nil, fmt.Errorf("err select version: %w", err)
+	}
+
+	var applied []string
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+		if err := apply(ctx, db, migration); err != nil {
+			return applied, fmt.Errorf("err apply %s: %w", migration.Name, err)
+		}
+		applied = append(applied, migration.Name)
+	}
+
+	return applied, nil
+}
+
+func apply(ctx context.Context, db *sql.DB, migration Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("err db.BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Concurrent instances wait for each other, the loser finds the version already recorded
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE schema_migrations IN EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("err lock schema_migrations: %w", err)
+	}
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, migration.Version).Scan(&exists); err != nil {
+		return fmt.Errorf("err select version: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+		return fmt.Errorf("err exec: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, migration.Version); err != nil {
+		return fmt.Errorf("err insert version: %w", err)
+	}
+
+	return tx.Commit()
+}
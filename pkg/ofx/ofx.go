@@ -0,0 +1,163 @@
+package ofx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotOFX = errors.New("not an OFX document")
+
+// Transaction is a single STMTTRN entry together with the account of its statement.
+type Transaction struct {
+	Type     string
+	Posted   time.Time
+	Amount   float64
+	FITID    string
+	Name     string
+	Memo     string
+	BankID   string
+	Account  string
+	Currency string
+}
+
+// Parse reads the transactions of every statement in an OFX or QFX document.
+// Both the SGML based 1.x format, where leaf elements have no closing tag, and the XML based 2.x format are supported.
+func Parse(r io.Reader) ([]Transaction, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("err io.ReadAll: %w", err)
+	}
+
+	// Skip the OFX headers, which are either "KEY:VALUE" lines or XML processing instructions
+	body := string(content)
+	start := strings.Index(strings.ToUpper(body), "<OFX>")
+	if start < 0 {
+		return nil, ErrNotOFX
+	}
+	body = body[start:]
+
+	var (
+		transactions []Transaction
+		current      *Transaction
+		bankID       string
+		account      string
+		currency     string
+	)
+
+	for _, t := range tokenize(body) {
+		switch {
+		case t.name == "STMTTRN" && !t.closing:
+			current = &Transaction{BankID: bankID, Account: account, Currency: currency}
+		case t.name == "STMTTRN" && t.closing:
+			if current != nil {
+				transactions = append(transactions, *current)
+			}
+			current = nil
+		case (t.name == "STMTRS" || t.name == "CCSTMTRS") && !t.closing:
+			// Each statement has its own account
+			bankID, account, currency = "", "", ""
+		case t.closing:
+			// Closing tags of aggregates carry no value
+		case current != nil:
+			// Accounts within a transaction, e.g. the BANKACCTTO of a transfer, are not the statement's
+			if err := current.set(t.name, t.value); err != nil {
+				return nil, fmt.Errorf("transaction %s: %w", current.FITID, err)
+			}
+		case t.name == "CURDEF":
+			currency = t.value
+		case t.name == "BANKID":
+			bankID = t.value
+		case t.name == "ACCTID":
+			account = t.value
+		}
+	}
+
+	return transactions, nil
+}
+
+// set assigns a STMTTRN element to the transaction.
+func (t *Transaction) set(name, value string) error {
+	switch name {
+	case "TRNTYPE":
+		t.Type = value
+	case "DTPOSTED":
+		posted, err := ParseDate(value)
+		if err != nil {
+			return err
+		}
+		t.Posted = posted
+	case "TRNAMT":
+		// Some banks use a decimal comma
+		amount, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil {
+			return fmt.Errorf("invalid TRNAMT %q: %w", value, err)
+		}
+		t.Amount = amount
+	case "FITID":
+		t.FITID = value
+	case "NAME":
+		t.Name = value
+	case "MEMO":
+		t.Memo = value
+	case "CURSYM":
+		// Part of the CURRENCY or ORIGCURRENCY aggregate, overriding the statement currency
+		t.Currency = value
+	}
+
+	return nil
+}
+
+// ParseDate parses an OFX datetime such as "20230912", "20230912101500" or "20230912101500.000[+7:WIB]".
+// The timezone bracket is ignored.
+func ParseDate(value string) (time.Time, error) {
+	if idx := strings.IndexAny(value, ".["); idx >= 0 {
+		value = value[:idx]
+	}
+
+	switch len(value) {
+	case 8:
+		return time.Parse("20060102", value)
+	case 12:
+		return time.Parse("200601021504", value)
+	case 14:
+		return time.Parse("20060102150405", value)
+	}
+
+	return time.Time{}, fmt.Errorf("invalid OFX date: %s", value)
+}
+
+type token struct {
+	name    string
+	value   string
+	closing bool
+}
+
+// tokenize splits an OFX body into tags with the text following each of them.
+func tokenize(body string) []token {
+	var tokens []token
+	for _, part := range strings.Split(body, "<")[1:] {
+		end := strings.Index(part, ">")
+		if end < 0 {
+			continue
+		}
+
+		name := strings.ToUpper(strings.TrimSpace(part[:end]))
+		t := token{name: name, value: unescape(strings.TrimSpace(part[end+1:]))}
+		if strings.HasPrefix(name, "/") {
+			t.name, t.closing = name[1:], true
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens
+}
+
+var unescaper = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&", "&apos;", "'", "&quot;", `"`)
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
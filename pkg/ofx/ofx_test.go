@@ -0,0 +1,129 @@
+package ofx
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "date", value: "20230912", want: time.Date(2023, 9, 12, 0, 0, 0, 0, time.UTC)},
+		{name: "minutes", value: "202309121015", want: time.Date(2023, 9, 12, 10, 15, 0, 0, time.UTC)},
+		{name: "seconds", value: "20230912101530", want: time.Date(2023, 9, 12, 10, 15, 30, 0, time.UTC)},
+		{name: "milliseconds and zone", value: "20230912101530.000[+7:WIB]", want: time.Date(2023, 9, 12, 10, 15, 30, 0, time.UTC)},
+		{name: "zone only", value: "20230912[-5:EST]", want: time.Date(2023, 9, 12, 0, 0, 0, 0, time.UTC)},
+		{name: "too short", value: "202309", wantErr: true},
+		{name: "not a date", value: "2023091x", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDate(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("ParseDate(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>IDR
+<BANKACCTFROM><BANKID>014<ACCTID>1234567890<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>XFER
+<DTPOSTED>20230912
+<TRNAMT>-500000,00
+<FITID>T1
+<NAME>Transfer to savings
+<BANKACCTTO><BANKID>008<ACCTID>9999<ACCTTYPE>SAVINGS</BANKACCTTO>
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20230913101500.000[+7:WIB]
+<TRNAMT>-25000
+<FITID>T2
+<NAME>Coffee &amp; cake
+<MEMO>card 1234
+<CURRENCY><CURRATE>1<CURSYM>USD</CURRENCY>
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>`
+
+const xmlStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX>
+<CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+<CURDEF>USD</CURDEF>
+<CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>CREDIT</TRNTYPE>
+<DTPOSTED>20230914</DTPOSTED>
+<TRNAMT>10.5</TRNAMT>
+<FITID>C1</FITID>
+<NAME>Refund</NAME>
+<CCACCTTO><ACCTID>5555</ACCTID></CCACCTTO>
+</STMTTRN>
+</BANKTRANLIST>
+</CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Transaction
+		wantErr error
+	}{
+		{
+			name:  "SGML with a transfer target account",
+			input: sgmlStatement,
+			want: []Transaction{
+				{Type: "XFER", Posted: time.Date(2023, 9, 12, 0, 0, 0, 0, time.UTC), Amount: -500000, FITID: "T1", Name: "Transfer to savings", BankID: "014", Account: "1234567890", Currency: "IDR"},
+				{Type: "DEBIT", Posted: time.Date(2023, 9, 13, 10, 15, 0, 0, time.UTC), Amount: -25000, FITID: "T2", Name: "Coffee & cake", Memo: "card 1234", BankID: "014", Account: "1234567890", Currency: "USD"},
+			},
+		},
+		{
+			name:  "XML credit card with a transfer target account",
+			input: xmlStatement,
+			want: []Transaction{
+				{Type: "CREDIT", Posted: time.Date(2023, 9, 14, 0, 0, 0, 0, time.UTC), Amount: 10.5, FITID: "C1", Name: "Refund", Account: "4111", Currency: "USD"},
+			},
+		},
+		{
+			name:    "not OFX",
+			input:   "date,amount\n2023-09-12,100\n",
+			wantErr: ErrNotOFX,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input))
+			if err != tt.wantErr {
+				t.Fatalf("Parse error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rule assigns a category and labels to transactions matching all of its conditions.
+// An empty condition always matches.
+type Rule struct {
+	Name      string
+	Match     string
+	MinAmount float64
+	MaxAmount float64
+	Wallet    string
+	Category  string
+	Labels    []string
+
+	pattern *regexp.Regexp
+}
+
+// New builds a rule from its text form. match is a case-insensitive substring of the note,
+// or a regular expression when written between slashes, e.g. /^(grab|gojek)/.
+// min and max bound the amount inclusively, labels is comma separated.
+func New(name, match, min, max, wallet, category, labels string) (Rule, error) {
+	rule := Rule{
+		Name:      name,
+		Match:     strings.TrimSpace(match),
+		MinAmount: math.Inf(-1),
+		MaxAmount: math.Inf(1),
+		Wallet:    strings.TrimSpace(wallet),
+		Category:  strings.TrimSpace(category),
+	}
+
+	if len(rule.Match) > 1 && strings.HasPrefix(rule.Match, "/") && strings.HasSuffix(rule.Match, "/") {
+		pattern, err := regexp.Compile("(?i)" + rule.Match[1:len(rule.Match)-1])
+		if err != nil {
+			return Rule{}, fmt.Errorf("err regexp.Compile: %w", err)
+		}
+		rule.pattern = pattern
+	}
+
+	var err error
+	if rule.MinAmount, err = parseBound(min, rule.MinAmount); err != nil {
+		return Rule{}, fmt.Errorf("invalid min amount %q: %w", min, err)
+	}
+	if rule.MaxAmount, err = parseBound(max, rule.MaxAmount); err != nil {
+		return Rule{}, fmt.Errorf("invalid max amount %q: %w", max, err)
+	}
+
+	for _, label := range strings.Split(labels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			rule.Labels = append(rule.Labels, label)
+		}
+	}
+
+	if rule.Category == "" && len(rule.Labels) == 0 {
+		return Rule{}, fmt.Errorf("rule %s assigns neither category nor labels", name)
+	}
+
+	return rule, nil
+}
+
+// Matches reports whether a transaction with the given note, amount and wallet satisfies the rule.
+func (r Rule) Matches(note string, amount float64, wallet string) bool {
+	if amount < r.MinAmount || amount > r.MaxAmount {
+		return false
+	}
+	if r.Wallet != "" && !strings.EqualFold(r.Wallet, strings.TrimSpace(wallet)) {
+		return false
+	}
+
+	switch {
+	case r.Match == "":
+		return true
+	case r.pattern != nil:
+		return r.pattern.MatchString(note)
+	default:
+		return strings.Contains(strings.ToLower(note), strings.ToLower(r.Match))
+	}
+}
+
+// First returns the first rule matching the transaction.
+func First(rules []Rule, note string, amount float64, wallet string) (Rule, bool) {
+	for _, rule := range rules {
+		if rule.Matches(note, amount, wallet) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func parseBound(s string, fallback float64) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return fallback, nil
+	}
+	s = strings.ReplaceAll(s, ",", "")
+	return strconv.ParseFloat(s, 64)
+}
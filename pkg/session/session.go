@@ -54,6 +54,20 @@ func ResetTimer(userID int) error {
 	return nil
 }
 
+// ExtendTimer resets the session timer for a user's session and lets it run for timeout,
+// for steps waiting on the user to pick or type an answer.
+func ExtendTimer(userID int, timeout time.Duration) error {
+	session, exist := getUserSession(userID)
+	if !exist {
+		return common.ErrNoSession
+	}
+	session.StartTime = time.Now() // Renew the timer
+	session.Timeout = timeout
+	setUserSession(userID, session)
+
+	return nil
+}
+
 // GetAction retrieves the current action for a user's session.
 func GetAction(userID int) (string, error) {
 	session, exist := getUserSession(userID)
@@ -64,6 +78,35 @@ func GetAction(userID int) (string, error) {
 	return session.Action, nil
 }
 
+// SetData stores a value in a user's session, e.g. an option given with the command starting it.
+func SetData(userID int, key, value string) error {
+	session, exist := getUserSession(userID)
+	if !exist {
+		return common.ErrNoSession
+	}
+
+	// Copy so readers holding the previous map aren't affected
+	data := make(map[string]string, len(session.Data)+1)
+	for k, v := range session.Data {
+		data[k] = v
+	}
+	data[key] = value
+	session.Data = data
+	setUserSession(userID, session)
+
+	return nil
+}
+
+// GetData retrieves a value stored in a user's session, empty when not set.
+func GetData(userID int, key string) (string, error) {
+	session, exist := getUserSession(userID)
+	if !exist {
+		return "", common.ErrNoSession
+	}
+
+	return session.Data[key], nil
+}
+
 // GetMessageID retrieves the message ID for a user's session.
 func GetMessageID(userID int) (int, error) {
 	session, exist := getUserSession(userID)
@@ -92,6 +135,22 @@ func DeleteUserSession(userID int) {
 	delete(userSessions, userID)
 }
 
+// SweepExpired deletes every timed out session and returns how many were removed.
+func SweepExpired() int {
+	userSessionMutex.Lock()
+	defer userSessionMutex.Unlock()
+
+	swept := 0
+	for userID, session := range userSessions {
+		if time.Since(session.StartTime) > timeout(session) {
+			delete(userSessions, userID)
+			swept++
+		}
+	}
+
+	return swept
+}
+
 // setUserSession sets the user's session data in a thread-safe manner.
 func setUserSession(userID int, newSession *model.Session) {
 	userSessionMutex.Lock()
@@ -121,5 +180,13 @@ func IsInteractionTimedOut(userID int) bool {
 	}
 
 	elapsed := time.Since(session.StartTime)
-	return elapsed > common.SessionTimeout
+	return elapsed > timeout(*session)
+}
+
+// timeout returns how long a session lasts without interaction.
+func timeout(session model.Session) time.Duration {
+	if session.Timeout > 0 {
+		return session.Timeout
+	}
+	return common.SessionTimeout
 }
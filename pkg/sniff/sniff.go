@@ -0,0 +1,184 @@
+package sniff
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Kind is the format of an uploaded document as detected from its content.
+type Kind int
+
+const (
+	Unknown Kind = iota
+	CSV
+	XLSX
+	XLS
+	ZIP
+	OFX
+	Camt
+	XML
+)
+
+func (k Kind) String() string {
+	switch k {
+	case CSV:
+		return "CSV"
+	case XLSX:
+		return "XLSX"
+	case XLS:
+		return "XLS"
+	case ZIP:
+		return "ZIP"
+	case OFX:
+		return "OFX"
+	case Camt:
+		return "camt.053"
+	case XML:
+		return "XML"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	zipMagic = []byte("PK\x03\x04")
+	// xlsMagic is the header of OLE2 compound documents, used by legacy Excel workbooks
+	xlsMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+
+	// delimiters are the CSV delimiters tried, in order of preference on a tie
+	delimiters = []rune{',', ';', '\t', '|'}
+)
+
+// headSize is the amount of text looked at to recognize text formats and CSV delimiters.
+const headSize = 8 << 10
+
+// Detect returns the kind of content.
+func Detect(content []byte) Kind {
+	switch {
+	case bytes.HasPrefix(content, zipMagic):
+		if isWorkbook(content) {
+			return XLSX
+		}
+		return ZIP
+	case bytes.HasPrefix(content, xlsMagic):
+		return XLS
+	}
+
+	text := Text(content)
+	head := text
+	if len(head) > headSize {
+		head = head[:headSize]
+	}
+	if strings.ContainsRune(head, 0) {
+		return Unknown
+	}
+
+	trimmed := strings.TrimSpace(head)
+	upper := strings.ToUpper(trimmed)
+	switch {
+	case strings.HasPrefix(upper, "OFXHEADER") || strings.Contains(upper, "<OFX>"):
+		return OFX
+	case strings.HasPrefix(trimmed, "<"):
+		if strings.Contains(trimmed, "camt.053") || strings.Contains(trimmed, "<BkToCstmrStmt>") {
+			return Camt
+		}
+		return XML
+	}
+
+	if _, ok := delimiter(head); ok {
+		return CSV
+	}
+
+	return Unknown
+}
+
+// Text decodes textual content to UTF-8. A byte order mark selects UTF-8 or UTF-16 and is removed,
+// content that is not valid UTF-8 is read as Latin-1, which is what spreadsheet apps commonly export.
+func Text(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, utf8BOM):
+		return string(content[len(utf8BOM):])
+	case bytes.HasPrefix(content, utf16LEBOM):
+		return decodeUTF16(content[len(utf16LEBOM):], binary.LittleEndian)
+	case bytes.HasPrefix(content, utf16BEBOM):
+		return decodeUTF16(content[len(utf16BEBOM):], binary.BigEndian)
+	case utf8.Valid(content):
+		return string(content)
+	}
+
+	runes := make([]rune, len(content))
+	for i, b := range content {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// Delimiter returns the field delimiter of CSV text, defaulting to a comma.
+func Delimiter(text string) rune {
+	if len(text) > headSize {
+		text = text[:headSize]
+	}
+	if d, ok := delimiter(text); ok {
+		return d
+	}
+	return ','
+}
+
+// delimiter picks the delimiter splitting the first lines of text into the same number of fields,
+// preferring the one producing the most fields.
+func delimiter(text string) (rune, bool) {
+	// Drop the last line, it may be cut short by the head limit
+	if i := strings.LastIndexByte(text, '\n'); i > 0 && i < len(text)-1 {
+		text = text[:i]
+	}
+
+	var best rune
+	bestFields := 1
+	for _, d := range delimiters {
+		reader := csv.NewReader(strings.NewReader(text))
+		reader.Comma = d
+		reader.LazyQuotes = true
+
+		records, err := reader.ReadAll()
+		if err != nil || len(records) == 0 {
+			continue
+		}
+		fields := len(records[0])
+		if fields > bestFields {
+			best, bestFields = d, fields
+		}
+	}
+
+	return best, best != 0
+}
+
+// isWorkbook reports whether the zip archive is an Excel workbook.
+func isWorkbook(content []byte) bool {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return false
+	}
+	for _, f := range archive.File {
+		if f.Name == "xl/workbook.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeUTF16(content []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		units[i] = order.Uint16(content[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
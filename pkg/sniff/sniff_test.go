@@ -0,0 +1,108 @@
+package sniff
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+// archive returns a zip archive holding empty files named names.
+func archive(t *testing.T, names ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		if _, err := zw.Create(name); err != nil {
+			t.Fatalf("zw.Create: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zw.Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    Kind
+	}{
+		{name: "xlsx workbook", content: archive(t, "[Content_Types].xml", "xl/workbook.xml"), want: XLSX},
+		{name: "other zip", content: archive(t, "statement.csv"), want: ZIP},
+		{name: "legacy xls", content: append([]byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, make([]byte, 16)...), want: XLS},
+		{name: "ofx sgml", content: []byte("OFXHEADER:100\nDATA:OFXSGML\n\n<OFX>\n"), want: OFX},
+		{name: "ofx xml", content: []byte(`<?xml version="1.0"?><?OFX OFXHEADER="200"?><ofx>`), want: OFX},
+		{name: "camt", content: []byte(`<?xml version="1.0"?><Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">`), want: Camt},
+		{name: "other xml", content: []byte(`<?xml version="1.0"?><feed></feed>`), want: XML},
+		{name: "csv", content: []byte("date,amount\n2024-09-05,100\n"), want: CSV},
+		{name: "csv with a bom", content: append([]byte{0xEF, 0xBB, 0xBF}, "date;amount\n2024-09-05;100\n"...), want: CSV},
+		{name: "utf-16 csv", content: utf16LE("date\tamount\r\n2024-09-05\t100\r\n"), want: CSV},
+		{name: "single column", content: []byte("hello\nworld\n"), want: Unknown},
+		{name: "binary", content: []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0}, want: Unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.content); got != tt.want {
+				t.Errorf("Detect = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// utf16LE encodes ASCII s as UTF-16 little endian with its byte order mark.
+func utf16LE(s string) []byte {
+	b := []byte{0xFF, 0xFE}
+	for _, r := range s {
+		b = append(b, byte(r), 0)
+	}
+	return b
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{name: "utf-8", content: []byte("café"), want: "café"},
+		{name: "utf-8 bom", content: append([]byte{0xEF, 0xBB, 0xBF}, "a,b"...), want: "a,b"},
+		{name: "utf-16 le bom", content: utf16LE("a;b"), want: "a;b"},
+		{name: "utf-16 be bom", content: []byte{0xFE, 0xFF, 0, 'a', 0, ';', 0, 'b'}, want: "a;b"},
+		{name: "latin-1", content: []byte{'c', 'a', 'f', 0xE9}, want: "café"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.content); got != tt.want {
+				t.Errorf("Text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want rune
+	}{
+		{name: "comma", text: "date,amount,note\n2024-09-05,100,coffee\n", want: ','},
+		{name: "semicolon with decimal commas", text: "date;amount\n2024-09-05;100,50\n", want: ';'},
+		{name: "tab", text: "date\tamount\tnote\n2024-09-05\t100\tcoffee\n", want: '\t'},
+		{name: "pipe", text: "date|amount\n2024-09-05|100\n", want: '|'},
+		{name: "quoted delimiters", text: "\"a;b\",c\n\"d;e\",f\n", want: ','},
+		{name: "last line cut short", text: "a;b;c\n1;2;3\n4;\"5", want: ';'},
+		{name: "single column defaults to a comma", text: "hello\nworld\n", want: ','},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Delimiter(tt.text); got != tt.want {
+				t.Errorf("Delimiter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrSheetNotFound = errors.New("sheet not found")
+
+// excelEpoch is day zero of the 1900 date system, shifted for Excel's fictitious 1900-02-29.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// Workbook is an opened XLSX file.
+type Workbook struct {
+	files        map[string]*zip.File
+	sheets       []sheetRef
+	sharedString []string
+	dateStyles   map[int]bool
+}
+
+type sheetRef struct {
+	name string
+	path string
+}
+
+// Open reads the workbook structure, shared strings and styles of an XLSX file.
+func Open(content []byte) (*Workbook, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("err zip.NewReader: %w", err)
+	}
+
+	wb := &Workbook{files: map[string]*zip.File{}, dateStyles: map[int]bool{}}
+	for _, f := range zr.File {
+		wb.files[f.Name] = f
+	}
+
+	if err := wb.readSheets(); err != nil {
+		return nil, err
+	}
+	if err := wb.readSharedStrings(); err != nil {
+		return nil, err
+	}
+	if err := wb.readStyles(); err != nil {
+		return nil, err
+	}
+
+	return wb, nil
+}
+
+// Sheets returns the worksheet names in workbook order.
+func (wb *Workbook) Sheets() []string {
+	names := make([]string, len(wb.sheets))
+	for i, s := range wb.sheets {
+		names[i] = s.name
+	}
+	return names
+}
+
+// Rows returns the cell values of the sheet at index as text, with missing cells as empty strings.
+// Rows missing from the sheet are left out, the way CSV readers skip blank lines.
+// Date formatted numbers are returned in RFC 3339.
+func (wb *Workbook) Rows(index int) ([][]string, error) {
+	if index < 0 || index >= len(wb.sheets) {
+		return nil, ErrSheetNotFound
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string       `xml:"r,attr"`
+				Type   string       `xml:"t,attr"`
+				Style  int          `xml:"s,attr"`
+				Value  string       `xml:"v"`
+				Inline inlineString `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := wb.decode(wb.sheets[index].path, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, r := range sheet.Rows {
+		var row []string
+		for i, c := range r.Cells {
+			col := i
+			if c.Ref != "" {
+				col = columnIndex(c.Ref)
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+
+			switch c.Type {
+			case "s":
+				if n, err := strconv.Atoi(c.Value); err == nil && n < len(wb.sharedString) {
+					row[col] = wb.sharedString[n]
+				}
+			case "inlineStr":
+				row[col] = c.Inline.text()
+			case "b":
+				row[col] = map[string]string{"1": "TRUE", "0": "FALSE"}[c.Value]
+			case "str", "e":
+				row[col] = c.Value
+			default:
+				row[col] = c.Value
+				if wb.dateStyles[c.Style] {
+					if serial, err := strconv.ParseFloat(c.Value, 64); err == nil {
+						row[col] = SerialToTime(serial).Format(time.RFC3339)
+					}
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// SerialToTime converts an Excel serial date of the 1900 date system to a UTC time.
+func SerialToTime(serial float64) time.Time {
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 86400)
+	return excelEpoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+}
+
+// inlineString is the text of a shared or inline string, either plain (t) or rich text runs (r>t).
+type inlineString struct {
+	Text string   `xml:"t"`
+	Runs []string `xml:"r>t"`
+}
+
+func (s inlineString) text() string {
+	return s.Text + strings.Join(s.Runs, "")
+}
+
+func (wb *Workbook) readSheets() error {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := wb.decode("xl/workbook.xml", &workbook); err != nil {
+		return err
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := wb.decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return err
+	}
+
+	targets := map[string]string{}
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+
+	for _, s := range workbook.Sheets {
+		wb.sheets = append(wb.sheets, sheetRef{name: s.Name, path: targets[s.ID]})
+	}
+
+	return nil
+}
+
+func (wb *Workbook) readSharedStrings() error {
+	if _, ok := wb.files["xl/sharedStrings.xml"]; !ok {
+		return nil
+	}
+
+	var sst struct {
+		Items []inlineString `xml:"si"`
+	}
+	if err := wb.decode("xl/sharedStrings.xml", &sst); err != nil {
+		return err
+	}
+
+	for _, item := range sst.Items {
+		wb.sharedString = append(wb.sharedString, item.text())
+	}
+
+	return nil
+}
+
+// readStyles finds the cell styles whose number format displays a date.
+func (wb *Workbook) readStyles() error {
+	if _, ok := wb.files["xl/styles.xml"]; !ok {
+		return nil
+	}
+
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := wb.decode("xl/styles.xml", &styles); err != nil {
+		return err
+	}
+
+	customDates := map[int]bool{}
+	for _, f := range styles.NumFmts {
+		customDates[f.ID] = isDateFormat(f.Code)
+	}
+
+	for i, xf := range styles.CellXfs {
+		builtinDate := (xf.NumFmtID >= 14 && xf.NumFmtID <= 22) || (xf.NumFmtID >= 45 && xf.NumFmtID <= 47)
+		wb.dateStyles[i] = builtinDate || customDates[xf.NumFmtID]
+	}
+
+	return nil
+}
+
+// isDateFormat reports whether a custom number format displays a date or time, ignoring quoted text and colors.
+func isDateFormat(code string) bool {
+	inQuote, inBracket := false, false
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case r == '[':
+			inBracket = true
+		case r == ']':
+			inBracket = false
+		case inBracket:
+		case strings.ContainsRune("ymdhs", r):
+			return true
+		}
+	}
+	return false
+}
+
+func (wb *Workbook) decode(name string, v any) error {
+	f, ok := wb.files[name]
+	if !ok {
+		return fmt.Errorf("missing %s", name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("err f.Open %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(io.LimitReader(rc, 64<<20)).Decode(v); err != nil {
+		return fmt.Errorf("err xml.Decode %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// columnIndex returns the zero based column of an A1 reference, e.g. "AB12" -> 27.
+func columnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
@@ -0,0 +1,194 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	rows := [][]any{
+		{"date", "category", "amount", "note"},
+		{"2024-09-05", "Food", 25000.5, "coffee & <cake>"},
+		{"2024-09-06", "Transport", 12000, "  bus  "},
+		{},
+		{"2024-09-07", "", -5000.0, "refund"},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "Sep & Oct", rows); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	wb, err := Open(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if got, want := wb.Sheets(), []string{"Sep & Oct"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sheets = %q, want %q", got, want)
+	}
+
+	got, err := wb.Rows(0)
+	if err != nil {
+		t.Fatalf("Rows: %v", err)
+	}
+	want := [][]string{
+		{"date", "category", "amount", "note"},
+		{"2024-09-05", "Food", "25000.5", "coffee & <cake>"},
+		{"2024-09-06", "Transport", "12000", "  bus  "},
+		nil,
+		{"2024-09-07", "", "-5000", "refund"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rows = %q, want %q", got, want)
+	}
+
+	if _, err := wb.Rows(1); !errors.Is(err, ErrSheetNotFound) {
+		t.Errorf("Rows(1) error = %v, want ErrSheetNotFound", err)
+	}
+}
+
+// workbook builds an XLSX file of one sheet from its sheet, shared strings and styles parts.
+func workbook(t *testing.T, sheet, sharedStrings, styles string) []byte {
+	t.Helper()
+
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Export" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Target="/xl/worksheets/data.xml"/></Relationships>`,
+		"xl/worksheets/data.xml": sheet,
+		"xl/sharedStrings.xml":   sharedStrings,
+		"xl/styles.xml":          styles,
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		if content == "" {
+			continue
+		}
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zw.Create: %v", err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatalf("fw.Write: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zw.Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRows(t *testing.T) {
+	const sharedStrings = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>Date</t></si><si><r><t>Category </t></r><r><t>name</t></r></si><si><t>Food</t></si></sst>`
+	// Style 1 is the built-in date format 14, style 2 a custom date format, style 3 a quoted text format
+	const styles = `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm"/><numFmt numFmtId="165" formatCode="&quot;Rp&quot; #,##0;[Red]-#,##0"/></numFmts>
+<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs></styleSheet>`
+
+	tests := []struct {
+		name          string
+		sheet         string
+		sharedStrings string
+		styles        string
+		want          [][]string
+	}{
+		{
+			name: "shared strings, dates and numbers",
+			sheet: `<worksheet><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>Amount</t></is></c></row>
+<row r="2"><c r="A2" s="1"><v>45540</v></c><c r="B2" t="s"><v>2</v></c><c r="C2" s="3"><v>-25000</v></c></row>
+<row r="3"><c r="A3" s="2"><v>45540.5</v></c><c r="B3" t="b"><v>1</v></c><c r="C3" t="str"><v>12.5</v></c></row>
+</sheetData></worksheet>`,
+			sharedStrings: sharedStrings,
+			styles:        styles,
+			want: [][]string{
+				{"Date", "Category name", "Amount"},
+				{"2024-09-05T00:00:00Z", "Food", "-25000"},
+				{"2024-09-05T12:00:00Z", "TRUE", "12.5"},
+			},
+		},
+		{
+			name: "gap rows and cells",
+			sheet: `<worksheet><sheetData>
+<row r="2"><c r="A2" t="inlineStr"><is><t>a</t></is></c><c r="D2"><v>4</v></c></row>
+<row r="5"><c r="B5"><v>2</v></c></row>
+</sheetData></worksheet>`,
+			want: [][]string{
+				{"a", "", "", "4"},
+				{"", "2"},
+			},
+		},
+		{
+			name: "cells without references",
+			sheet: `<worksheet><sheetData>
+<row><c><v>1</v></c><c><v>2</v></c></row>
+</sheetData></worksheet>`,
+			want: [][]string{{"1", "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wb, err := Open(workbook(t, tt.sheet, tt.sharedStrings, tt.styles))
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			got, err := wb.Rows(0)
+			if err != nil {
+				t.Fatalf("Rows: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rows = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCellRef(t *testing.T) {
+	tests := []struct {
+		col, row int
+		want     string
+	}{
+		{0, 0, "A1"},
+		{25, 9, "Z10"},
+		{26, 0, "AA1"},
+		{27, 11, "AB12"},
+		{701, 0, "ZZ1"},
+		{702, 0, "AAA1"},
+	}
+
+	for _, tt := range tests {
+		got := CellRef(tt.col, tt.row)
+		if got != tt.want {
+			t.Errorf("CellRef(%d, %d) = %q, want %q", tt.col, tt.row, got, tt.want)
+		}
+		if col := columnIndex(got); col != tt.col {
+			t.Errorf("columnIndex(%q) = %d, want %d", got, col, tt.col)
+		}
+	}
+}
+
+func TestSerialToTime(t *testing.T) {
+	tests := []struct {
+		serial float64
+		want   time.Time
+	}{
+		{61, time.Date(1900, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{45540, time.Date(2024, 9, 5, 0, 0, 0, 0, time.UTC)},
+		{45540.75, time.Date(2024, 9, 5, 18, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := SerialToTime(tt.serial); !got.Equal(tt.want) {
+			t.Errorf("SerialToTime(%v) = %v, want %v", tt.serial, got, tt.want)
+		}
+	}
+}
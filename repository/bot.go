@@ -19,6 +19,10 @@ type BotRepository interface {
 	GetUpdate(ctx context.Context, r io.Reader) (*tgbotapi.Update, error)
 	SendMessage(ctx context.Context, c tgbotapi.Chattable) (*tgbotapi.Message, error)
 	SendTextMessage(ctx context.Context, chatID int64, text string) (*tgbotapi.Message, error)
+	SendTextMessageWithKeyboard(ctx context.Context, chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) (*tgbotapi.Message, error)
+	EditMessageText(ctx context.Context, chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) (*tgbotapi.Message, error)
+	EditMessageReplyMarkup(ctx context.Context, chatID int64, messageID int, keyboard tgbotapi.InlineKeyboardMarkup) (*tgbotapi.Message, error)
+	AnswerCallbackQuery(ctx context.Context, callbackQueryID string, text string) error
 	DeleteMessage(ctx context.Context, chatID int64, messageID int) (*tgbotapi.Message, error)
 	GetFileURL(ctx context.Context, fileID string) (string, error)
 }
@@ -77,6 +81,76 @@ func (s *botRepo) SendTextMessage(ctx context.Context, chatID int64, text string
 	return &msg, nil
 }
 
+// SendTextMessageWithKeyboard sends a text message with an inline keyboard attached to a specific chat.
+func (s *botRepo) SendTextMessageWithKeyboard(ctx context.Context, chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) (*tgbotapi.Message, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "BotSendTextMessageWithKeyboard", err)
+	}()
+
+	stringMsg := tgbotapi.NewMessage(chatID, text)
+	stringMsg.ReplyMarkup = keyboard
+	msg, err := s.bot.Send(stringMsg)
+	if err != nil {
+		err = fmt.Errorf("err bot.Send: %w", err)
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+// EditMessageText replaces the text of a previously sent message.
+// A nil keyboard removes any inline keyboard from the message.
+func (s *botRepo) EditMessageText(ctx context.Context, chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) (*tgbotapi.Message, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "BotEditMessageText", err)
+	}()
+
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ReplyMarkup = keyboard
+	msg, err := s.bot.Send(edit)
+	if err != nil {
+		err = fmt.Errorf("err bot.Send: %w", err)
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+// EditMessageReplyMarkup replaces the inline keyboard of a previously sent message.
+func (s *botRepo) EditMessageReplyMarkup(ctx context.Context, chatID int64, messageID int, keyboard tgbotapi.InlineKeyboardMarkup) (*tgbotapi.Message, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "BotEditMessageReplyMarkup", err)
+	}()
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, keyboard)
+	msg, err := s.bot.Send(edit)
+	if err != nil {
+		err = fmt.Errorf("err bot.Send: %w", err)
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+// AnswerCallbackQuery acknowledges a callback query, optionally showing a short text to the user.
+func (s *botRepo) AnswerCallbackQuery(ctx context.Context, callbackQueryID string, text string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "BotAnswerCallbackQuery", err)
+	}()
+
+	_, err = s.bot.AnswerCallbackQuery(tgbotapi.NewCallback(callbackQueryID, text))
+	if err != nil {
+		err = fmt.Errorf("err bot.AnswerCallbackQuery: %w", err)
+		return err
+	}
+
+	return nil
+}
+
 // SetWebhook sets up the bot's webhook for receiving updates.
 func (s *botRepo) SetWebhook(ctx context.Context) error {
 	var err error
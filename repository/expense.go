@@ -0,0 +1,400 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"google.golang.org/api/sheets/v4"
+)
+
+// ExpenseQuery selects expenses. No Periods selects every period, an empty Category every category.
+// MaxAge allows answering from values read up to MaxAge ago, for backends caching their reads; zero reads fresh values.
+type ExpenseQuery struct {
+	Periods  []time.Time
+	Category string
+	MaxAge   time.Duration
+}
+
+// ExpenseStore is an interface for storing expenses, independently of where they are kept.
+// Expenses are grouped in month tabs named after their month, e.g. "09", whatever the backend.
+type ExpenseStore interface {
+	Add(ctx context.Context, expenses []model.Expense) ([]model.StoredExpense, error)
+	Query(ctx context.Context, query ExpenseQuery) ([]model.StoredExpense, error)
+	Get(ctx context.Context, sheet string, row int) (model.StoredExpense, error)
+	Update(ctx context.Context, expense model.StoredExpense) error
+	Delete(ctx context.Context, expenses []model.StoredExpense) error
+	Periods(ctx context.Context) ([]time.Time, error)
+}
+
+// MirroredExpenseStore is an ExpenseStore copying its writes to a second store, e.g. the spreadsheet.
+type MirroredExpenseStore interface {
+	ExpenseStore
+	// Unmirrored returns the store writing to its own backend only.
+	Unmirrored() ExpenseStore
+	// Mirror returns the store receiving the copies, nil when there is none.
+	Mirror() ExpenseStore
+}
+
+// ExpenseSheetHeader is the first row of every month tab.
+var ExpenseSheetHeader = []any{"date", "category", "amount", "note", "label", "wallet", "currency", "ref"}
+
+// ExpenseRow converts an expense to a month tab row.
+func ExpenseRow(expense model.Expense) []any {
+	return []any{
+		expense.Date.Format(common.SheetDateLayout),
+		expense.Category,
+		expense.Amount,
+		expense.Note,
+		expense.Label,
+		expense.Wallet,
+		expense.Currency,
+		expense.Ref,
+	}
+}
+
+// ExpenseFromRow converts a month tab row (date, category, amount, note, label, wallet, currency, ref) to an expense.
+// Wallet, currency and ref are missing from tabs written before they were added and are left empty.
+func ExpenseFromRow(row []any) (model.Expense, error) {
+	cell := func(i int) string {
+		if i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(fmt.Sprint(row[i]))
+	}
+
+	date, err := common.ParseSheetDate(cell(0))
+	if err != nil {
+		return model.Expense{}, err
+	}
+
+	amount, err := common.ParseAmount(cell(2))
+	if err != nil {
+		return model.Expense{}, fmt.Errorf("invalid amount: %w", err)
+	}
+
+	return model.Expense{
+		Date:     date,
+		Category: cell(1),
+		Amount:   amount,
+		Note:     cell(3),
+		Label:    cell(4),
+		Wallet:   cell(5),
+		Currency: cell(6),
+		Ref:      cell(7),
+	}, nil
+}
+
+// monthTabPattern matches the names of month tabs.
+var monthTabPattern = regexp.MustCompile(`^\d{2}$`)
+
+type gsheetExpenseStore struct {
+	gsheetRepo GSheetRepository
+}
+
+// Add implements ExpenseStore. It appends expenses to the month tab of their date, creating the tab and its header
+// when missing, and returns where each expense was written in the order given. Whatever the number of months,
+// the tabs are created and read in one call each, then appended to in a call per tab. The rows are those the
+// API reports it wrote, so a row added in between, by hand or by another instance, is kept.
+func (store *gsheetExpenseStore) Add(ctx context.Context, expenses []model.Expense) ([]model.StoredExpense, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetExpenseAdd", err)
+	}()
+
+	if len(expenses) == 0 {
+		return nil, nil
+	}
+
+	groups := groupByTab(expenses)
+	sheetNames := make([]string, len(groups))
+	headers := make([]string, len(groups))
+	for i, indexes := range groups {
+		sheetNames[i] = common.SheetNameForPeriod(expenses[indexes[0]].Date)
+		headers[i] = fmt.Sprintf("%s!A1", sheetNames[i])
+	}
+
+	if err = store.gsheetRepo.EnsureSheets(ctx, sheetNames); err != nil {
+		err = fmt.Errorf("err gsheetRepo.EnsureSheets: %w", err)
+		return nil, err
+	}
+
+	// A tab without its header is empty
+	gsheetValues, err := store.gsheetRepo.BatchGetValues(ctx, headers)
+	if err != nil {
+		err = fmt.Errorf("err gsheetRepo.BatchGetValues: %w", err)
+		return nil, err
+	}
+
+	stored := make([]model.StoredExpense, len(expenses))
+	for i, indexes := range groups {
+		var rows [][]any
+		if len(gsheetValues[i].Values) == 0 {
+			rows = append(rows, ExpenseSheetHeader)
+		}
+		headerRows := len(rows)
+		for _, k := range indexes {
+			rows = append(rows, ExpenseRow(expenses[k]))
+		}
+
+		var updatedRange string
+		updatedRange, err = store.gsheetRepo.AppendRow(ctx, sheetNames[i], rows)
+		if err != nil {
+			err = fmt.Errorf("err gsheetRepo.AppendRow: %w", err)
+			return nil, err
+		}
+
+		var written a1Range
+		written, err = parseA1(updatedRange)
+		if err != nil {
+			err = fmt.Errorf("err parseA1: %w", err)
+			return nil, err
+		}
+		if count := written.endRow - written.startRow + 1; count != len(rows) {
+			err = fmt.Errorf("appended %d rows to %s, want %d", count, updatedRange, len(rows))
+			return nil, err
+		}
+
+		for j, k := range indexes {
+			stored[k] = model.StoredExpense{Expense: expenses[k], Sheet: sheetNames[i], Row: written.startRow + 1 + headerRows + j}
+		}
+	}
+
+	return stored, nil
+}
+
+// Query implements ExpenseStore. The tabs of the periods, every month tab without periods, are read in one call.
+// Rows dated outside the periods (e.g. a previous year left in the same tab) are skipped, periods without a tab
+// have no expenses. With a MaxAge, tabs read recently by the process are answered from its cache.
+func (store *gsheetExpenseStore) Query(ctx context.Context, query ExpenseQuery) ([]model.StoredExpense, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetExpenseQuery", err)
+	}()
+
+	var sheetNames []string
+	if query.MaxAge > 0 {
+		sheetNames, err = store.gsheetRepo.CachedSheetNames(ctx, query.MaxAge)
+	} else {
+		sheetNames, err = store.gsheetRepo.SheetNames(ctx)
+	}
+	if err != nil {
+		err = fmt.Errorf("err gsheetRepo.SheetNames: %w", err)
+		return nil, err
+	}
+
+	candidates := sheetNames
+	if len(query.Periods) > 0 {
+		candidates = make([]string, len(query.Periods))
+		for i, period := range query.Periods {
+			candidates[i] = common.SheetNameForPeriod(period)
+		}
+	}
+
+	// Periods of different years share their tab, read it once
+	var tabs, ranges []string
+	for _, sheetName := range candidates {
+		if !monthTabPattern.MatchString(sheetName) || !common.Contains(sheetNames, sheetName) || common.Contains(tabs, sheetName) {
+			continue
+		}
+		tabs = append(tabs, sheetName)
+		ranges = append(ranges, fmt.Sprintf("%s!A:H", sheetName))
+	}
+
+	var gsheetValues []*sheets.ValueRange
+	if query.MaxAge > 0 {
+		gsheetValues, err = store.gsheetRepo.CachedBatchGetValues(ctx, ranges, query.MaxAge)
+	} else {
+		gsheetValues, err = store.gsheetRepo.BatchGetValues(ctx, ranges)
+	}
+	if err != nil {
+		err = fmt.Errorf("err gsheetRepo.BatchGetValues: %w", err)
+		return nil, err
+	}
+
+	var expenses []model.StoredExpense
+	for k, values := range gsheetValues {
+		for i, row := range values.Values {
+			expense, errRow := ExpenseFromRow(row)
+			if errRow != nil {
+				// The first row is the header
+				if i > 0 {
+					logger.Warn(ctx, fmt.Sprintf("skipping %s row %d: %s", ranges[k], i+1, errRow.Error()))
+				}
+				continue
+			}
+
+			if matchesQuery(expense, query) {
+				expenses = append(expenses, model.StoredExpense{Expense: expense, Sheet: tabs[k], Row: i + 1})
+			}
+		}
+	}
+
+	return expenses, nil
+}
+
+// Get implements ExpenseStore. It returns common.ErrNotFound when the row holds no expense.
+func (store *gsheetExpenseStore) Get(ctx context.Context, sheet string, row int) (model.StoredExpense, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetExpenseGet", err)
+	}()
+
+	gsheetValues, err := store.gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A%d:H%d", sheet, row, row))
+	if err != nil {
+		err = fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+		return model.StoredExpense{}, err
+	}
+	if len(gsheetValues.Values) == 0 {
+		return model.StoredExpense{}, common.ErrNotFound
+	}
+
+	expense, errRow := ExpenseFromRow(gsheetValues.Values[0])
+	if errRow != nil {
+		return model.StoredExpense{}, common.ErrNotFound
+	}
+
+	return model.StoredExpense{Expense: expense, Sheet: sheet, Row: row}, nil
+}
+
+// Update implements ExpenseStore. It overwrites the row of expense.
+func (store *gsheetExpenseStore) Update(ctx context.Context, expense model.StoredExpense) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetExpenseUpdate", err)
+	}()
+
+	targetRange := fmt.Sprintf("%s!A%d:H%d", expense.Sheet, expense.Row, expense.Row)
+	if err = store.gsheetRepo.UpdateValues(ctx, targetRange, [][]any{ExpenseRow(expense.Expense)}); err != nil {
+		err = fmt.Errorf("err gsheetRepo.UpdateValues: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Delete implements ExpenseStore. Rows are numbered as before any of them is deleted, the rows below move up
+// like deleting rows by hand. The rows of a tab are deleted in one call.
+func (store *gsheetExpenseStore) Delete(ctx context.Context, expenses []model.StoredExpense) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetExpenseDelete", err)
+	}()
+
+	var sheetNames []string
+	rows := map[string][]int{}
+	for _, expense := range expenses {
+		if _, ok := rows[expense.Sheet]; !ok {
+			sheetNames = append(sheetNames, expense.Sheet)
+		}
+		rows[expense.Sheet] = append(rows[expense.Sheet], expense.Row)
+	}
+
+	for _, sheetName := range sheetNames {
+		if err = store.gsheetRepo.DeleteRows(ctx, sheetName, rows[sheetName]); err != nil {
+			err = fmt.Errorf("err gsheetRepo.DeleteRows: %w", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Periods implements ExpenseStore. It returns the months having expenses, oldest first. The month tabs are read in one call.
+func (store *gsheetExpenseStore) Periods(ctx context.Context) ([]time.Time, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetExpensePeriods", err)
+	}()
+
+	sheetNames, err := store.gsheetRepo.SheetNames(ctx)
+	if err != nil {
+		err = fmt.Errorf("err gsheetRepo.SheetNames: %w", err)
+		return nil, err
+	}
+
+	var ranges []string
+	for _, sheetName := range sheetNames {
+		if monthTabPattern.MatchString(sheetName) {
+			ranges = append(ranges, fmt.Sprintf("%s!A:A", sheetName))
+		}
+	}
+
+	gsheetValues, err := store.gsheetRepo.BatchGetValues(ctx, ranges)
+	if err != nil {
+		err = fmt.Errorf("err gsheetRepo.BatchGetValues: %w", err)
+		return nil, err
+	}
+
+	seen := map[time.Time]bool{}
+	for _, values := range gsheetValues {
+		for _, row := range values.Values {
+			if len(row) == 0 {
+				continue
+			}
+			date, errDate := common.ParseSheetDate(strings.TrimSpace(fmt.Sprint(row[0])))
+			if errDate != nil {
+				continue
+			}
+			seen[periodOf(date)] = true
+		}
+	}
+
+	return sortedPeriods(seen), nil
+}
+
+// groupByTab returns the indexes of expenses grouped by month tab, the tabs in order of first appearance.
+func groupByTab(expenses []model.Expense) [][]int {
+	var groups [][]int
+	position := map[string]int{}
+	for i, expense := range expenses {
+		sheetName := common.SheetNameForPeriod(expense.Date)
+		if _, ok := position[sheetName]; !ok {
+			position[sheetName] = len(groups)
+			groups = append(groups, nil)
+		}
+		groups[position[sheetName]] = append(groups[position[sheetName]], i)
+	}
+	return groups
+}
+
+// matchesQuery reports whether expense belongs to one of the periods of query, if any, and to its category, if any.
+func matchesQuery(expense model.Expense, query ExpenseQuery) bool {
+	if query.Category != "" && !strings.EqualFold(expense.Category, query.Category) {
+		return false
+	}
+	if len(query.Periods) == 0 {
+		return true
+	}
+	for _, period := range query.Periods {
+		if expense.Date.Year() == period.Year() && expense.Date.Month() == period.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+// periodOf returns the first moment of the month of date.
+func periodOf(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func sortedPeriods(seen map[time.Time]bool) []time.Time {
+	periods := make([]time.Time, 0, len(seen))
+	for period := range seen {
+		periods = append(periods, period)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Before(periods[j]) })
+	return periods
+}
+
+// NewGSheetExpenseStore creates an ExpenseStore keeping expenses in the month tabs of the spreadsheet.
+func NewGSheetExpenseStore(gsheetRepo GSheetRepository) ExpenseStore {
+	return &gsheetExpenseStore{gsheetRepo: gsheetRepo}
+}
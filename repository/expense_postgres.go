@@ -0,0 +1,454 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/migrate"
+
+	// Registers the "postgres" database/sql driver
+	_ "github.com/lib/pq"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// postgresDBs holds a connection pool per DATABASE_URL, created and migrated once per process.
+var postgresDBs sync.Map
+
+type postgresDB struct {
+	once sync.Once
+	db   *sql.DB
+	err  error
+}
+
+// openPostgres returns the connection pool of dsn, applying the pending migrations on first use.
+func openPostgres(ctx context.Context, dsn string) (*sql.DB, error) {
+	entry, _ := postgresDBs.LoadOrStore(dsn, &postgresDB{})
+	conn := entry.(*postgresDB)
+	conn.once.Do(func() {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			conn.err = fmt.Errorf("err sql.Open: %w", err)
+			return
+		}
+
+		applied, err := migrateUp(ctx, db)
+		if err != nil {
+			db.Close()
+			conn.err = fmt.Errorf("err migrateUp: %w", err)
+			return
+		}
+		for _, name := range applied {
+			logger.Info(ctx, fmt.Sprintf("applied migration %s", name))
+		}
+
+		conn.db = db
+	})
+
+	if conn.err != nil {
+		// Let the next request try again, e.g. once the database is up
+		postgresDBs.Delete(dsn)
+	}
+	return conn.db, conn.err
+}
+
+// migrateUp applies the embedded migrations to db and returns the names of those not applied before.
+func migrateUp(ctx context.Context, db *sql.DB) ([]string, error) {
+	dir, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("err fs.Sub: %w", err)
+	}
+
+	migrations, err := migrate.Load(dir)
+	if err != nil {
+		return nil, fmt.Errorf("err migrate.Load: %w", err)
+	}
+
+	return migrate.Up(ctx, db, migrations)
+}
+
+// expenseColumns are the columns read into a StoredExpense by scanExpense.
+const expenseColumns = "id, spent_at, category, amount, note, label, wallet, currency, ref"
+
+type postgresExpenseStore struct {
+	db *sql.DB
+	// mirror receives a copy of every write, e.g. the spreadsheet for people who like its view. It may be nil.
+	mirror ExpenseStore
+}
+
+// Add implements ExpenseStore. The rows of a single call are inserted in one transaction.
+// Row is the id of the expense in the table.
+func (store *postgresExpenseStore) Add(ctx context.Context, expenses []model.Expense) ([]model.StoredExpense, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "PostgresExpenseAdd", err)
+	}()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		err = fmt.Errorf("err db.BeginTx: %w", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stored := make([]model.StoredExpense, len(expenses))
+	for i, expense := range expenses {
+		var id int
+		err = tx.QueryRowContext(ctx, `INSERT INTO expenses (spent_at, period, category, amount, note, label, wallet, currency, ref)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+			expense.Date, periodOf(expense.Date), expense.Category, expense.Amount, expense.Note,
+			expense.Label, expense.Wallet, expense.Currency, expense.Ref,
+		).Scan(&id)
+		if err != nil {
+			err = fmt.Errorf("err insert expense: %w", err)
+			return nil, err
+		}
+		stored[i] = model.StoredExpense{Expense: expense, Sheet: common.SheetNameForPeriod(expense.Date), Row: id}
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("err tx.Commit: %w", err)
+		return nil, err
+	}
+
+	if store.mirror != nil {
+		if _, errMirror := store.mirror.Add(ctx, expenses); errMirror != nil {
+			logger.Warn(ctx, fmt.Sprintf("mirror.Add: %s", errMirror.Error()))
+		}
+	}
+
+	return stored, nil
+}
+
+// Query implements ExpenseStore.
+func (store *postgresExpenseStore) Query(ctx context.Context, query ExpenseQuery) ([]model.StoredExpense, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "PostgresExpenseQuery", err)
+	}()
+
+	var conditions []string
+	var args []any
+	if len(query.Periods) > 0 {
+		placeholders := make([]string, len(query.Periods))
+		for i, period := range query.Periods {
+			args = append(args, periodOf(period))
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf("period IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	if query.Category != "" {
+		args = append(args, query.Category)
+		conditions = append(conditions, fmt.Sprintf("lower(category) = lower($%d)", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := store.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM expenses %s ORDER BY period, id", expenseColumns, where), args...)
+	if err != nil {
+		err = fmt.Errorf("err db.QueryContext: %w", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expenses []model.StoredExpense
+	for rows.Next() {
+		expense, errScan := scanExpense(rows)
+		if errScan != nil {
+			err = fmt.Errorf("err scanExpense: %w", errScan)
+			return nil, err
+		}
+		expenses = append(expenses, expense)
+	}
+	if err = rows.Err(); err != nil {
+		err = fmt.Errorf("err rows.Err: %w", err)
+		return nil, err
+	}
+
+	return expenses, nil
+}
+
+// Get implements ExpenseStore. row is the id of the expense, which must belong to the month tab sheet.
+func (store *postgresExpenseStore) Get(ctx context.Context, sheet string, row int) (model.StoredExpense, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "PostgresExpenseGet", err)
+	}()
+
+	expense, err := store.get(ctx, row)
+	if errors.Is(err, common.ErrNotFound) || (err == nil && expense.Sheet != sheet) {
+		err = nil
+		return model.StoredExpense{}, common.ErrNotFound
+	}
+	if err != nil {
+		err = fmt.Errorf("err get: %w", err)
+		return model.StoredExpense{}, err
+	}
+
+	return expense, nil
+}
+
+// Update implements ExpenseStore.
+func (store *postgresExpenseStore) Update(ctx context.Context, expense model.StoredExpense) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "PostgresExpenseUpdate", err)
+	}()
+
+	previous, err := store.get(ctx, expense.Row)
+	if err != nil {
+		err = fmt.Errorf("err get: %w", err)
+		return err
+	}
+
+	_, err = store.db.ExecContext(ctx, `UPDATE expenses SET spent_at = $1, period = $2, category = $3, amount = $4, note = $5,
+		label = $6, wallet = $7, currency = $8, ref = $9, updated_at = now() WHERE id = $10`,
+		expense.Date, periodOf(expense.Date), expense.Category, expense.Amount, expense.Note,
+		expense.Label, expense.Wallet, expense.Currency, expense.Ref, expense.Row,
+	)
+	if err != nil {
+		err = fmt.Errorf("err update expense: %w", err)
+		return err
+	}
+
+	if store.mirror != nil {
+		if errMirror := store.mirrorUpdate(ctx, previous.Expense, expense.Expense); errMirror != nil {
+			logger.Warn(ctx, fmt.Sprintf("mirrorUpdate: %s", errMirror.Error()))
+		}
+	}
+
+	return nil
+}
+
+// Delete implements ExpenseStore. The expenses are deleted in one statement.
+func (store *postgresExpenseStore) Delete(ctx context.Context, expenses []model.StoredExpense) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "PostgresExpenseDelete", err)
+	}()
+
+	if len(expenses) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(expenses))
+	args := make([]any, len(expenses))
+	for i, expense := range expenses {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = expense.Row
+	}
+
+	rows, err := store.db.QueryContext(ctx, fmt.Sprintf("DELETE FROM expenses WHERE id IN (%s) RETURNING %s", strings.Join(placeholders, ", "), expenseColumns), args...)
+	if err != nil {
+		err = fmt.Errorf("err delete expenses: %w", err)
+		return err
+	}
+	defer rows.Close()
+
+	var deleted []model.Expense
+	for rows.Next() {
+		expense, errScan := scanExpense(rows)
+		if errScan != nil {
+			err = fmt.Errorf("err scanExpense: %w", errScan)
+			return err
+		}
+		deleted = append(deleted, expense.Expense)
+	}
+	if err = rows.Err(); err != nil {
+		err = fmt.Errorf("err rows.Err: %w", err)
+		return err
+	}
+	if len(deleted) < len(expenses) {
+		err = fmt.Errorf("%d of %d expenses: %w", len(expenses)-len(deleted), len(expenses), common.ErrNotFound)
+		return err
+	}
+
+	if store.mirror != nil {
+		if errMirror := store.mirrorDelete(ctx, deleted); errMirror != nil {
+			logger.Warn(ctx, fmt.Sprintf("mirrorDelete: %s", errMirror.Error()))
+		}
+	}
+
+	return nil
+}
+
+// Periods implements ExpenseStore.
+func (store *postgresExpenseStore) Periods(ctx context.Context) ([]time.Time, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "PostgresExpensePeriods", err)
+	}()
+
+	rows, err := store.db.QueryContext(ctx, `SELECT DISTINCT period FROM expenses ORDER BY period`)
+	if err != nil {
+		err = fmt.Errorf("err db.QueryContext: %w", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var periods []time.Time
+	for rows.Next() {
+		var period time.Time
+		if err = rows.Scan(&period); err != nil {
+			err = fmt.Errorf("err rows.Scan: %w", err)
+			return nil, err
+		}
+		periods = append(periods, periodOf(period))
+	}
+	if err = rows.Err(); err != nil {
+		err = fmt.Errorf("err rows.Err: %w", err)
+		return nil, err
+	}
+
+	return periods, nil
+}
+
+// Unmirrored implements MirroredExpenseStore.
+func (store *postgresExpenseStore) Unmirrored() ExpenseStore {
+	return &postgresExpenseStore{db: store.db}
+}
+
+// Mirror implements MirroredExpenseStore.
+func (store *postgresExpenseStore) Mirror() ExpenseStore {
+	return store.mirror
+}
+
+// get reads the expense of id, returning common.ErrNotFound when there is none.
+func (store *postgresExpenseStore) get(ctx context.Context, id int) (model.StoredExpense, error) {
+	row := store.db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM expenses WHERE id = $1", expenseColumns), id)
+	expense, err := scanExpense(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.StoredExpense{}, common.ErrNotFound
+	}
+	if err != nil {
+		return model.StoredExpense{}, fmt.Errorf("err scanExpense: %w", err)
+	}
+
+	return expense, nil
+}
+
+// mirrorUpdate replaces previous with expense in the mirror, a zero expense deleting it.
+// The mirror has its own row numbers, so the row is found by its content.
+func (store *postgresExpenseStore) mirrorUpdate(ctx context.Context, previous, expense model.Expense) error {
+	candidates, err := store.mirror.Query(ctx, ExpenseQuery{Periods: []time.Time{previous.Date}})
+	if err != nil {
+		return fmt.Errorf("err mirror.Query: %w", err)
+	}
+
+	for _, candidate := range candidates {
+		if !sameExpense(candidate.Expense, previous) {
+			continue
+		}
+
+		// Moving to another month tab takes a delete and an add
+		if expense.Date.IsZero() || common.SheetNameForPeriod(expense.Date) != candidate.Sheet {
+			if err := store.mirror.Delete(ctx, []model.StoredExpense{candidate}); err != nil {
+				return fmt.Errorf("err mirror.Delete: %w", err)
+			}
+			if expense.Date.IsZero() {
+				return nil
+			}
+			if _, err := store.mirror.Add(ctx, []model.Expense{expense}); err != nil {
+				return fmt.Errorf("err mirror.Add: %w", err)
+			}
+			return nil
+		}
+
+		candidate.Expense = expense
+		if err := store.mirror.Update(ctx, candidate); err != nil {
+			return fmt.Errorf("err mirror.Update: %w", err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("expense %s of %s not in the mirror: %w", previous.Note, previous.Date.Format("2006-01-02"), common.ErrNotFound)
+}
+
+// mirrorDelete deletes expenses from the mirror, finding their rows by content like mirrorUpdate.
+// The rows found are deleted even when some expenses are missing from the mirror.
+func (store *postgresExpenseStore) mirrorDelete(ctx context.Context, expenses []model.Expense) error {
+	periods := make([]time.Time, len(expenses))
+	for i, expense := range expenses {
+		periods[i] = expense.Date
+	}
+	candidates, err := store.mirror.Query(ctx, ExpenseQuery{Periods: periods})
+	if err != nil {
+		return fmt.Errorf("err mirror.Query: %w", err)
+	}
+
+	var found []model.StoredExpense
+	var missing int
+	used := make([]bool, len(candidates))
+	for _, expense := range expenses {
+		i := 0
+		for ; i < len(candidates); i++ {
+			if !used[i] && sameExpense(candidates[i].Expense, expense) {
+				break
+			}
+		}
+		if i == len(candidates) {
+			missing++
+			continue
+		}
+		used[i] = true
+		found = append(found, candidates[i])
+	}
+
+	if err := store.mirror.Delete(ctx, found); err != nil {
+		return fmt.Errorf("err mirror.Delete: %w", err)
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d expenses not in the mirror: %w", missing, common.ErrNotFound)
+	}
+
+	return nil
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExpense(row rowScanner) (model.StoredExpense, error) {
+	var expense model.StoredExpense
+	err := row.Scan(&expense.Row, &expense.Date, &expense.Category, &expense.Amount, &expense.Note,
+		&expense.Label, &expense.Wallet, &expense.Currency, &expense.Ref)
+	if err != nil {
+		return model.StoredExpense{}, err
+	}
+
+	expense.Sheet = common.SheetNameForPeriod(expense.Date)
+	return expense, nil
+}
+
+// sameExpense reports whether a and b hold the same values, as far as the spreadsheet keeps them.
+func sameExpense(a, b model.Expense) bool {
+	return a.Date.Format(common.SheetDateLayout) == b.Date.Format(common.SheetDateLayout) &&
+		a.Category == b.Category &&
+		math.Abs(a.Amount-b.Amount) < 0.005 &&
+		a.Note == b.Note &&
+		a.Label == b.Label &&
+		a.Wallet == b.Wallet &&
+		a.Currency == b.Currency &&
+		a.Ref == b.Ref
+}
+
+// NewPostgresExpenseStore creates an ExpenseStore keeping expenses in the expenses table of db,
+// copying every write to mirror unless it is nil.
+func NewPostgresExpenseStore(db *sql.DB, mirror ExpenseStore) ExpenseStore {
+	return &postgresExpenseStore{db: db, mirror: mirror}
+}
@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/model"
+)
+
+// newTestPostgres connects to POSTGRES_TEST_DSN in a schema of its own, dropped at the end of the test,
+// and returns the connection and its DSN. The test is skipped when POSTGRES_TEST_DSN is not set.
+func newTestPostgres(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	ctx := context.Background()
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	schema := fmt.Sprintf("expense_test_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("drop schema: %v", err)
+		}
+		admin.Close()
+	})
+
+	// Unknown connection parameters are sent to the server as run-time parameters
+	schemaDSN := dsn + " search_path=" + schema
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatalf("url.Parse: %v", err)
+		}
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		schemaDSN = u.String()
+	}
+
+	db, err := sql.Open("postgres", schemaDSN)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db, schemaDSN
+}
+
+// newTestPostgresStore returns a migrated postgres store, mirrored to mirror unless it is nil.
+func newTestPostgresStore(t *testing.T, mirror ExpenseStore) ExpenseStore {
+	t.Helper()
+
+	db, _ := newTestPostgres(t)
+	if _, err := migrateUp(context.Background(), db); err != nil {
+		t.Fatalf("migrateUp: %v", err)
+	}
+	return NewPostgresExpenseStore(db, mirror)
+}
+
+// equalStored reports whether got and want hold the same expenses in the same rows. Dates are compared as
+// instants, the driver reads them in a zone of its own.
+func equalStored(got, want []model.StoredExpense) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		a, b := got[i], want[i]
+		if a.Row != b.Row || a.Sheet != b.Sheet || !a.Date.Equal(b.Date) {
+			return false
+		}
+		a.Date, b.Date = time.Time{}, time.Time{}
+		if a != b {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPostgresMigrateOnce(t *testing.T) {
+	ctx := context.Background()
+	db, dsn := newTestPostgres(t)
+
+	applied, err := migrateUp(ctx, db)
+	if err != nil {
+		t.Fatalf("migrateUp: %v", err)
+	}
+	if want := []string{"0001_create_expenses.sql", "0002_expense_indexes.sql"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("first migrateUp applied %v, want %v", applied, want)
+	}
+
+	applied, err = migrateUp(ctx, db)
+	if err != nil {
+		t.Fatalf("migrateUp again: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second migrateUp applied %v, want none", applied)
+	}
+
+	// The pool of a DSN is opened and migrated once per process
+	t.Cleanup(func() { postgresDBs.Delete(dsn) })
+	first, err := openPostgres(ctx, dsn)
+	if err != nil {
+		t.Fatalf("openPostgres: %v", err)
+	}
+	t.Cleanup(func() { first.Close() })
+	second, err := openPostgres(ctx, dsn)
+	if err != nil {
+		t.Fatalf("openPostgres again: %v", err)
+	}
+	if first != second {
+		t.Error("openPostgres opened the same DSN twice")
+	}
+}
+
+func TestPostgresExpenseStore(t *testing.T) {
+	ctx := context.Background()
+	store := newTestPostgresStore(t, nil)
+
+	september := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	october := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	expenses := []model.Expense{
+		{Date: september.AddDate(0, 0, 4), Category: "Food", Amount: 25000, Note: "coffee", Wallet: "Cash", Currency: "IDR", Ref: "ofx:1"},
+		{Date: september.AddDate(0, 0, 9), Category: "Transport", Amount: 12500.5, Note: "bus", Label: "work", Currency: "IDR"},
+		{Date: october.AddDate(0, 0, 1), Category: "food", Amount: -5000, Note: "refund", Currency: "IDR"},
+	}
+
+	// Add
+	added, err := store.Add(ctx, expenses)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if len(added) != len(expenses) {
+		t.Fatalf("Add returned %d expenses, want %d", len(added), len(expenses))
+	}
+	for i, expense := range added {
+		if expense.Row <= 0 {
+			t.Errorf("added[%d].Row = %d, want an id", i, expense.Row)
+		}
+		if want := common.SheetNameForPeriod(expenses[i].Date); expense.Sheet != want {
+			t.Errorf("added[%d].Sheet = %q, want %q", i, expense.Sheet, want)
+		}
+	}
+
+	// Query
+	queryTests := []struct {
+		name  string
+		query ExpenseQuery
+		want  []model.StoredExpense
+	}{
+		{name: "everything", query: ExpenseQuery{}, want: added},
+		{name: "one month", query: ExpenseQuery{Periods: []time.Time{september}}, want: added[:2]},
+		{name: "a day of the month", query: ExpenseQuery{Periods: []time.Time{october.AddDate(0, 0, 20)}}, want: added[2:]},
+		{name: "category ignoring case", query: ExpenseQuery{Category: "FOOD"}, want: []model.StoredExpense{added[0], added[2]}},
+		{name: "category of a month", query: ExpenseQuery{Periods: []time.Time{september}, Category: "food"}, want: added[:1]},
+		{name: "nothing", query: ExpenseQuery{Periods: []time.Time{october.AddDate(0, 1, 0)}}, want: nil},
+	}
+	for _, tt := range queryTests {
+		t.Run("Query "+tt.name, func(t *testing.T) {
+			got, err := store.Query(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if !equalStored(got, tt.want) {
+				t.Errorf("Query = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Get
+	got, err := store.Get(ctx, added[1].Sheet, added[1].Row)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !equalStored([]model.StoredExpense{got}, added[1:2]) {
+		t.Errorf("Get = %+v, want %+v", got, added[1])
+	}
+	if _, err := store.Get(ctx, added[2].Sheet, added[1].Row); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Get of another month tab error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Get(ctx, added[1].Sheet, added[2].Row+1000); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Get of a missing row error = %v, want ErrNotFound", err)
+	}
+
+	// Update, moving the expense to another month
+	moved := added[1]
+	moved.Date = october.AddDate(0, 0, 14)
+	moved.Note = "train"
+	if err := store.Update(ctx, moved); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	moved.Sheet = common.SheetNameForPeriod(moved.Date)
+	got, err = store.Get(ctx, moved.Sheet, moved.Row)
+	if err != nil {
+		t.Fatalf("Get after Update: %v", err)
+	}
+	if !equalStored([]model.StoredExpense{got}, []model.StoredExpense{moved}) {
+		t.Errorf("Get after Update = %+v, want %+v", got, moved)
+	}
+	if err := store.Update(ctx, model.StoredExpense{Expense: moved.Expense, Row: added[2].Row + 1000}); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Update of a missing row error = %v, want ErrNotFound", err)
+	}
+
+	// Periods
+	periods, err := store.Periods(ctx)
+	if err != nil {
+		t.Fatalf("Periods: %v", err)
+	}
+	if want := []time.Time{september, october}; !reflect.DeepEqual(periods, want) {
+		t.Errorf("Periods = %v, want %v", periods, want)
+	}
+
+	// Delete
+	if err := store.Delete(ctx, []model.StoredExpense{added[0], moved}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	remaining, err := store.Query(ctx, ExpenseQuery{})
+	if err != nil {
+		t.Fatalf("Query after Delete: %v", err)
+	}
+	if want := added[2:]; !equalStored(remaining, want) {
+		t.Errorf("Query after Delete = %+v, want %+v", remaining, want)
+	}
+	if err := store.Delete(ctx, []model.StoredExpense{added[0]}); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("Delete of a deleted row error = %v, want ErrNotFound", err)
+	}
+
+	periods, err = store.Periods(ctx)
+	if err != nil {
+		t.Fatalf("Periods after Delete: %v", err)
+	}
+	if want := []time.Time{october}; !reflect.DeepEqual(periods, want) {
+		t.Errorf("Periods after Delete = %v, want %v", periods, want)
+	}
+}
+
+func TestPostgresExpenseStoreMirror(t *testing.T) {
+	ctx := context.Background()
+	mirror := NewGSheetExpenseStore(NewLocalGSheetRepository(filepath.Join(t.TempDir(), "sheets.json")))
+	store := newTestPostgresStore(t, mirror)
+
+	september := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	added, err := store.Add(ctx, []model.Expense{
+		{Date: september.AddDate(0, 0, 4), Category: "Food", Amount: 25000, Note: "coffee", Currency: "IDR"},
+		{Date: september.AddDate(0, 0, 9), Category: "Transport", Amount: 12500, Note: "bus", Currency: "IDR"},
+	})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	mirrored := func() []string {
+		t.Helper()
+		rows, err := mirror.Query(ctx, ExpenseQuery{Periods: []time.Time{september}})
+		if err != nil {
+			t.Fatalf("mirror.Query: %v", err)
+		}
+		notes := make([]string, len(rows))
+		for i, row := range rows {
+			notes[i] = row.Note
+		}
+		return notes
+	}
+	if got, want := mirrored(), []string{"coffee", "bus"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mirror after Add = %v, want %v", got, want)
+	}
+
+	updated := added[0]
+	updated.Note = "tea"
+	if err := store.Update(ctx, updated); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got, want := mirrored(), []string{"tea", "bus"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mirror after Update = %v, want %v", got, want)
+	}
+
+	if err := store.Delete(ctx, []model.StoredExpense{added[1]}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, want := mirrored(), []string{"tea"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mirror after Delete = %v, want %v", got, want)
+	}
+}
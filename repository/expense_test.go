@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/model"
+)
+
+func TestGSheetExpenseStoreAdd(t *testing.T) {
+	ctx := context.Background()
+	repo := NewLocalGSheetRepository(filepath.Join(t.TempDir(), "sheets.json"))
+	store := NewGSheetExpenseStore(repo)
+
+	september := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	october := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	expense := func(date time.Time, note string) model.Expense {
+		return model.Expense{Date: date, Category: "Food", Amount: 1000, Note: note, Currency: "IDR"}
+	}
+	location := func(stored []model.StoredExpense) []string {
+		got := make([]string, len(stored))
+		for i, expense := range stored {
+			got[i] = fmt.Sprintf("%s!%d %s", expense.Sheet, expense.Row, expense.Note)
+		}
+		return got
+	}
+
+	// New tabs get their header first
+	added, err := store.Add(ctx, []model.Expense{
+		expense(september.AddDate(0, 0, 1), "a"),
+		expense(october.AddDate(0, 0, 1), "b"),
+		expense(september.AddDate(0, 0, 2), "c"),
+	})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if got, want := location(added), []string{"09!2 a", "10!2 b", "09!3 c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Add = %v, want %v", got, want)
+	}
+
+	// A row added by hand in between is kept
+	if _, err := repo.AppendRow(ctx, "09", [][]any{{"2024-09-03", "Food", 500, "by hand"}}); err != nil {
+		t.Fatalf("AppendRow: %v", err)
+	}
+	added, err = store.Add(ctx, []model.Expense{expense(september.AddDate(0, 0, 4), "d")})
+	if err != nil {
+		t.Fatalf("Add again: %v", err)
+	}
+	if got, want := location(added), []string{"09!5 d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Add again = %v, want %v", got, want)
+	}
+
+	got, err := store.Query(ctx, ExpenseQuery{Periods: []time.Time{september}})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if want := []string{"09!2 a", "09!3 c", "09!4 by hand", "09!5 d"}; !reflect.DeepEqual(location(got), want) {
+		t.Errorf("Query = %v, want %v", location(got), want)
+	}
+}
@@ -2,25 +2,45 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/frasnym/go-expense-telebot/common"
 	"github.com/frasnym/go-expense-telebot/common/logger"
 	"github.com/frasnym/go-expense-telebot/config"
+	"github.com/frasnym/go-expense-telebot/pkg/gsheet"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/sheets/v4"
 )
 
 type GSheetRepository interface {
-	AppendRow(ctx context.Context, sheetName string, input [][]any) error
+	AppendRow(ctx context.Context, sheetName string, input [][]any) (string, error)
 	GetValues(ctx context.Context, valueRange string) (*sheets.ValueRange, error)
+	UpdateValues(ctx context.Context, valueRange string, input [][]any) error
+	ClearValues(ctx context.Context, valueRange string) error
+	EnsureSheet(ctx context.Context, sheetName string) error
+	SheetNames(ctx context.Context) ([]string, error)
+	DeleteRows(ctx context.Context, sheetName string, rows []int) error
+	EnsureSheets(ctx context.Context, sheetNames []string) error
+	BatchGetValues(ctx context.Context, valueRanges []string) ([]*sheets.ValueRange, error)
+	BatchUpdateValues(ctx context.Context, data []*sheets.ValueRange) error
+	CachedSheetNames(ctx context.Context, maxAge time.Duration) ([]string, error)
+	CachedBatchGetValues(ctx context.Context, valueRanges []string, maxAge time.Duration) ([]*sheets.ValueRange, error)
 }
 
 type gsheetRepo struct {
-	cfg     *config.Config
-	service *sheets.Service
+	cfg       *config.Config
+	service   *sheets.Service
+	rateLimit int
 }
 
-// AppendRow implements GSheetRepository.
-func (repo *gsheetRepo) AppendRow(ctx context.Context, sheetName string, input [][]any) error {
+// AppendRow implements GSheetRepository. It returns the range the rows were written to, e.g. "'09'!A12:H14".
+func (repo *gsheetRepo) AppendRow(ctx context.Context, sheetName string, input [][]any) (string, error) {
 	var err error
 	defer func() {
 		logger.LogService(ctx, "GSheetAppendRow", err)
@@ -30,16 +50,25 @@ func (repo *gsheetRepo) AppendRow(ctx context.Context, sheetName string, input [
 		Values: input,
 	}
 
-	_, err = repo.service.Spreadsheets.Values.
-		Append(repo.cfg.GsheetID, fmt.Sprintf("%s!A:E", sheetName), values).ValueInputOption("USER_ENTERED").Do()
+	var resp *sheets.AppendValuesResponse
+	err = repo.callOnce(ctx, func() (errCall error) {
+		resp, errCall = repo.service.Spreadsheets.Values.
+			Append(repo.cfg.GsheetID, fmt.Sprintf("%s!A:H", sheetName), values).ValueInputOption("USER_ENTERED").Context(ctx).Do()
+		return errCall
+	})
+	repo.invalidate(sheetName)
 	if err != nil {
 		err = fmt.Errorf("err repo.service.Spreadsheets.Values.Append: %w", err)
-		return err
+		return "", err
 	}
 
-	return nil
+	if resp.Updates == nil {
+		return "", nil
+	}
+	return resp.Updates.UpdatedRange, nil
 }
 
+// GetValues reads the cells of valueRange. It returns common.ErrNotFound when the tab doesn't exist.
 func (repo *gsheetRepo) GetValues(ctx context.Context, valueRange string) (*sheets.ValueRange, error) {
 	var err error
 	defer func() {
@@ -47,15 +76,338 @@ func (repo *gsheetRepo) GetValues(ctx context.Context, valueRange string) (*shee
 	}()
 
 	// Make the API call to get values from the specified range.
-	resp, err := repo.service.Spreadsheets.Values.Get(repo.cfg.GsheetID, valueRange).Do()
+	var resp *sheets.ValueRange
+	err = repo.call(ctx, func() (errCall error) {
+		resp, errCall = repo.service.Spreadsheets.Values.Get(repo.cfg.GsheetID, valueRange).Context(ctx).Do()
+		return errCall
+	})
 	if err != nil {
-		err = fmt.Errorf("err repo.service.Spreadsheets.Values.Get: %w", err)
+		err = fmt.Errorf("err repo.service.Spreadsheets.Values.Get: %w", missingSheet(err))
 		return nil, err
 	}
 
 	return resp, nil
 }
 
+// UpdateValues overwrites the cells of valueRange, starting from its top left cell.
+func (repo *gsheetRepo) UpdateValues(ctx context.Context, valueRange string, input [][]any) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetUpdateValues", err)
+	}()
+
+	values := &sheets.ValueRange{
+		Values: input,
+	}
+
+	err = repo.call(ctx, func() error {
+		_, errCall := repo.service.Spreadsheets.Values.
+			Update(repo.cfg.GsheetID, valueRange, values).ValueInputOption("USER_ENTERED").Context(ctx).Do()
+		return errCall
+	})
+	repo.invalidate(rangeSheet(valueRange))
+	if err != nil {
+		err = fmt.Errorf("err repo.service.Spreadsheets.Values.Update: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// ClearValues empties the cells of valueRange, keeping their formatting.
+func (repo *gsheetRepo) ClearValues(ctx context.Context, valueRange string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetClearValues", err)
+	}()
+
+	err = repo.call(ctx, func() error {
+		_, errCall := repo.service.Spreadsheets.Values.
+			Clear(repo.cfg.GsheetID, valueRange, &sheets.ClearValuesRequest{}).Context(ctx).Do()
+		return errCall
+	})
+	repo.invalidate(rangeSheet(valueRange))
+	if err != nil {
+		err = fmt.Errorf("err repo.service.Spreadsheets.Values.Clear: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// EnsureSheet adds a tab named sheetName to the spreadsheet unless it already exists.
+func (repo *gsheetRepo) EnsureSheet(ctx context.Context, sheetName string) error {
+	return repo.EnsureSheets(ctx, []string{sheetName})
+}
+
+// EnsureSheets adds the missing tabs of sheetNames to the spreadsheet, all in one call.
+func (repo *gsheetRepo) EnsureSheets(ctx context.Context, sheetNames []string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetEnsureSheets", err)
+	}()
+
+	existing, err := repo.SheetNames(ctx)
+	if err != nil {
+		err = fmt.Errorf("err SheetNames: %w", err)
+		return err
+	}
+
+	var requests []*sheets.Request
+	for _, sheetName := range sheetNames {
+		if common.Contains(existing, sheetName) {
+			continue
+		}
+		existing = append(existing, sheetName)
+		requests = append(requests, &sheets.Request{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: sheetName}}})
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+
+	err = repo.call(ctx, func() error {
+		_, errCall := repo.service.Spreadsheets.BatchUpdate(repo.cfg.GsheetID, &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}).Context(ctx).Do()
+		return errCall
+	})
+	repo.invalidate("")
+	if err != nil {
+		err = fmt.Errorf("err repo.service.Spreadsheets.BatchUpdate: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// SheetNames returns the names of the tabs of the spreadsheet.
+func (repo *gsheetRepo) SheetNames(ctx context.Context) ([]string, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetSheetNames", err)
+	}()
+
+	var spreadsheet *sheets.Spreadsheet
+	err = repo.call(ctx, func() (errCall error) {
+		spreadsheet, errCall = repo.service.Spreadsheets.Get(repo.cfg.GsheetID).Fields("sheets.properties.title").Context(ctx).Do()
+		return errCall
+	})
+	if err != nil {
+		err = fmt.Errorf("err repo.service.Spreadsheets.Get: %w", err)
+		return nil, err
+	}
+
+	names := make([]string, len(spreadsheet.Sheets))
+	for i, sheet := range spreadsheet.Sheets {
+		names[i] = sheet.Properties.Title
+	}
+
+	return names, nil
+}
+
+// DeleteRows removes the 1-based rows of the tab sheetName in one call, moving the rows below up.
+// Rows are numbered as before any of them is deleted.
+func (repo *gsheetRepo) DeleteRows(ctx context.Context, sheetName string, rows []int) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetDeleteRows", err)
+	}()
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var spreadsheet *sheets.Spreadsheet
+	err = repo.call(ctx, func() (errCall error) {
+		spreadsheet, errCall = repo.service.Spreadsheets.Get(repo.cfg.GsheetID).Fields("sheets.properties(title,sheetId)").Context(ctx).Do()
+		return errCall
+	})
+	if err != nil {
+		err = fmt.Errorf("err repo.service.Spreadsheets.Get: %w", err)
+		return err
+	}
+
+	var sheetID *int64
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties.Title == sheetName {
+			sheetID = &sheet.Properties.SheetId
+			break
+		}
+	}
+	if sheetID == nil {
+		err = fmt.Errorf("sheet %s: %w", sheetName, common.ErrNotFound)
+		return err
+	}
+
+	// The requests apply in order, deleting bottom-up keeps the numbers of the rows left to delete
+	sorted := append([]int(nil), rows...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	req := &sheets.BatchUpdateSpreadsheetRequest{}
+	for _, row := range sorted {
+		req.Requests = append(req.Requests, &sheets.Request{DeleteDimension: &sheets.DeleteDimensionRequest{Range: &sheets.DimensionRange{
+			SheetId:    *sheetID,
+			Dimension:  "ROWS",
+			StartIndex: int64(row - 1),
+			EndIndex:   int64(row),
+			// SheetId 0 is the first tab and would be dropped from the request
+			ForceSendFields: []string{"SheetId", "StartIndex"},
+		}}})
+	}
+	err = repo.callOnce(ctx, func() error {
+		_, errCall := repo.service.Spreadsheets.BatchUpdate(repo.cfg.GsheetID, req).Context(ctx).Do()
+		return errCall
+	})
+	repo.invalidate(sheetName)
+	if err != nil {
+		err = fmt.Errorf("err repo.service.Spreadsheets.BatchUpdate: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// BatchGetValues reads several ranges in one call, returning them in the order of valueRanges.
+// It returns common.ErrNotFound when one of the tabs doesn't exist.
+func (repo *gsheetRepo) BatchGetValues(ctx context.Context, valueRanges []string) ([]*sheets.ValueRange, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetBatchGetValues", err)
+	}()
+
+	if len(valueRanges) == 0 {
+		return nil, nil
+	}
+
+	var resp *sheets.BatchGetValuesResponse
+	err = repo.call(ctx, func() (errCall error) {
+		resp, errCall = repo.service.Spreadsheets.Values.BatchGet(repo.cfg.GsheetID).Ranges(valueRanges...).Context(ctx).Do()
+		return errCall
+	})
+	if err != nil {
+		err = fmt.Errorf("err repo.service.Spreadsheets.Values.BatchGet: %w", missingSheet(err))
+		return nil, err
+	}
+
+	return resp.ValueRanges, nil
+}
+
+// BatchUpdateValues overwrites the cells of several ranges in one call, each starting from its top left cell.
+func (repo *gsheetRepo) BatchUpdateValues(ctx context.Context, data []*sheets.ValueRange) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "GSheetBatchUpdateValues", err)
+	}()
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	req := &sheets.BatchUpdateValuesRequest{ValueInputOption: "USER_ENTERED", Data: data}
+	err = repo.call(ctx, func() error {
+		_, errCall := repo.service.Spreadsheets.Values.BatchUpdate(repo.cfg.GsheetID, req).Context(ctx).Do()
+		return errCall
+	})
+	for _, valueRange := range data {
+		repo.invalidate(rangeSheet(valueRange.Range))
+	}
+	if err != nil {
+		err = fmt.Errorf("err repo.service.Spreadsheets.Values.BatchUpdate: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// CachedSheetNames is SheetNames answered from the process cache when the tabs were listed less than maxAge ago.
+func (repo *gsheetRepo) CachedSheetNames(ctx context.Context, maxAge time.Duration) ([]string, error) {
+	if names, ok := gsheet.Cached(repo.cfg.GsheetID, sheetNamesKey, maxAge); ok {
+		return names.([]string), nil
+	}
+
+	readAt := time.Now()
+	names, err := repo.SheetNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gsheet.Store(repo.cfg.GsheetID, sheetNamesKey, "", names, readAt)
+
+	return names, nil
+}
+
+// CachedBatchGetValues is BatchGetValues answering the ranges read less than maxAge ago from the process cache
+// and reading the others in one call. Writes made through the repositories of the process drop the ranges of the
+// tabs written, edits made by hand or by another instance show once the cached ranges are maxAge old.
+// The values returned are shared and must not be modified.
+func (repo *gsheetRepo) CachedBatchGetValues(ctx context.Context, valueRanges []string, maxAge time.Duration) ([]*sheets.ValueRange, error) {
+	result := make([]*sheets.ValueRange, len(valueRanges))
+	var missing []string
+	var positions []int
+	for i, valueRange := range valueRanges {
+		if values, ok := gsheet.Cached(repo.cfg.GsheetID, valueRange, maxAge); ok {
+			result[i] = values.(*sheets.ValueRange)
+			continue
+		}
+		missing = append(missing, valueRange)
+		positions = append(positions, i)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	readAt := time.Now()
+	read, err := repo.BatchGetValues(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for j, values := range read {
+		result[positions[j]] = values
+		gsheet.Store(repo.cfg.GsheetID, missing[j], rangeSheet(missing[j]), values, readAt)
+	}
+
+	return result, nil
+}
+
+// invalidate drops the cached values of the tabs sheetNames, an empty name drops the cached list of tabs.
+func (repo *gsheetRepo) invalidate(sheetNames ...string) {
+	gsheet.Invalidate(repo.cfg.GsheetID, sheetNames...)
+}
+
+// call runs an API call within the rate limit of the spreadsheet, retrying it on rate limit and server errors.
+func (repo *gsheetRepo) call(ctx context.Context, fn func() error) error {
+	return gsheet.Do(ctx, repo.cfg.GsheetID, repo.rateLimit, fn)
+}
+
+// callOnce is call for API calls that must not be applied twice, retrying them on rate limit errors only.
+func (repo *gsheetRepo) callOnce(ctx context.Context, fn func() error) error {
+	return gsheet.DoOnce(ctx, repo.cfg.GsheetID, repo.rateLimit, fn)
+}
+
+// missingSheet adds common.ErrNotFound to err when it reports a range of a tab that doesn't exist.
+func missingSheet(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest && strings.Contains(apiErr.Message, "Unable to parse range") {
+		return fmt.Errorf("%w: %w", common.ErrNotFound, err)
+	}
+	return err
+}
+
+// sheetNamesKey is the cache key of the list of tabs.
+const sheetNamesKey = "sheets"
+
+// rangeSheet returns the tab of valueRange, e.g. "09" for "'09'!A:H".
+func rangeSheet(valueRange string) string {
+	sheetName, _, _ := strings.Cut(valueRange, "!")
+	if strings.HasPrefix(sheetName, "'") {
+		sheetName = strings.ReplaceAll(strings.Trim(sheetName, "'"), "''", "'")
+	}
+	return sheetName
+}
+
+// NewGSheetRepository creates a GSheetRepository for the GSHEET_ID spreadsheet, calling the API at most
+// GSHEET_RATE_LIMIT times a minute.
 func NewGSheetRepository(cfg *config.Config, service *sheets.Service) GSheetRepository {
-	return &gsheetRepo{cfg: cfg, service: service}
+	rateLimit := common.DefaultGsheetRateLimit
+	if limit, err := strconv.Atoi(cfg.GsheetRateLimit); err == nil && limit > 0 {
+		rateLimit = limit
+	}
+
+	return &gsheetRepo{cfg: cfg, service: service, rateLimit: rateLimit}
 }
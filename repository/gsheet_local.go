@@ -0,0 +1,427 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"google.golang.org/api/sheets/v4"
+)
+
+// a1Pattern splits an A1 range such as "'_rules'!A2:G" into its tab, start column, start row, end column and end row.
+var a1Pattern = regexp.MustCompile(`^(?:'((?:[^']|'')+)'|([^!]+))!([A-Z]+)(\d*)(?::([A-Z]+)(\d*))?$`)
+
+// localSheets is the document of the local spreadsheet: the cells of every tab as text,
+// the way the Sheets API returns formatted values.
+type localSheets struct {
+	Tabs map[string][][]string `json:"tabs"`
+}
+
+// a1Range is a parsed A1 range. Columns and rows are 0-based, an end of -1 is unbounded.
+type a1Range struct {
+	sheet    string
+	startCol int
+	startRow int
+	endCol   int
+	endRow   int
+}
+
+type localGSheetRepo struct {
+	file localFile
+}
+
+// AppendRow implements GSheetRepository. Rows are added after the last non-empty row of the tab.
+func (repo *localGSheetRepo) AppendRow(ctx context.Context, sheetName string, input [][]any) (string, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalGSheetAppendRow", err)
+	}()
+
+	var updatedRange string
+	var doc localSheets
+	err = repo.file.write(&doc, func() error {
+		tab, ok := doc.Tabs[sheetName]
+		if !ok {
+			return fmt.Errorf("sheet %s: %w", sheetName, common.ErrNotFound)
+		}
+
+		tab = trimRows(tab)
+		start := len(tab)
+		width := 1
+		for _, row := range input {
+			tab = append(tab, cellStrings(row))
+			if len(row) > width {
+				width = len(row)
+			}
+		}
+		doc.Tabs[sheetName] = tab
+		updatedRange = fmt.Sprintf("'%s'!A%d:%s%d", sheetName, start+1, columnLetters(width-1), len(tab))
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.write: %w", err)
+		return "", err
+	}
+
+	return updatedRange, nil
+}
+
+// GetValues implements GSheetRepository. Like the Sheets API, trailing empty rows and cells are left out.
+func (repo *localGSheetRepo) GetValues(ctx context.Context, valueRange string) (*sheets.ValueRange, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalGSheetGetValues", err)
+	}()
+
+	target, err := parseA1(valueRange)
+	if err != nil {
+		err = fmt.Errorf("err parseA1: %w", err)
+		return nil, err
+	}
+
+	resp := &sheets.ValueRange{Range: valueRange}
+	var doc localSheets
+	err = repo.file.read(&doc, func() error {
+		tab, ok := doc.Tabs[target.sheet]
+		if !ok {
+			return fmt.Errorf("sheet %s: %w", target.sheet, common.ErrNotFound)
+		}
+
+		var rows [][]string
+		for i := target.startRow; i < len(tab) && (target.endRow < 0 || i <= target.endRow); i++ {
+			var row []string
+			for j := target.startCol; j < len(tab[i]) && (target.endCol < 0 || j <= target.endCol); j++ {
+				row = append(row, tab[i][j])
+			}
+			rows = append(rows, row)
+		}
+
+		for _, row := range trimRows(rows) {
+			values := make([]any, len(row))
+			for j, cell := range row {
+				values[j] = cell
+			}
+			resp.Values = append(resp.Values, values)
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.read: %w", err)
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// UpdateValues implements GSheetRepository.
+func (repo *localGSheetRepo) UpdateValues(ctx context.Context, valueRange string, input [][]any) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalGSheetUpdateValues", err)
+	}()
+
+	target, err := parseA1(valueRange)
+	if err != nil {
+		err = fmt.Errorf("err parseA1: %w", err)
+		return err
+	}
+
+	var doc localSheets
+	err = repo.file.write(&doc, func() error {
+		tab, ok := doc.Tabs[target.sheet]
+		if !ok {
+			return fmt.Errorf("sheet %s: %w", target.sheet, common.ErrNotFound)
+		}
+
+		for i, row := range input {
+			for j, value := range row {
+				tab = setCell(tab, target.startRow+i, target.startCol+j, cellString(value))
+			}
+		}
+		doc.Tabs[target.sheet] = tab
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.write: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// ClearValues implements GSheetRepository.
+func (repo *localGSheetRepo) ClearValues(ctx context.Context, valueRange string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalGSheetClearValues", err)
+	}()
+
+	target, err := parseA1(valueRange)
+	if err != nil {
+		err = fmt.Errorf("err parseA1: %w", err)
+		return err
+	}
+
+	var doc localSheets
+	err = repo.file.write(&doc, func() error {
+		tab, ok := doc.Tabs[target.sheet]
+		if !ok {
+			return fmt.Errorf("sheet %s: %w", target.sheet, common.ErrNotFound)
+		}
+
+		for i := target.startRow; i < len(tab) && (target.endRow < 0 || i <= target.endRow); i++ {
+			for j := target.startCol; j < len(tab[i]) && (target.endCol < 0 || j <= target.endCol); j++ {
+				tab[i][j] = ""
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.write: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// EnsureSheet implements GSheetRepository.
+func (repo *localGSheetRepo) EnsureSheet(ctx context.Context, sheetName string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalGSheetEnsureSheet", err)
+	}()
+
+	var doc localSheets
+	err = repo.file.write(&doc, func() error {
+		if doc.Tabs == nil {
+			doc.Tabs = map[string][][]string{}
+		}
+		if _, ok := doc.Tabs[sheetName]; !ok {
+			doc.Tabs[sheetName] = [][]string{}
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.write: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// SheetNames implements GSheetRepository.
+func (repo *localGSheetRepo) SheetNames(ctx context.Context) ([]string, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalGSheetSheetNames", err)
+	}()
+
+	var names []string
+	var doc localSheets
+	err = repo.file.read(&doc, func() error {
+		for name := range doc.Tabs {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.read: %w", err)
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeleteRows implements GSheetRepository.
+func (repo *localGSheetRepo) DeleteRows(ctx context.Context, sheetName string, rows []int) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalGSheetDeleteRows", err)
+	}()
+
+	sorted := append([]int(nil), rows...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+
+	var doc localSheets
+	err = repo.file.write(&doc, func() error {
+		tab, ok := doc.Tabs[sheetName]
+		if !ok {
+			return fmt.Errorf("sheet %s: %w", sheetName, common.ErrNotFound)
+		}
+		for _, row := range sorted {
+			if row >= 1 && row <= len(tab) {
+				tab = append(tab[:row-1], tab[row:]...)
+			}
+		}
+		doc.Tabs[sheetName] = tab
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.write: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// EnsureSheets implements GSheetRepository.
+func (repo *localGSheetRepo) EnsureSheets(ctx context.Context, sheetNames []string) error {
+	for _, sheetName := range sheetNames {
+		if err := repo.EnsureSheet(ctx, sheetName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BatchGetValues implements GSheetRepository.
+func (repo *localGSheetRepo) BatchGetValues(ctx context.Context, valueRanges []string) ([]*sheets.ValueRange, error) {
+	resps := make([]*sheets.ValueRange, len(valueRanges))
+	for i, valueRange := range valueRanges {
+		resp, err := repo.GetValues(ctx, valueRange)
+		if err != nil {
+			return nil, err
+		}
+		resps[i] = resp
+	}
+	return resps, nil
+}
+
+// BatchUpdateValues implements GSheetRepository.
+func (repo *localGSheetRepo) BatchUpdateValues(ctx context.Context, data []*sheets.ValueRange) error {
+	for _, valueRange := range data {
+		if err := repo.UpdateValues(ctx, valueRange.Range, valueRange.Values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CachedSheetNames implements GSheetRepository. The file is read every time, there is no API to spare.
+func (repo *localGSheetRepo) CachedSheetNames(ctx context.Context, maxAge time.Duration) ([]string, error) {
+	return repo.SheetNames(ctx)
+}
+
+// CachedBatchGetValues implements GSheetRepository. The file is read every time, there is no API to spare.
+func (repo *localGSheetRepo) CachedBatchGetValues(ctx context.Context, valueRanges []string, maxAge time.Duration) ([]*sheets.ValueRange, error) {
+	return repo.BatchGetValues(ctx, valueRanges)
+}
+
+// parseA1 parses ranges such as "_rules!A:G", "'09'!A12:H14" or "_csv_profiles!B3".
+func parseA1(valueRange string) (a1Range, error) {
+	match := a1Pattern.FindStringSubmatch(valueRange)
+	if match == nil {
+		return a1Range{}, fmt.Errorf("unsupported range: %s", valueRange)
+	}
+
+	target := a1Range{sheet: match[2], startCol: columnNumber(match[3]), endRow: -1}
+	if match[1] != "" {
+		target.sheet = strings.ReplaceAll(match[1], "''", "'")
+	}
+	if match[4] != "" {
+		target.startRow, _ = strconv.Atoi(match[4])
+		target.startRow--
+	}
+
+	switch {
+	case match[5] == "" && match[4] != "":
+		// A single cell
+		target.endCol, target.endRow = target.startCol, target.startRow
+	case match[5] == "":
+		// A single column
+		target.endCol = target.startCol
+	default:
+		target.endCol = columnNumber(match[5])
+		if match[6] != "" {
+			target.endRow, _ = strconv.Atoi(match[6])
+			target.endRow--
+		}
+	}
+
+	return target, nil
+}
+
+// columnNumber converts a column letter such as "A" or "AB" to its 0-based index.
+func columnNumber(letters string) int {
+	number := 0
+	for _, r := range letters {
+		number = number*26 + int(r-'A') + 1
+	}
+	return number - 1
+}
+
+// columnLetters converts a 0-based column index to its letters, the inverse of columnNumber.
+func columnLetters(index int) string {
+	letters := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		letters = string(rune('A'+(index-1)%26)) + letters
+	}
+	return letters
+}
+
+// setCell writes value at row and col, growing tab as needed.
+func setCell(tab [][]string, row, col int, value string) [][]string {
+	for len(tab) <= row {
+		tab = append(tab, nil)
+	}
+	for len(tab[row]) <= col {
+		tab[row] = append(tab[row], "")
+	}
+	tab[row][col] = value
+	return tab
+}
+
+// trimRows drops the trailing empty cells of every row and the trailing empty rows.
+func trimRows(rows [][]string) [][]string {
+	for i, row := range rows {
+		end := len(row)
+		for end > 0 && row[end-1] == "" {
+			end--
+		}
+		rows[i] = row[:end]
+	}
+
+	end := len(rows)
+	for end > 0 && len(rows[end-1]) == 0 {
+		end--
+	}
+	return rows[:end]
+}
+
+func cellStrings(row []any) []string {
+	cells := make([]string, len(row))
+	for i, value := range row {
+		cells[i] = cellString(value)
+	}
+	return cells
+}
+
+// cellString formats a value the way the spreadsheet shows it, without exponents for big amounts.
+// Like a user entered value, a leading apostrophe only keeps the text from being parsed and is not stored.
+func cellString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimPrefix(v, "'")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// NewLocalGSheetRepository creates a GSheetRepository keeping the tabs in a JSON file at path,
+// for running without a Google spreadsheet.
+func NewLocalGSheetRepository(path string) GSheetRepository {
+	return &localGSheetRepo{file: localFile{path: path}}
+}
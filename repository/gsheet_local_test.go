@@ -0,0 +1,350 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/frasnym/go-expense-telebot/common"
+)
+
+func TestParseA1(t *testing.T) {
+	tests := []struct {
+		name       string
+		valueRange string
+		want       a1Range
+		wantErr    bool
+	}{
+		{
+			name:       "open-ended columns",
+			valueRange: "_rules!A:G",
+			want:       a1Range{sheet: "_rules", startCol: 0, startRow: 0, endCol: 6, endRow: -1},
+		},
+		{
+			name:       "open-ended column from a row",
+			valueRange: "_rules!A2:G",
+			want:       a1Range{sheet: "_rules", startCol: 0, startRow: 1, endCol: 6, endRow: -1},
+		},
+		{
+			name:       "single column",
+			valueRange: "_unparsed!A",
+			want:       a1Range{sheet: "_unparsed", startCol: 0, startRow: 0, endCol: 0, endRow: -1},
+		},
+		{
+			name:       "single cell",
+			valueRange: "_csv_profiles!B3",
+			want:       a1Range{sheet: "_csv_profiles", startCol: 1, startRow: 2, endCol: 1, endRow: 2},
+		},
+		{
+			name:       "bounded rows",
+			valueRange: "'09'!A12:H14",
+			want:       a1Range{sheet: "09", startCol: 0, startRow: 11, endCol: 7, endRow: 13},
+		},
+		{
+			name:       "quoted tab with an apostrophe",
+			valueRange: "'Bob''s tab'!A1:B2",
+			want:       a1Range{sheet: "Bob's tab", startCol: 0, startRow: 0, endCol: 1, endRow: 1},
+		},
+		{
+			name:       "unquoted tab with a space",
+			valueRange: "My tab!AB1",
+			want:       a1Range{sheet: "My tab", startCol: 27, startRow: 0, endCol: 27, endRow: 0},
+		},
+		{
+			name:       "missing tab",
+			valueRange: "A1:B2",
+			wantErr:    true,
+		},
+		{
+			name:       "lower case column",
+			valueRange: "09!a1",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseA1(tt.valueRange)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseA1(%q) error = %v, wantErr %v", tt.valueRange, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseA1(%q) = %+v, want %+v", tt.valueRange, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnLetters(t *testing.T) {
+	for _, letters := range []string{"A", "H", "Z", "AA", "AB", "AZ", "BA", "ZZ", "AAA"} {
+		if got := columnLetters(columnNumber(letters)); got != letters {
+			t.Errorf("columnLetters(columnNumber(%q)) = %q", letters, got)
+		}
+	}
+}
+
+// newTestSheet returns a local repository whose tab "09" holds rows.
+func newTestSheet(t *testing.T, rows [][]any) GSheetRepository {
+	t.Helper()
+
+	ctx := context.Background()
+	repo := NewLocalGSheetRepository(filepath.Join(t.TempDir(), "sheets.json"))
+	if err := repo.EnsureSheet(ctx, "09"); err != nil {
+		t.Fatalf("EnsureSheet: %v", err)
+	}
+	if len(rows) > 0 {
+		if err := repo.UpdateValues(ctx, "09!A1", rows); err != nil {
+			t.Fatalf("UpdateValues: %v", err)
+		}
+	}
+	return repo
+}
+
+// values reads valueRange as strings.
+func values(t *testing.T, repo GSheetRepository, valueRange string) [][]string {
+	t.Helper()
+
+	resp, err := repo.GetValues(context.Background(), valueRange)
+	if err != nil {
+		t.Fatalf("GetValues(%q): %v", valueRange, err)
+	}
+
+	rows := [][]string{}
+	for _, row := range resp.Values {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = cell.(string)
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+func TestLocalGSheetAppendRow(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  [][]any
+		input     [][]any
+		wantRange string
+		want      [][]string
+	}{
+		{
+			name:      "empty tab",
+			input:     [][]any{{"a", "b"}},
+			wantRange: "'09'!A1:B1",
+			want:      [][]string{{"a", "b"}},
+		},
+		{
+			name:      "after the last row",
+			existing:  [][]any{{"header"}, {"1", "2", "3"}},
+			input:     [][]any{{"x"}, {"y", "z"}},
+			wantRange: "'09'!A3:B4",
+			want:      [][]string{{"header"}, {"1", "2", "3"}, {"x"}, {"y", "z"}},
+		},
+		{
+			name:      "after trailing blank rows",
+			existing:  [][]any{{"header"}, {"1"}, {""}, {"", ""}},
+			input:     [][]any{{"x"}},
+			wantRange: "'09'!A3:A3",
+			want:      [][]string{{"header"}, {"1"}, {"x"}},
+		},
+		{
+			name:      "numbers and text kept as text",
+			input:     [][]any{{1500000.0, "'007", nil, true}},
+			wantRange: "'09'!A1:D1",
+			want:      [][]string{{"1500000", "007", "", "true"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestSheet(t, tt.existing)
+
+			updatedRange, err := repo.AppendRow(context.Background(), "09", tt.input)
+			if err != nil {
+				t.Fatalf("AppendRow: %v", err)
+			}
+			if updatedRange != tt.wantRange {
+				t.Errorf("AppendRow range = %q, want %q", updatedRange, tt.wantRange)
+			}
+			if got := values(t, repo, "09!A:Z"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalGSheetUpdateValues(t *testing.T) {
+	existing := [][]any{{"h1", "h2"}, {"a", "b"}}
+
+	tests := []struct {
+		name       string
+		valueRange string
+		input      [][]any
+		want       [][]string
+	}{
+		{
+			name:       "overwrite a cell",
+			valueRange: "09!B2",
+			input:      [][]any{{"B"}},
+			want:       [][]string{{"h1", "h2"}, {"a", "B"}},
+		},
+		{
+			name:       "grow the tab",
+			valueRange: "'09'!C4:D4",
+			input:      [][]any{{"c", "d"}},
+			want:       [][]string{{"h1", "h2"}, {"a", "b"}, {}, {"", "", "c", "d"}},
+		},
+		{
+			name:       "blank cells are read as missing",
+			valueRange: "09!A1:B2",
+			input:      [][]any{{"h1", "h2"}, {"", ""}},
+			want:       [][]string{{"h1", "h2"}},
+		},
+		{
+			name:       "apostrophe is not stored",
+			valueRange: "09!A2",
+			input:      [][]any{{"'mon"}},
+			want:       [][]string{{"h1", "h2"}, {"mon", "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestSheet(t, existing)
+
+			if err := repo.UpdateValues(context.Background(), tt.valueRange, tt.input); err != nil {
+				t.Fatalf("UpdateValues: %v", err)
+			}
+			if got := values(t, repo, "09!A:Z"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalGSheetRewriteKeepsApostropheText(t *testing.T) {
+	// Reading text written with an apostrophe and writing it back with one must not pile them up
+	repo := newTestSheet(t, [][]any{{"'mon"}})
+	for i := 0; i < 3; i++ {
+		cell := values(t, repo, "09!A1")[0][0]
+		if err := repo.UpdateValues(context.Background(), "09!A1", [][]any{{"'" + cell}}); err != nil {
+			t.Fatalf("UpdateValues: %v", err)
+		}
+	}
+
+	if got := values(t, repo, "09!A1")[0][0]; got != "mon" {
+		t.Errorf("cell = %q, want %q", got, "mon")
+	}
+}
+
+func TestLocalGSheetClearValues(t *testing.T) {
+	existing := [][]any{{"h1", "h2", "h3"}, {"a", "b", "c"}, {"d", "e", "f"}}
+
+	tests := []struct {
+		name       string
+		valueRange string
+		want       [][]string
+	}{
+		{
+			name:       "whole columns",
+			valueRange: "09!A:C",
+			want:       [][]string{},
+		},
+		{
+			name:       "rows below the header",
+			valueRange: "09!A2:C",
+			want:       [][]string{{"h1", "h2", "h3"}},
+		},
+		{
+			name:       "one column",
+			valueRange: "09!B:B",
+			want:       [][]string{{"h1", "", "h3"}, {"a", "", "c"}, {"d", "", "f"}},
+		},
+		{
+			name:       "a block",
+			valueRange: "'09'!B2:C2",
+			want:       [][]string{{"h1", "h2", "h3"}, {"a"}, {"d", "e", "f"}},
+		},
+		{
+			name:       "past the last row",
+			valueRange: "09!A10:C20",
+			want:       [][]string{{"h1", "h2", "h3"}, {"a", "b", "c"}, {"d", "e", "f"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestSheet(t, existing)
+
+			if err := repo.ClearValues(context.Background(), tt.valueRange); err != nil {
+				t.Fatalf("ClearValues: %v", err)
+			}
+			if got := values(t, repo, "09!A:Z"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalGSheetDeleteRows(t *testing.T) {
+	existing := [][]any{{"h"}, {"1"}, {"2"}, {"3"}, {"4"}}
+
+	tests := []struct {
+		name string
+		rows []int
+		want [][]string
+	}{
+		{
+			name: "one row",
+			rows: []int{3},
+			want: [][]string{{"h"}, {"1"}, {"3"}, {"4"}},
+		},
+		{
+			name: "rows in any order shift once",
+			rows: []int{2, 5, 3},
+			want: [][]string{{"h"}, {"3"}},
+		},
+		{
+			name: "rows out of the tab are ignored",
+			rows: []int{0, 6, 100},
+			want: [][]string{{"h"}, {"1"}, {"2"}, {"3"}, {"4"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestSheet(t, existing)
+
+			if err := repo.DeleteRows(context.Background(), "09", tt.rows); err != nil {
+				t.Fatalf("DeleteRows: %v", err)
+			}
+			if got := values(t, repo, "09!A:Z"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalGSheetMissingTab(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestSheet(t, nil)
+
+	if _, err := repo.GetValues(ctx, "10!A:H"); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("GetValues error = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.AppendRow(ctx, "10", [][]any{{"a"}}); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("AppendRow error = %v, want ErrNotFound", err)
+	}
+	if err := repo.UpdateValues(ctx, "10!A1", [][]any{{"a"}}); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("UpdateValues error = %v, want ErrNotFound", err)
+	}
+	if err := repo.ClearValues(ctx, "10!A:H"); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("ClearValues error = %v, want ErrNotFound", err)
+	}
+	if err := repo.DeleteRows(ctx, "10", []int{1}); !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("DeleteRows error = %v, want ErrNotFound", err)
+	}
+}
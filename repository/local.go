@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+)
+
+// localLocks serializes the access to each local file, as every request builds its own repositories.
+var localLocks sync.Map
+
+// localFile is a JSON document on disk, read and rewritten as a whole.
+type localFile struct {
+	path string
+}
+
+// update loads the document into v, applies fn and writes v back when fn succeeds.
+// A missing file leaves v untouched. The file is replaced atomically so a crash never leaves it half written.
+func (f localFile) update(v any, fn func() error, write bool) error {
+	lock, _ := localLocks.LoadOrStore(f.path, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	content, err := os.ReadFile(f.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("err os.ReadFile: %w", err)
+	}
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, v); err != nil {
+			return fmt.Errorf("err json.Unmarshal: %w", err)
+		}
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+	if !write {
+		return nil
+	}
+
+	content, err = json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("err json.MarshalIndent: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("err os.MkdirAll: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return fmt.Errorf("err os.WriteFile: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("err os.Rename: %w", err)
+	}
+
+	return nil
+}
+
+// read loads the document into v and applies fn without writing anything back.
+func (f localFile) read(v any, fn func() error) error {
+	return f.update(v, fn, false)
+}
+
+// write loads the document into v, applies fn and writes v back.
+func (f localFile) write(v any, fn func() error) error {
+	return f.update(v, fn, true)
+}
+
+// localExpenses is the document of the local expense store. Like the spreadsheet, expenses are kept
+// in month tabs whose first row is taken by a header, so rows are numbered the same way in both stores.
+type localExpenses struct {
+	Tabs map[string][]model.Expense `json:"tabs"`
+}
+
+type localExpenseStore struct {
+	file localFile
+}
+
+// Add implements ExpenseStore.
+func (store *localExpenseStore) Add(ctx context.Context, expenses []model.Expense) ([]model.StoredExpense, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalExpenseAdd", err)
+	}()
+
+	stored := make([]model.StoredExpense, len(expenses))
+	var doc localExpenses
+	err = store.file.write(&doc, func() error {
+		if doc.Tabs == nil {
+			doc.Tabs = map[string][]model.Expense{}
+		}
+		for i, expense := range expenses {
+			sheetName := common.SheetNameForPeriod(expense.Date)
+			doc.Tabs[sheetName] = append(doc.Tabs[sheetName], expense)
+			stored[i] = model.StoredExpense{Expense: expense, Sheet: sheetName, Row: len(doc.Tabs[sheetName]) + 1}
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.write: %w", err)
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// Query implements ExpenseStore.
+func (store *localExpenseStore) Query(ctx context.Context, query ExpenseQuery) ([]model.StoredExpense, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalExpenseQuery", err)
+	}()
+
+	var expenses []model.StoredExpense
+	var doc localExpenses
+	err = store.file.read(&doc, func() error {
+		for sheetName, tab := range doc.Tabs {
+			for i, expense := range tab {
+				if matchesQuery(expense, query) {
+					expenses = append(expenses, model.StoredExpense{Expense: expense, Sheet: sheetName, Row: i + 2})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.read: %w", err)
+		return nil, err
+	}
+
+	sortStored(expenses)
+	return expenses, nil
+}
+
+// Get implements ExpenseStore. It returns common.ErrNotFound when the row holds no expense.
+func (store *localExpenseStore) Get(ctx context.Context, sheet string, row int) (model.StoredExpense, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalExpenseGet", err)
+	}()
+
+	var expense model.StoredExpense
+	var doc localExpenses
+	err = store.file.read(&doc, func() error {
+		tab := doc.Tabs[sheet]
+		if row < 2 || row-2 >= len(tab) {
+			return common.ErrNotFound
+		}
+		expense = model.StoredExpense{Expense: tab[row-2], Sheet: sheet, Row: row}
+		return nil
+	})
+	if errors.Is(err, common.ErrNotFound) {
+		return model.StoredExpense{}, err
+	}
+	if err != nil {
+		err = fmt.Errorf("err file.read: %w", err)
+		return model.StoredExpense{}, err
+	}
+
+	return expense, nil
+}
+
+// Update implements ExpenseStore.
+func (store *localExpenseStore) Update(ctx context.Context, expense model.StoredExpense) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalExpenseUpdate", err)
+	}()
+
+	var doc localExpenses
+	err = store.file.write(&doc, func() error {
+		tab := doc.Tabs[expense.Sheet]
+		if expense.Row < 2 || expense.Row-2 >= len(tab) {
+			return common.ErrNotFound
+		}
+		tab[expense.Row-2] = expense.Expense
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.write: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Delete implements ExpenseStore. Rows are numbered as before any of them is deleted, the rows below move up
+// like in the spreadsheet.
+func (store *localExpenseStore) Delete(ctx context.Context, expenses []model.StoredExpense) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalExpenseDelete", err)
+	}()
+
+	var doc localExpenses
+	err = store.file.write(&doc, func() error {
+		sorted := append([]model.StoredExpense(nil), expenses...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Row > sorted[j].Row })
+		for _, expense := range sorted {
+			tab := doc.Tabs[expense.Sheet]
+			if expense.Row < 2 || expense.Row-2 >= len(tab) {
+				return common.ErrNotFound
+			}
+			doc.Tabs[expense.Sheet] = append(tab[:expense.Row-2], tab[expense.Row-1:]...)
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.write: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Periods implements ExpenseStore.
+func (store *localExpenseStore) Periods(ctx context.Context) ([]time.Time, error) {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "LocalExpensePeriods", err)
+	}()
+
+	seen := map[time.Time]bool{}
+	var doc localExpenses
+	err = store.file.read(&doc, func() error {
+		for _, tab := range doc.Tabs {
+			for _, expense := range tab {
+				seen[periodOf(expense.Date)] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("err file.read: %w", err)
+		return nil, err
+	}
+
+	return sortedPeriods(seen), nil
+}
+
+// sortStored orders expenses by tab and row, the order the spreadsheet store returns them in.
+func sortStored(expenses []model.StoredExpense) {
+	sort.Slice(expenses, func(i, j int) bool {
+		if expenses[i].Sheet != expenses[j].Sheet {
+			return expenses[i].Sheet < expenses[j].Sheet
+		}
+		return expenses[i].Row < expenses[j].Row
+	})
+}
+
+// NewLocalExpenseStore creates an ExpenseStore keeping expenses in a JSON file at path.
+func NewLocalExpenseStore(path string) ExpenseStore {
+	return &localExpenseStore{file: localFile{path: path}}
+}
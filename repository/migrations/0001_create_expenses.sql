@@ -0,0 +1,16 @@
+-- Expenses, one row per month tab row of the spreadsheet store.
+-- period is the first day of the month of spent_at, the month tab the expense belongs to.
+CREATE TABLE expenses (
+    id         BIGSERIAL PRIMARY KEY,
+    spent_at   TIMESTAMP NOT NULL,
+    period     DATE NOT NULL,
+    category   TEXT NOT NULL DEFAULT '',
+    amount     NUMERIC(20, 4) NOT NULL,
+    note       TEXT NOT NULL DEFAULT '',
+    label      TEXT NOT NULL DEFAULT '',
+    wallet     TEXT NOT NULL DEFAULT '',
+    currency   TEXT NOT NULL DEFAULT '',
+    ref        TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
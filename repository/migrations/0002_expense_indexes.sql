@@ -0,0 +1,6 @@
+-- Reports and budgets read a month at a time, optionally of a single category.
+CREATE INDEX expenses_period_idx ON expenses (period, spent_at);
+CREATE INDEX expenses_category_period_idx ON expenses (lower(category), period);
+
+-- Imports skip refs already stored.
+CREATE INDEX expenses_ref_idx ON expenses (ref) WHERE ref <> '';
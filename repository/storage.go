@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/config"
+	"github.com/frasnym/go-expense-telebot/pkg/gsheet"
+)
+
+// NewStorage creates the spreadsheet repository and the expense store of the STORAGE backend.
+// The local backend needs no Google credentials, the spreadsheet is only connected to for the gsheet backend
+// and, when GSHEET_ID is set or STORAGE_MIRROR is gsheet, for the postgres backend.
+func NewStorage(cfg *config.Config) (GSheetRepository, ExpenseStore, error) {
+	switch cfg.Storage {
+	case "", common.StorageGSheet:
+		gsheetRepo := NewGSheetRepository(cfg, gsheet.GetService())
+		return gsheetRepo, NewGSheetExpenseStore(gsheetRepo), nil
+
+	case common.StorageLocal:
+		return NewLocalGSheetRepository(filepath.Join(localDataDir(cfg), "sheets.json")), NewLocalExpenseStore(filepath.Join(localDataDir(cfg), "expenses.json")), nil
+
+	case common.StoragePostgres:
+		if cfg.DatabaseURL == "" {
+			return nil, nil, fmt.Errorf("DATABASE_URL is required for the %s storage", cfg.Storage)
+		}
+		db, err := openPostgres(context.Background(), cfg.DatabaseURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("err openPostgres: %w", err)
+		}
+
+		// Rules, budgets and the other settings tabs stay in the spreadsheet, or in a local file without one
+		var gsheetRepo GSheetRepository
+		if cfg.GsheetID != "" {
+			gsheetRepo = NewGSheetRepository(cfg, gsheet.GetService())
+		} else {
+			gsheetRepo = NewLocalGSheetRepository(filepath.Join(localDataDir(cfg), "sheets.json"))
+		}
+
+		var mirror ExpenseStore
+		switch cfg.StorageMirror {
+		case "":
+		case common.StorageGSheet:
+			if cfg.GsheetID == "" {
+				return nil, nil, fmt.Errorf("GSHEET_ID is required to mirror to %s", cfg.StorageMirror)
+			}
+			mirror = NewGSheetExpenseStore(gsheetRepo)
+		default:
+			return nil, nil, fmt.Errorf("unknown storage mirror: %s", cfg.StorageMirror)
+		}
+
+		return gsheetRepo, NewPostgresExpenseStore(db, mirror), nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown storage: %s", cfg.Storage)
+	}
+}
+
+func localDataDir(cfg *config.Config) string {
+	if cfg.LocalDataDir == "" {
+		return common.DefaultLocalDataDir
+	}
+	return cfg.LocalDataDir
+}
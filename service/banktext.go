@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/banktext"
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+const bankUsage = "Usage: /bank <notification text>\n" +
+	"Records the expense of a bank notification. Text no template recognizes is kept so a template can be added, see /" + common.CommandTemplates
+
+// dotThousands matches amounts grouped with dots and no decimals, e.g. "150.000" as Indonesian banks write them.
+var dotThousands = regexp.MustCompile(`^\d{1,3}(\.\d{3})+$`)
+
+// BankTextService is an interface for recording expenses from forwarded or pasted bank notifications.
+type BankTextService interface {
+	Parse(ctx context.Context, chatID int64, text string, received time.Time) error
+	Record(ctx context.Context, chatID int64, text string, received time.Time) error
+	Templates(ctx context.Context, chatID int64) error
+}
+
+type bankTextSvc struct {
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+	budgetSvc    BudgetService
+	suggestSvc   SuggestService
+}
+
+// Parse records the expense described by text sent outside of any command, when a template recognizes it as a bank notification.
+// Other text is left alone and common.ErrNoTemplateMatch returned.
+func (s *bankTextSvc) Parse(ctx context.Context, chatID int64, text string, received time.Time) error {
+	var err error
+	defer func() {
+		if errors.Is(err, common.ErrNoTemplateMatch) {
+			logger.LogService(ctx, "BankTextParse", nil)
+			return
+		}
+		logger.LogService(ctx, "BankTextParse", err)
+	}()
+
+	err = s.parse(ctx, chatID, text, received, false)
+	return err
+}
+
+// Record records the expense described by a bank notification sent with /bank.
+// Messages no template recognizes are kept in the unparsed tab so a template can be written for them.
+func (s *bankTextSvc) Record(ctx context.Context, chatID int64, text string, received time.Time) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "BankTextRecord", err)
+	}()
+
+	if strings.TrimSpace(text) == "" {
+		_, err = s.botRepo.SendTextMessage(ctx, chatID, bankUsage)
+		if err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return err
+	}
+
+	err = s.parse(ctx, chatID, text, received, true)
+	return err
+}
+
+// parse records the expense described by a bank notification. Messages no template recognizes fail with
+// common.ErrNoTemplateMatch, unless keepUnrecognized, which saves them in the unparsed tab.
+func (s *bankTextSvc) parse(ctx context.Context, chatID int64, text string, received time.Time, keepUnrecognized bool) error {
+	templates, err := s.getTemplates(ctx)
+	if err != nil {
+		return fmt.Errorf("err getTemplates: %w", err)
+	}
+
+	match, ok := banktext.Parse(text, templates)
+	if !ok && !keepUnrecognized {
+		return common.ErrNoTemplateMatch
+	}
+	if !ok {
+		logger.Warn(ctx, fmt.Sprintf("unrecognized bank notification: %s", text))
+		if err := s.saveUnparsed(ctx, chatID, text, received); err != nil {
+			return fmt.Errorf("err saveUnparsed: %w", err)
+		}
+
+		_, err := s.botRepo.SendTextMessage(ctx, chatID, fmt.Sprintf("Unrecognized message, it was saved in the %s tab so a template can be added, see /%s", common.SheetUnparsed, common.CommandTemplates))
+		if err != nil {
+			return fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return nil
+	}
+
+	expense, notes := bankTextExpense(match, text, received)
+	result, err := importExpenses(ctx, s.gsheetRepo, s.expenseStore, s.budgetSvc, s.suggestSvc, chatID, importSource{}, []model.Expense{expense})
+	if err != nil {
+		return fmt.Errorf("err importExpenses: %w", err)
+	}
+
+	replyTxt := fmt.Sprintf("Recorded %s %s at %s on %s (%s/%s)", expense.Currency, common.FormatAmount(expense.Amount),
+		expense.Note, expense.Date.Format("2006-01-02"), match.Template.Bank, match.Template.Name)
+	for _, v := range append(notes, result...) {
+		replyTxt = fmt.Sprintf("%s\n- %s", replyTxt, v)
+	}
+
+	// Locate the row, new or already recorded, so replying to the confirmation corrects it
+	stored, errFind := s.findByRef(ctx, expense)
+	if errFind != nil {
+		logger.Warn(ctx, fmt.Sprintf("findByRef: %s", errFind.Error()))
+	} else {
+		replyTxt = fmt.Sprintf("%s\n\n%s\n%s", replyTxt, correctUsage, expenseRef(stored, ""))
+	}
+
+	if _, err := s.botRepo.SendTextMessage(ctx, chatID, replyTxt); err != nil {
+		return fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+	}
+
+	return nil
+}
+
+// findByRef returns the stored row of expense, found by its ref in its month tab.
+func (s *bankTextSvc) findByRef(ctx context.Context, expense model.Expense) (model.StoredExpense, error) {
+	period := time.Date(expense.Date.Year(), expense.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	stored, err := s.expenseStore.Query(ctx, repository.ExpenseQuery{Periods: []time.Time{period}})
+	if err != nil {
+		return model.StoredExpense{}, fmt.Errorf("err expenseStore.Query: %w", err)
+	}
+
+	for _, row := range stored {
+		if row.Ref == expense.Ref {
+			return row, nil
+		}
+	}
+
+	return model.StoredExpense{}, common.ErrNotFound
+}
+
+// Templates lists the templates in the order they are tried and explains how to add one.
+func (s *bankTextSvc) Templates(ctx context.Context, chatID int64) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "BankTextTemplates", err)
+	}()
+
+	templates, err := s.getTemplates(ctx)
+	if err != nil {
+		err = fmt.Errorf("err getTemplates: %w", err)
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Bank notification templates, tried in order:")
+	for _, template := range templates {
+		sb.WriteString(fmt.Sprintf("\n- %s/%s", template.Bank, template.Name))
+		if template.Credit {
+			sb.WriteString(" (credit)")
+		}
+	}
+
+	if err = s.gsheetRepo.EnsureSheet(ctx, common.SheetUnparsed); err != nil {
+		err = fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+		return err
+	}
+	gsheetValues, err := s.gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:A", common.SheetUnparsed))
+	if err != nil {
+		err = fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+		return err
+	}
+	if unparsed := len(gsheetValues.Values) - 1; unparsed > 0 {
+		sb.WriteString(fmt.Sprintf("\n\n%d unrecognized messages in the %s tab.", unparsed, common.SheetUnparsed))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n\nAdd templates to the %s tab as bank | name | pattern | date layouts | credit. "+
+		"The pattern is a regular expression capturing amount, and optionally currency, merchant, date and account, "+
+		"e.g. (?P<amount>...). Date layouts are comma separated Go layouts such as 2/1/2006.\n\n"+
+		"Messages a template recognizes are recorded when pasted or forwarded, send others with /%s <text> to keep them for a new template.", common.SheetTemplates, common.CommandBank))
+
+	_, err = s.botRepo.SendTextMessage(ctx, chatID, sb.String())
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// getTemplates returns the templates of the templates tab followed by the built-in ones.
+// Invalid templates are skipped. Every plain text message is tried, so a missing tab is not created.
+func (s *bankTextSvc) getTemplates(ctx context.Context) ([]banktext.Template, error) {
+	gsheetValues, err := s.gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:E", common.SheetTemplates))
+	if errors.Is(err, common.ErrNotFound) {
+		return banktext.Defaults(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	var templates []banktext.Template
+	for i, row := range gsheetValues.Values {
+		// Skip header
+		if i == 0 || len(row) < 3 {
+			continue
+		}
+
+		cells := make([]string, 5)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = fmt.Sprint(row[j])
+			}
+		}
+
+		template, err := banktext.NewTemplate(cells[0], cells[1], cells[2], cells[3], strings.EqualFold(cells[4], "true"))
+		if err != nil {
+			logger.Warn(ctx, fmt.Sprintf("invalid template %s/%s: %s", cells[0], cells[1], err.Error()))
+			continue
+		}
+		templates = append(templates, template)
+	}
+
+	return append(templates, banktext.Defaults()...), nil
+}
+
+// saveUnparsed appends a message no template recognizes to the unparsed tab.
+func (s *bankTextSvc) saveUnparsed(ctx context.Context, chatID int64, text string, received time.Time) error {
+	if err := s.gsheetRepo.EnsureSheet(ctx, common.SheetUnparsed); err != nil {
+		return fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+
+	gsheetValues, err := s.gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A1", common.SheetUnparsed))
+	if err != nil {
+		return fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	var rows [][]any
+	if len(gsheetValues.Values) == 0 {
+		rows = append(rows, []any{"received", "chat_id", "text"})
+	}
+	rows = append(rows, []any{received.Format(common.SheetDateLayout), fmt.Sprint(chatID), text})
+
+	if _, err := s.gsheetRepo.AppendRow(ctx, common.SheetUnparsed, rows); err != nil {
+		return fmt.Errorf("err gsheetRepo.AppendRow: %w", err)
+	}
+
+	return nil
+}
+
+// bankTextExpense builds the expense of a matched notification and returns notes about the fields that fell back to defaults.
+func bankTextExpense(match banktext.Match, text string, received time.Time) (model.Expense, []string) {
+	var notes []string
+
+	decimalComma := isDecimalComma([]string{match.Amount}) || dotThousands.MatchString(match.Amount)
+	amount, err := parseCSVAmount(match.Amount, decimalComma)
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("unreadable amount %s", match.Amount))
+	}
+	if match.Template.Credit {
+		amount = -amount
+	}
+
+	currency := strings.ToUpper(match.Currency)
+	if currency == "RP" {
+		currency = "IDR"
+	}
+
+	date, ok := notificationDate(match.Date, match.Template.DateLayouts, received)
+	if !ok {
+		date = received
+		if match.Date != "" {
+			notes = append(notes, fmt.Sprintf("unreadable date %s, using the date received", match.Date))
+		}
+	}
+
+	return model.Expense{
+		Date:     date,
+		Amount:   amount,
+		Note:     match.Merchant,
+		Wallet:   match.Account,
+		Currency: currency,
+		Ref:      "banktext:" + common.Fingerprint(strings.Fields(text)...),
+	}, notes
+}
+
+// notificationDate parses the date of a notification. Dates without a year are placed
+// in the year that makes them closest before the moment the message was received.
+func notificationDate(value string, layouts []string, received time.Time) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	for _, layout := range layouts {
+		date, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+
+		if date.Year() == 0 {
+			date = date.AddDate(received.Year(), 0, 0)
+			if date.After(received.AddDate(0, 0, 1)) {
+				date = date.AddDate(-1, 0, 0)
+			}
+		}
+		return date, true
+	}
+
+	return time.Time{}, false
+}
+
+// NewBankTextService creates a new BankTextService using the provided repositories, budget and suggestion services.
+func NewBankTextService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore, budgetSvc BudgetService, suggestSvc SuggestService) BankTextService {
+	return &bankTextSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore, budgetSvc: budgetSvc, suggestSvc: suggestSvc}
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/common/notification"
+	"github.com/frasnym/go-expense-telebot/config"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/category"
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+// defaultBudgetThresholds are the alert percentages used when BUDGET_ALERT_THRESHOLDS is not set.
+var defaultBudgetThresholds = []float64{80, 100}
+
+const budgetUsage = "Usage:\n/budget - list budgets\n/budget set <category> <amount>\n/budget del <category>"
+
+// BudgetService is an interface for managing monthly budgets per category.
+type BudgetService interface {
+	Command(ctx context.Context, chatID int64, args string) error
+	Check(ctx context.Context, chatID int64, period time.Time, added []model.Expense) error
+}
+
+type budgetSvc struct {
+	cfg          *config.Config
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+
+	notificationClient notification.NotificationClient
+}
+
+// Command handles the /budget subcommands: list, set and del.
+func (s *budgetSvc) Command(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "BudgetCommand", err)
+	}()
+
+	fields := strings.Fields(args)
+	replyTxt := budgetUsage
+
+	switch {
+	case len(fields) == 0 || fields[0] == "list":
+		budgets, errRead := s.getBudgets(ctx)
+		if errRead != nil {
+			err = fmt.Errorf("err getBudgets: %w", errRead)
+			return err
+		}
+		replyTxt = formatBudgets(budgets)
+
+	case fields[0] == "set" && len(fields) >= 3:
+		category := strings.Join(fields[1:len(fields)-1], " ")
+		amount, errAmount := common.ParseAmount(fields[len(fields)-1])
+		if errAmount != nil || amount <= 0 {
+			replyTxt = fmt.Sprintf("Invalid amount: %s", fields[len(fields)-1])
+			break
+		}
+
+		if err = s.updateBudgets(ctx, func(budgets map[string]float64) {
+			deleteBudget(budgets, category)
+			budgets[category] = amount
+		}); err != nil {
+			err = fmt.Errorf("err updateBudgets: %w", err)
+			return err
+		}
+		replyTxt = fmt.Sprintf("Budget for %s set to %s", category, common.FormatAmount(amount))
+
+	case fields[0] == "del" && len(fields) >= 2:
+		category := strings.Join(fields[1:], " ")
+		if err = s.updateBudgets(ctx, func(budgets map[string]float64) { deleteBudget(budgets, category) }); err != nil {
+			err = fmt.Errorf("err updateBudgets: %w", err)
+			return err
+		}
+		replyTxt = fmt.Sprintf("Budget for %s removed", category)
+	}
+
+	_, err = s.botRepo.SendTextMessage(ctx, chatID, replyTxt)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Check compares the spend-to-date of period against the budgets after added expenses were written,
+// and sends an alert for every threshold crossed by those expenses.
+func (s *budgetSvc) Check(ctx context.Context, chatID int64, period time.Time, added []model.Expense) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "BudgetCheck", err)
+	}()
+
+	budgets, err := s.getBudgets(ctx)
+	if err != nil {
+		err = fmt.Errorf("err getBudgets: %w", err)
+		return err
+	}
+	if len(budgets) == 0 {
+		return nil
+	}
+
+	expenses, err := readPeriodExpenses(ctx, s.expenseStore, period)
+	if err != nil {
+		err = fmt.Errorf("err readPeriodExpenses: %w", err)
+		return err
+	}
+
+	// A budget of a parent category covers the spend of its children
+	tree, errTree := getCategoryTree(ctx, s.gsheetRepo)
+	if errTree != nil {
+		logger.Warn(ctx, fmt.Sprintf("getCategoryTree: %s", errTree.Error()))
+	}
+	spent := categorySpend(tree, expenses)
+	addedSpent := categorySpend(tree, added)
+
+	alertChatID := chatID
+	if s.cfg.BudgetAlertChatID != "" {
+		if groupID, errParse := strconv.ParseInt(s.cfg.BudgetAlertChatID, 10, 64); errParse == nil {
+			alertChatID = groupID
+		} else {
+			logger.Warn(ctx, fmt.Sprintf("invalid BUDGET_ALERT_CHAT_ID: %s", s.cfg.BudgetAlertChatID))
+		}
+	}
+
+	for category, limit := range budgets {
+		after := spent[strings.ToLower(category)]
+		before := after - addedSpent[strings.ToLower(category)]
+
+		// Only alert on the highest threshold crossed by this batch
+		crossed := 0.0
+		for _, threshold := range s.thresholds(ctx) {
+			target := limit * threshold / 100
+			if before < target && after >= target {
+				crossed = threshold
+			}
+		}
+		if crossed == 0 {
+			continue
+		}
+
+		msg := fmt.Sprintf("Budget alert %s: %s reached %.0f%% of its budget (%s of %s)",
+			period.Format(common.PeriodLayout), category, after/limit*100, common.FormatAmount(after), common.FormatAmount(limit))
+		if errNotify := s.notificationClient.NotifyChat(ctx, alertChatID, msg); errNotify != nil {
+			logger.Warn(ctx, fmt.Sprintf("notificationClient.NotifyChat: %s", errNotify.Error()))
+		}
+	}
+
+	return nil
+}
+
+// categorySpend sums expenses by lower-cased category, adding each expense to the ancestors of its category as well.
+func categorySpend(tree *category.Tree, expenses []model.Expense) map[string]float64 {
+	spent := map[string]float64{}
+	for _, expense := range expenses {
+		for _, name := range tree.Path(expense.Category) {
+			spent[strings.ToLower(name)] += expense.Amount
+		}
+	}
+	return spent
+}
+
+// thresholds returns the configured alert percentages in ascending order.
+func (s *budgetSvc) thresholds(ctx context.Context) []float64 {
+	if s.cfg.BudgetAlertThresholds == "" {
+		return defaultBudgetThresholds
+	}
+
+	var thresholds []float64
+	for _, v := range strings.Split(s.cfg.BudgetAlertThresholds, ",") {
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || threshold <= 0 {
+			logger.Warn(ctx, fmt.Sprintf("invalid budget threshold: %s", v))
+			continue
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	sort.Float64s(thresholds)
+
+	return thresholds
+}
+
+// getBudgets reads the budgets tab as category -> monthly limit. There are no budgets until the tab is created.
+func (s *budgetSvc) getBudgets(ctx context.Context) (map[string]float64, error) {
+	budgets, _, err := s.readBudgets(ctx)
+	return budgets, err
+}
+
+// readBudgets is getBudgets also returning the number of rows of the tab.
+func (s *budgetSvc) readBudgets(ctx context.Context) (map[string]float64, int, error) {
+	gsheetValues, err := s.gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:B", common.SheetBudgets))
+	if errors.Is(err, common.ErrNotFound) {
+		return map[string]float64{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	budgets := map[string]float64{}
+	for _, row := range gsheetValues.Values {
+		if len(row) < 2 {
+			continue
+		}
+
+		// Header and malformed rows don't parse as an amount
+		amount, err := common.ParseAmount(fmt.Sprint(row[1]))
+		if err != nil {
+			continue
+		}
+		budgets[fmt.Sprint(row[0])] = amount
+	}
+
+	return budgets, len(gsheetValues.Values), nil
+}
+
+// updateBudgets applies fn to the stored budgets and rewrites the budgets tab, creating it when missing.
+func (s *budgetSvc) updateBudgets(ctx context.Context, fn func(budgets map[string]float64)) error {
+	budgets, previousRows, err := s.readBudgets(ctx)
+	if err != nil {
+		return err
+	}
+
+	fn(budgets)
+
+	categories := make([]string, 0, len(budgets))
+	for category := range budgets {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	rows := [][]any{{"category", "amount"}}
+	for _, category := range categories {
+		rows = append(rows, []any{category, budgets[category]})
+	}
+
+	if err := s.gsheetRepo.EnsureSheet(ctx, common.SheetBudgets); err != nil {
+		return fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+	if err := rewriteTab(ctx, s.gsheetRepo, common.SheetBudgets, previousRows, rows); err != nil {
+		return fmt.Errorf("err rewriteTab: %w", err)
+	}
+
+	return nil
+}
+
+// deleteBudget removes the budget of category, ignoring case.
+func deleteBudget(budgets map[string]float64, category string) {
+	for existing := range budgets {
+		if strings.EqualFold(existing, category) {
+			delete(budgets, existing)
+		}
+	}
+}
+
+func formatBudgets(budgets map[string]float64) string {
+	if len(budgets) == 0 {
+		return "No budgets set\n\n" + budgetUsage
+	}
+
+	categories := make([]string, 0, len(budgets))
+	for category := range budgets {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	msg := "Monthly budgets"
+	for _, category := range categories {
+		msg = fmt.Sprintf("%s\n- %s: %s", msg, category, common.FormatAmount(budgets[category]))
+	}
+
+	return msg
+}
+
+// NewBudgetService creates a new BudgetService using the provided repositories and notification client.
+func NewBudgetService(cfg *config.Config, botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore, notificationClient *notification.NotificationClient) BudgetService {
+	return &budgetSvc{cfg: cfg, botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore, notificationClient: *notificationClient}
+}
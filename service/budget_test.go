@@ -0,0 +1,56 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/category"
+)
+
+func TestCategorySpend(t *testing.T) {
+	tree, err := category.New(map[string]string{
+		"Food":        "",
+		"Groceries":   "Food",
+		"Restaurants": "Food",
+		"Fast food":   "Restaurants",
+		"Transport":   "",
+	})
+	if err != nil {
+		t.Fatalf("category.New: %v", err)
+	}
+
+	expenses := []model.Expense{
+		{Category: "groceries", Amount: 100},
+		{Category: "Fast food", Amount: 50},
+		{Category: "Food", Amount: 10},
+		{Category: "Transport", Amount: 20},
+		{Category: "Gifts", Amount: 5},
+		{Category: "Groceries", Amount: -30},
+	}
+
+	tests := []struct {
+		name string
+		tree *category.Tree
+		want map[string]float64
+	}{
+		{
+			name: "children roll up to their ancestors",
+			tree: tree,
+			want: map[string]float64{"food": 130, "groceries": 70, "restaurants": 50, "fast food": 50, "transport": 20, "gifts": 5},
+		},
+		{
+			name: "without a hierarchy",
+			tree: nil,
+			want: map[string]float64{"groceries": 70, "fast food": 50, "food": 10, "transport": 20, "gifts": 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := categorySpend(tt.tree, expenses); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("categorySpend = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
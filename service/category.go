@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/category"
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+const categoriesUsage = "Usage:\n/categories - show the category hierarchy\n/categories check [YYYY-MM] - list the categories of a month missing from the hierarchy\n\n" +
+	"Categories live in the " + common.SheetCategories + " tab as category | parent, top-level categories have no parent."
+
+// CategoryService is an interface for the category hierarchy.
+type CategoryService interface {
+	Command(ctx context.Context, chatID int64, args string) error
+}
+
+type categorySvc struct {
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+}
+
+// Command handles the /categories subcommands: show and check.
+func (s *categorySvc) Command(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "CategoryCommand", err)
+	}()
+
+	fields := strings.Fields(args)
+	replyTxt := categoriesUsage
+
+	switch {
+	case len(fields) == 0:
+		tree, errRead := getCategoryTree(ctx, s.gsheetRepo)
+		if errRead != nil {
+			err = fmt.Errorf("err getCategoryTree: %w", errRead)
+			return err
+		}
+		replyTxt = formatCategoryTree(tree)
+
+	case fields[0] == "check" && len(fields) <= 2:
+		period := ""
+		if len(fields) == 2 {
+			period = fields[1]
+		}
+		month, errPeriod := common.ParsePeriod(period)
+		if errPeriod != nil {
+			replyTxt = fmt.Sprintf("Invalid period: %s\n\n%s", period, categoriesUsage)
+			break
+		}
+
+		replyTxt, err = s.check(ctx, month)
+		if err != nil {
+			err = fmt.Errorf("err check: %w", err)
+			return err
+		}
+	}
+
+	_, err = s.botRepo.SendTextMessage(ctx, chatID, replyTxt)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// check validates the categories of a month tab, including the rows entered by hand.
+func (s *categorySvc) check(ctx context.Context, month time.Time) (string, error) {
+	tree, err := getCategoryTree(ctx, s.gsheetRepo)
+	if err != nil {
+		return "", fmt.Errorf("err getCategoryTree: %w", err)
+	}
+	if tree.Empty() {
+		return "No categories defined yet\n\n" + categoriesUsage, nil
+	}
+
+	expenses, err := readPeriodExpenses(ctx, s.expenseStore, month)
+	if err != nil {
+		return "", fmt.Errorf("err readPeriodExpenses: %w", err)
+	}
+
+	counts := map[string]int{}
+	for _, expense := range expenses {
+		if _, ok := tree.Lookup(expense.Category); !ok {
+			counts[expense.Category]++
+		}
+	}
+	if len(counts) == 0 {
+		return fmt.Sprintf("All categories of %s are in the hierarchy", month.Format(common.PeriodLayout)), nil
+	}
+
+	missing := make([]string, 0, len(counts))
+	for name := range counts {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+
+	msg := fmt.Sprintf("Categories of %s missing from the %s tab:", month.Format(common.PeriodLayout), common.SheetCategories)
+	for _, name := range missing {
+		if name == "" {
+			msg = fmt.Sprintf("%s\n- (empty): %d rows", msg, counts[name])
+			continue
+		}
+		msg = fmt.Sprintf("%s\n- %s: %d rows", msg, name, counts[name])
+	}
+
+	return msg, nil
+}
+
+// getCategoryTree reads the hierarchy of the categories tab. An empty tab gives an empty tree.
+func getCategoryTree(ctx context.Context, gsheetRepo repository.GSheetRepository) (*category.Tree, error) {
+	if err := gsheetRepo.EnsureSheet(ctx, common.SheetCategories); err != nil {
+		return nil, fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+
+	gsheetValues, err := gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:B", common.SheetCategories))
+	if err != nil {
+		return nil, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	parents := map[string]string{}
+	for i, row := range gsheetValues.Values {
+		// Skip header
+		if i == 0 || len(row) == 0 {
+			continue
+		}
+
+		name := strings.TrimSpace(fmt.Sprint(row[0]))
+		if name == "" {
+			continue
+		}
+		parent := ""
+		if len(row) > 1 {
+			parent = strings.TrimSpace(fmt.Sprint(row[1]))
+		}
+		parents[name] = parent
+	}
+
+	tree, err := category.New(parents)
+	if err != nil {
+		return nil, fmt.Errorf("err category.New: %w", err)
+	}
+
+	return tree, nil
+}
+
+// checkCategories rewrites the categories of expenses as written in the tree and returns
+// the categories missing from it, sorted. Every category is valid when the tree is empty.
+func checkCategories(tree *category.Tree, expenses []model.Expense) []string {
+	if tree.Empty() {
+		return nil
+	}
+
+	var missing []string
+	for i, expense := range expenses {
+		if expense.Category == "" {
+			continue
+		}
+
+		name, ok := tree.Lookup(expense.Category)
+		if !ok {
+			if !common.Contains(missing, expense.Category) {
+				missing = append(missing, expense.Category)
+			}
+			continue
+		}
+		expenses[i].Category = name
+	}
+	sort.Strings(missing)
+
+	return missing
+}
+
+// categoryResult returns the result line reporting the imported categories needing attention.
+// unmapped are the categories missing from the category map, missing those missing from the hierarchy,
+// which supersede unmapped once a hierarchy is defined.
+func categoryResult(tree *category.Tree, unmapped, missing []string) []string {
+	if tree.Empty() {
+		if len(unmapped) == 0 {
+			return nil
+		}
+		return []string{formatUnknownCategories(unmapped)}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("categories missing from %s: %s, add them there or map them with /%s \"%s\" <category>",
+		common.SheetCategories, strings.Join(missing, ", "), common.CommandMapCategory, missing[0])}
+}
+
+// validateCategories checks imported expenses against the categories tab and returns the result lines
+// of the categories needing attention. Failing to read the tab only reports unmapped.
+func validateCategories(ctx context.Context, gsheetRepo repository.GSheetRepository, expenses []model.Expense, unmapped []string) []string {
+	tree, err := getCategoryTree(ctx, gsheetRepo)
+	if err != nil {
+		logger.Warn(ctx, fmt.Sprintf("getCategoryTree: %s", err.Error()))
+	}
+
+	return categoryResult(tree, unmapped, checkCategories(tree, expenses))
+}
+
+// appendMissing adds to list the names it doesn't contain yet.
+func appendMissing(list, names []string) []string {
+	for _, name := range names {
+		if !common.Contains(list, name) {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+func formatCategoryTree(tree *category.Tree) string {
+	if tree.Empty() {
+		return "No categories defined yet\n\n" + categoriesUsage
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Categories")
+	var write func(parent string, depth int)
+	write = func(parent string, depth int) {
+		for _, name := range tree.Children(parent) {
+			sb.WriteString(fmt.Sprintf("\n%s- %s", strings.Repeat("  ", depth), name))
+			write(name, depth+1)
+		}
+	}
+	write("", 0)
+
+	return sb.String()
+}
+
+// NewCategoryService creates a new CategoryService using the provided repositories.
+func NewCategoryService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore) CategoryService {
+	return &categorySvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore}
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+const categoryMapUsage = "Usage:\n/map_category - list mappings\n/map_category \"<source category>\" <category>\n/map_category del \"<source category>\"\n\n" +
+	"Imported categories are renamed with the " + common.SheetCategoryMap + " tab as source | category, ignoring case."
+
+// categoriesInUseAge is how old the month tabs read for the categories in use may be, so imports in a row read them once
+const categoriesInUseAge = 5 * time.Minute
+
+// CategoryMapService is an interface for managing the mapping of imported categories to the sheet categories.
+type CategoryMapService interface {
+	Command(ctx context.Context, chatID int64, args string) error
+}
+
+type categoryMapSvc struct {
+	botRepo    repository.BotRepository
+	gsheetRepo repository.GSheetRepository
+}
+
+// Command handles the /map_category subcommands: list, map and del.
+func (s *categoryMapSvc) Command(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "CategoryMapCommand", err)
+	}()
+
+	fields := common.SplitArgs(args)
+	replyTxt := categoryMapUsage
+
+	switch {
+	case len(fields) == 0 || (fields[0] == "list" && len(fields) == 1):
+		categoryMap, errRead := getCategoryMap(ctx, s.gsheetRepo)
+		if errRead != nil {
+			err = fmt.Errorf("err getCategoryMap: %w", errRead)
+			return err
+		}
+		replyTxt = formatCategoryMap(categoryMap)
+
+	case fields[0] == "del" && len(fields) == 2:
+		source := fields[1]
+		if err = s.updateCategoryMap(ctx, func(categoryMap map[string]string) { deleteMapping(categoryMap, source) }); err != nil {
+			err = fmt.Errorf("err updateCategoryMap: %w", err)
+			return err
+		}
+		replyTxt = fmt.Sprintf("Mapping of %s removed", source)
+
+	case len(fields) >= 2:
+		source := fields[0]
+		target := strings.Join(fields[1:], " ")
+		if err = s.updateCategoryMap(ctx, func(categoryMap map[string]string) {
+			deleteMapping(categoryMap, source)
+			categoryMap[source] = target
+		}); err != nil {
+			err = fmt.Errorf("err updateCategoryMap: %w", err)
+			return err
+		}
+		replyTxt = fmt.Sprintf("%s is now imported as %s", source, target)
+	}
+
+	_, err = s.botRepo.SendTextMessage(ctx, chatID, replyTxt)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// updateCategoryMap applies fn to the stored mappings and rewrites the category map tab.
+func (s *categoryMapSvc) updateCategoryMap(ctx context.Context, fn func(categoryMap map[string]string)) error {
+	categoryMap, previousRows, err := readCategoryMap(ctx, s.gsheetRepo)
+	if err != nil {
+		return err
+	}
+
+	fn(categoryMap)
+
+	sources := make([]string, 0, len(categoryMap))
+	for source := range categoryMap {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	rows := [][]any{{"source", "category"}}
+	for _, source := range sources {
+		rows = append(rows, []any{source, categoryMap[source]})
+	}
+
+	if err := rewriteTab(ctx, s.gsheetRepo, common.SheetCategoryMap, previousRows, rows); err != nil {
+		return fmt.Errorf("err rewriteTab: %w", err)
+	}
+
+	return nil
+}
+
+// getCategoryMap reads the category map tab as source category -> sheet category.
+func getCategoryMap(ctx context.Context, gsheetRepo repository.GSheetRepository) (map[string]string, error) {
+	categoryMap, _, err := readCategoryMap(ctx, gsheetRepo)
+	return categoryMap, err
+}
+
+// readCategoryMap reads the category map tab as source category -> sheet category, and its number of rows.
+func readCategoryMap(ctx context.Context, gsheetRepo repository.GSheetRepository) (map[string]string, int, error) {
+	if err := gsheetRepo.EnsureSheet(ctx, common.SheetCategoryMap); err != nil {
+		return nil, 0, fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+
+	gsheetValues, err := gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:B", common.SheetCategoryMap))
+	if err != nil {
+		return nil, 0, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	categoryMap := map[string]string{}
+	for i, row := range gsheetValues.Values {
+		// Skip header
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+
+		source := strings.TrimSpace(fmt.Sprint(row[0]))
+		target := strings.TrimSpace(fmt.Sprint(row[1]))
+		if source == "" || target == "" {
+			continue
+		}
+		categoryMap[source] = target
+	}
+
+	return categoryMap, len(gsheetValues.Values), nil
+}
+
+// mapCategories renames the categories of expenses found in categoryMap, ignoring case. Mappings are followed
+// to the end, a source mapped to another source ends up as the category of the last one, and a source mapped to
+// itself keeps the spelling of its target. Categories that are neither mapped, in use by the stored expenses
+// (inUse, lower case) nor a mapping target are returned as unknown, sorted.
+func mapCategories(categoryMap map[string]string, inUse map[string]bool, expenses []model.Expense) ([]model.Expense, []string) {
+	// Sources differing only by case are looked up in order, the first wins
+	sources := make([]string, 0, len(categoryMap))
+	for source := range categoryMap {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	targets := map[string]string{}
+	known := map[string]bool{}
+	for category := range inUse {
+		known[category] = true
+	}
+	for _, source := range sources {
+		if _, ok := targets[strings.ToLower(source)]; !ok {
+			targets[strings.ToLower(source)] = categoryMap[source]
+		}
+		known[strings.ToLower(categoryMap[source])] = true
+	}
+
+	unknownSet := map[string]bool{}
+	for i, expense := range expenses {
+		category := strings.TrimSpace(expense.Category)
+		if category == "" {
+			continue
+		}
+
+		if target, ok := resolveCategory(targets, category); ok {
+			expenses[i].Category = target
+			continue
+		}
+		if !known[strings.ToLower(category)] {
+			unknownSet[category] = true
+		}
+	}
+
+	unknown := make([]string, 0, len(unknownSet))
+	for category := range unknownSet {
+		unknown = append(unknown, category)
+	}
+	sort.Strings(unknown)
+
+	return expenses, unknown
+}
+
+// resolveCategory follows the mappings of category in targets, keyed by lower case source, until one maps
+// to itself or is not mapped. It reports false when category is not mapped. Cycles stop at their first repeat.
+func resolveCategory(targets map[string]string, category string) (string, bool) {
+	target, ok := targets[strings.ToLower(category)]
+	if !ok {
+		return category, false
+	}
+
+	seen := map[string]bool{strings.ToLower(category): true}
+	for !seen[strings.ToLower(target)] {
+		next, ok := targets[strings.ToLower(target)]
+		if !ok {
+			break
+		}
+		seen[strings.ToLower(target)] = true
+		target = next
+	}
+
+	return target, true
+}
+
+// categoriesInUse returns the categories of the stored expenses, lower case.
+func categoriesInUse(ctx context.Context, expenseStore repository.ExpenseStore) (map[string]bool, error) {
+	stored, err := expenseStore.Query(ctx, repository.ExpenseQuery{MaxAge: categoriesInUseAge})
+	if err != nil {
+		return nil, fmt.Errorf("err expenseStore.Query: %w", err)
+	}
+
+	inUse := map[string]bool{}
+	for _, expense := range stored {
+		if category := strings.TrimSpace(expense.Category); category != "" {
+			inUse[strings.ToLower(category)] = true
+		}
+	}
+
+	return inUse, nil
+}
+
+// mapImportedCategories applies the category map tab to expenses and returns the unknown categories.
+// Failing to read the map leaves the expenses untouched.
+func mapImportedCategories(ctx context.Context, gsheetRepo repository.GSheetRepository, expenseStore repository.ExpenseStore, expenses []model.Expense) ([]model.Expense, []string) {
+	categoryMap, err := getCategoryMap(ctx, gsheetRepo)
+	if err != nil {
+		logger.Warn(ctx, fmt.Sprintf("getCategoryMap: %s", err.Error()))
+		return expenses, nil
+	}
+
+	// Without the stored categories, only the mapping targets are known
+	inUse, err := categoriesInUse(ctx, expenseStore)
+	if err != nil {
+		logger.Warn(ctx, fmt.Sprintf("categoriesInUse: %s", err.Error()))
+	}
+
+	return mapCategories(categoryMap, inUse, expenses)
+}
+
+func formatUnknownCategories(unknown []string) string {
+	return fmt.Sprintf("unknown categories: %s, map them with /%s \"%s\" <category>",
+		strings.Join(unknown, ", "), common.CommandMapCategory, unknown[0])
+}
+
+// deleteMapping removes the mapping of source, ignoring case.
+func deleteMapping(categoryMap map[string]string, source string) {
+	for existing := range categoryMap {
+		if strings.EqualFold(existing, source) {
+			delete(categoryMap, existing)
+		}
+	}
+}
+
+func formatCategoryMap(categoryMap map[string]string) string {
+	if len(categoryMap) == 0 {
+		return "No category mappings yet\n\n" + categoryMapUsage
+	}
+
+	sources := make([]string, 0, len(categoryMap))
+	for source := range categoryMap {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	msg := "Category mappings"
+	for _, source := range sources {
+		msg = fmt.Sprintf("%s\n- %s -> %s", msg, source, categoryMap[source])
+	}
+
+	return msg
+}
+
+// NewCategoryMapService creates a new CategoryMapService using the provided repositories.
+func NewCategoryMapService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository) CategoryMapService {
+	return &categoryMapSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo}
+}
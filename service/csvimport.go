@@ -0,0 +1,832 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/common/notification"
+	"github.com/frasnym/go-expense-telebot/config"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/callback"
+	"github.com/frasnym/go-expense-telebot/pkg/session"
+	"github.com/frasnym/go-expense-telebot/repository"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Steps of the column mapping wizard, in the order they are asked
+const (
+	csvStepDate     = "date"
+	csvStepLayout   = "layout"
+	csvStepAmount   = "amount"
+	csvStepSign     = "sign"
+	csvStepDebit    = "debit"
+	csvStepCredit   = "credit"
+	csvStepNote     = "note"
+	csvStepCategory = "category"
+	csvStepName     = "name"
+)
+
+const (
+	// csvNone is the callback value of an unmapped column, csvPair selects separate debit and credit columns
+	csvNone = "-"
+	csvPair = "pair"
+	csvSave = "save"
+
+	csvSampleRows = 5
+)
+
+// csvDateLayouts are the date layouts offered when mapping the date column.
+var csvDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"02/01/2006",
+	"01/02/2006",
+	"02/01/2006 15:04",
+	"02/01/2006 15:04:05",
+	"01/02/2006 15:04:05",
+	"02-01-2006",
+	"02.01.2006",
+	"2006/01/02",
+	"02/01/06",
+	"01/02/06",
+	"2 Jan 2006",
+	"Jan 2, 2006",
+	"20060102",
+	time.RFC3339,
+}
+
+var errNoDateLayout = errors.New("no date layout matches")
+
+// CSVImportService is an interface for importing bank CSV exports through saved column mapping profiles.
+type CSVImportService interface {
+	Processor(ctx context.Context, userID int, fileID string, sheet int, records [][]string) error
+	Map(ctx context.Context, userID int, chatID int64, messageID int, args []string) error
+	Name(ctx context.Context, userID int, name string) error
+}
+
+type csvImportSvc struct {
+	cfg          *config.Config
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+	budgetSvc    BudgetService
+	suggestSvc   SuggestService
+
+	notificationClient notification.NotificationClient
+}
+
+// Processor imports the records with the profile saved for their header,
+// or walks the user through mapping the columns when the header is unknown.
+func (s *csvImportSvc) Processor(ctx context.Context, userID int, fileID string, sheet int, records [][]string) error {
+	var err error
+	var result []string
+	mapping := false
+	defer func() {
+		logger.LogService(ctx, "CSVImportProcessor", err)
+	}()
+
+	defer func() {
+		// The user is asked to map the columns, the session goes on
+		if mapping {
+			return
+		}
+		s.notifyResult(ctx, userID, result, err)
+	}()
+
+	if len(records) == 0 {
+		result = append(result, "file is empty")
+		return err
+	}
+
+	signature := headerSignature(records[0])
+	profiles, err := s.getProfiles(ctx)
+	if err != nil {
+		err = fmt.Errorf("err getProfiles: %w", err)
+		return err
+	}
+	for _, profile := range profiles {
+		if profile.Signature == signature {
+			result, err = s.importRecords(ctx, userID, profile, records)
+			if err != nil {
+				err = fmt.Errorf("err importRecords: %w", err)
+			}
+			return err
+		}
+	}
+
+	mapping = true
+	if err = s.startMapping(ctx, userID, fileID, sheet, signature, records); err != nil {
+		mapping = false
+		err = fmt.Errorf("err startMapping: %w", err)
+	}
+	return err
+}
+
+// Map applies the column picked by the user for the current wizard step and asks the next one.
+// args holds the step and the picked value.
+func (s *csvImportSvc) Map(ctx context.Context, userID int, chatID int64, messageID int, args []string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "CSVImportMap", err)
+	}()
+
+	if session.IsInteractionTimedOut(userID) {
+		s.botRepo.EditMessageText(ctx, chatID, messageID, "Request timeout", nil)
+		session.DeleteUserSession(userID)
+		return err
+	}
+
+	if len(args) != 2 {
+		err = fmt.Errorf("invalid mapping args: %v", args)
+		return err
+	}
+	step, value := args[0], args[1]
+
+	// Ignore buttons of steps already answered
+	current, err := session.GetData(userID, common.SessionKeyCSVStep)
+	if err != nil {
+		err = fmt.Errorf("err session.GetData: %w", err)
+		return err
+	}
+	if step != current {
+		err = fmt.Errorf("unexpected mapping step: %s, expecting %s", step, current)
+		return err
+	}
+
+	var header []string
+	var sample [][]string
+	var profile model.CSVProfile
+	if err = getSessionJSON(userID, common.SessionKeyCSVHeader, &header); err != nil {
+		return err
+	}
+	if err = getSessionJSON(userID, common.SessionKeyCSVSample, &sample); err != nil {
+		return err
+	}
+	if err = getSessionJSON(userID, common.SessionKeyCSVProfile, &profile); err != nil {
+		return err
+	}
+
+	next, err := applyMapping(&profile, step, value, header, sample)
+	notice := ""
+	if err == errNoDateLayout {
+		notice = "No known date format matches that column.\n\n"
+		next, err = csvStepDate, nil
+	}
+	if err != nil {
+		err = fmt.Errorf("err applyMapping: %w", err)
+		return err
+	}
+
+	// All columns are mapped and the default profile name was picked
+	if next == "" {
+		s.botRepo.EditMessageText(ctx, chatID, messageID, "Column mapping done, importing", nil)
+		if err = s.finish(ctx, userID, profile); err != nil {
+			err = fmt.Errorf("err finish: %w", err)
+		}
+		return err
+	}
+
+	if err = s.setStep(userID, next, profile); err != nil {
+		return err
+	}
+
+	text, keyboard := csvStepPrompt(next, header, sample, profile)
+	_, err = s.botRepo.EditMessageText(ctx, chatID, messageID, notice+text, &keyboard)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.EditMessageText: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Name saves the profile being mapped under the name sent by the user and imports the file.
+func (s *csvImportSvc) Name(ctx context.Context, userID int, name string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "CSVImportName", err)
+	}()
+
+	// Only the last wizard step expects text, anything else while uploading is a missing file
+	step, _ := session.GetData(userID, common.SessionKeyCSVStep)
+	if step != csvStepName {
+		s.notificationClient.NotifySendToChat(ctx, userID, "no file uploaded")
+		return err
+	}
+
+	if session.IsInteractionTimedOut(userID) {
+		s.notificationClient.NotifySendToChat(ctx, userID, "Request timeout")
+		session.DeleteUserSession(userID)
+		return err
+	}
+
+	var profile model.CSVProfile
+	if err = getSessionJSON(userID, common.SessionKeyCSVProfile, &profile); err != nil {
+		return err
+	}
+	profile.Name = strings.TrimSpace(name)
+
+	if err = s.finish(ctx, userID, profile); err != nil {
+		err = fmt.Errorf("err finish: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// startMapping stores the upload in the user's session and asks for the first column.
+func (s *csvImportSvc) startMapping(ctx context.Context, userID int, fileID string, sheet int, signature string, records [][]string) error {
+	header := records[0]
+	sample := records[1:]
+	if len(sample) > csvSampleRows {
+		sample = sample[:csvSampleRows]
+	}
+	profile := model.CSVProfile{Signature: signature}
+
+	if err := session.SetData(userID, common.SessionKeyFileID, fileID); err != nil {
+		return fmt.Errorf("err session.SetData: %w", err)
+	}
+	if err := session.SetData(userID, common.SessionKeySheet, strconv.Itoa(sheet)); err != nil {
+		return fmt.Errorf("err session.SetData: %w", err)
+	}
+	if err := setSessionJSON(userID, common.SessionKeyCSVHeader, header); err != nil {
+		return err
+	}
+	if err := setSessionJSON(userID, common.SessionKeyCSVSample, sample); err != nil {
+		return err
+	}
+	if err := s.setStep(userID, csvStepDate, profile); err != nil {
+		return err
+	}
+
+	chatID, err := session.GetChatID(userID)
+	if err != nil {
+		return fmt.Errorf("err session.GetChatID: %w", err)
+	}
+
+	text, keyboard := csvStepPrompt(csvStepDate, header, sample, profile)
+	text = "Unknown file format, let's map its columns. Files with the same header will be imported right away next time.\n\n" + text
+	if _, err := s.botRepo.SendTextMessageWithKeyboard(ctx, chatID, text, keyboard); err != nil {
+		return fmt.Errorf("err botRepo.SendTextMessageWithKeyboard: %w", err)
+	}
+
+	return nil
+}
+
+// setStep stores the profile being mapped and the step waiting for an answer, and gives the user time to answer.
+func (s *csvImportSvc) setStep(userID int, step string, profile model.CSVProfile) error {
+	if err := setSessionJSON(userID, common.SessionKeyCSVProfile, profile); err != nil {
+		return err
+	}
+	if err := session.SetData(userID, common.SessionKeyCSVStep, step); err != nil {
+		return fmt.Errorf("err session.SetData: %w", err)
+	}
+	if err := session.ExtendTimer(userID, common.PromptSessionTimeout); err != nil {
+		return fmt.Errorf("err session.ExtendTimer: %w", err)
+	}
+	return nil
+}
+
+// finish saves the mapped profile, then downloads the uploaded file again and imports it.
+func (s *csvImportSvc) finish(ctx context.Context, userID int, profile model.CSVProfile) error {
+	var err error
+	var result []string
+	defer func() {
+		s.notifyResult(ctx, userID, result, err)
+	}()
+
+	if profile.Name == "" {
+		profile.Name = defaultProfileName(profile)
+	}
+	if err = s.saveProfile(ctx, profile); err != nil {
+		err = fmt.Errorf("err saveProfile: %w", err)
+		return err
+	}
+	result = append(result, fmt.Sprintf("mapping saved as profile %s", profile.Name))
+
+	fileID, err := session.GetData(userID, common.SessionKeyFileID)
+	if err != nil {
+		err = fmt.Errorf("err session.GetData: %w", err)
+		return err
+	}
+	sheetData, _ := session.GetData(userID, common.SessionKeySheet)
+	sheet, _ := strconv.Atoi(sheetData)
+
+	content, err := downloadFile(ctx, s.botRepo, fileID, maxUploadSize(ctx, s.cfg))
+	if err != nil {
+		err = fmt.Errorf("err downloadFile: %w", err)
+		return err
+	}
+	records, err := readRecords(ctx, content, sheet)
+	if err != nil {
+		err = fmt.Errorf("err readRecords: %w", err)
+		return err
+	}
+
+	imported, err := s.importRecords(ctx, userID, profile, records)
+	result = append(result, imported...)
+	if err != nil {
+		err = fmt.Errorf("err importRecords: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// importRecords maps the records to expenses with profile and writes them to the month tabs.
+func (s *csvImportSvc) importRecords(ctx context.Context, userID int, profile model.CSVProfile, records [][]string) ([]string, error) {
+	header := records[0]
+	dateIdx := columnIndex(header, profile.DateColumn)
+	amountIdx := columnIndex(header, profile.AmountColumn)
+	debitIdx := columnIndex(header, profile.DebitColumn)
+	creditIdx := columnIndex(header, profile.CreditColumn)
+	noteIdx := columnIndex(header, profile.NoteColumn)
+	categoryIdx := columnIndex(header, profile.CategoryColumn)
+	if dateIdx < 0 || (amountIdx < 0 && debitIdx < 0) {
+		return nil, fmt.Errorf("profile %s maps columns missing from the header", profile.Name)
+	}
+
+	chatID, err := session.GetChatID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("err session.GetChatID: %w", err)
+	}
+	wallet, _ := session.GetData(userID, common.SessionKeyWallet)
+
+	var expenses []model.Expense
+	skipped := 0
+	occurrences := map[string]int{}
+	for _, row := range records[1:] {
+		if isBlankRow(row) {
+			continue
+		}
+
+		date, errDate := time.Parse(profile.DateLayout, strings.TrimSpace(cell(row, dateIdx)))
+		if errDate != nil {
+			skipped++
+			continue
+		}
+
+		var amount float64
+		var errAmount error
+		if amountIdx >= 0 {
+			amount, errAmount = parseCSVAmount(cell(row, amountIdx), profile.DecimalComma)
+			if profile.NegativeExpenses {
+				amount = expenseAmount(amount)
+			}
+		} else {
+			debit, errDebit := parseCSVAmount(cell(row, debitIdx), profile.DecimalComma)
+			credit, errCredit := parseCSVAmount(cell(row, creditIdx), profile.DecimalComma)
+			amount, errAmount = debit-credit, errors.Join(errDebit, errCredit)
+		}
+		if errAmount != nil {
+			skipped++
+			continue
+		}
+		if amount == 0 {
+			continue
+		}
+
+		// Identical rows are distinct transactions, tell them apart by their occurrence
+		key := common.Fingerprint(row...)
+		occurrences[key]++
+
+		expenses = append(expenses, model.Expense{
+			Date:     date,
+			Category: strings.TrimSpace(cell(row, categoryIdx)),
+			Amount:   amount,
+			Note:     strings.TrimSpace(cell(row, noteIdx)),
+			Wallet:   wallet,
+			Ref:      fmt.Sprintf("csv:%s:%s", profile.Signature, common.Fingerprint(key, strconv.Itoa(occurrences[key]))),
+		})
+	}
+
+	result := []string{fmt.Sprintf("profile %s: %d transactions", profile.Name, len(expenses))}
+	if skipped > 0 {
+		result = append(result, fmt.Sprintf("%d rows skipped, invalid date or amount", skipped))
+	}
+
+	imported, err := importExpenses(ctx, s.gsheetRepo, s.expenseStore, s.budgetSvc, s.suggestSvc, chatID, uploadSource(userID, profile.Name), expenses)
+	result = append(result, imported...)
+	if err != nil {
+		return result, fmt.Errorf("err importExpenses: %w", err)
+	}
+
+	return result, nil
+}
+
+// notifyResult sends the import result to the user and ends their session.
+func (s *csvImportSvc) notifyResult(ctx context.Context, userID int, result []string, err error) {
+	resultMsg := "Finished"
+	if err != nil {
+		resultMsg = "Import failed"
+	}
+	for _, v := range result {
+		resultMsg = fmt.Sprintf("%s\n- %s", resultMsg, v)
+	}
+
+	s.notificationClient.NotifySendToChat(ctx, userID, resultMsg)
+	session.DeleteUserSession(userID)
+}
+
+// getProfiles reads the saved column mapping profiles.
+func (s *csvImportSvc) getProfiles(ctx context.Context) ([]model.CSVProfile, error) {
+	profiles, _, err := s.readProfiles(ctx)
+	return profiles, err
+}
+
+// readProfiles reads the saved column mapping profiles and the number of rows of the profiles tab.
+func (s *csvImportSvc) readProfiles(ctx context.Context) ([]model.CSVProfile, int, error) {
+	if err := s.gsheetRepo.EnsureSheet(ctx, common.SheetCSVProfiles); err != nil {
+		return nil, 0, fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+
+	gsheetValues, err := s.gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:K", common.SheetCSVProfiles))
+	if err != nil {
+		return nil, 0, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	var profiles []model.CSVProfile
+	for i, row := range gsheetValues.Values {
+		// Skip header
+		if i == 0 || len(row) < 5 {
+			continue
+		}
+
+		cells := make([]string, 11)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = fmt.Sprint(row[j])
+			}
+		}
+
+		profiles = append(profiles, model.CSVProfile{
+			Signature:        cells[0],
+			Name:             cells[1],
+			DateColumn:       cells[2],
+			DateLayout:       cells[3],
+			AmountColumn:     cells[4],
+			NegativeExpenses: strings.EqualFold(cells[5], "true"),
+			DebitColumn:      cells[6],
+			CreditColumn:     cells[7],
+			NoteColumn:       cells[8],
+			CategoryColumn:   cells[9],
+			DecimalComma:     strings.EqualFold(cells[10], "true"),
+		})
+	}
+
+	return profiles, len(gsheetValues.Values), nil
+}
+
+// saveProfile stores profile, replacing the profile saved for the same header, and rewrites the profiles tab.
+func (s *csvImportSvc) saveProfile(ctx context.Context, profile model.CSVProfile) error {
+	profiles, previousRows, err := s.readProfiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]any{{"signature", "name", "date_column", "date_layout", "amount_column", "negative_expenses",
+		"debit_column", "credit_column", "note_column", "category_column", "decimal_comma"}}
+	for _, p := range append(profiles, profile) {
+		if p.Signature == profile.Signature && p != profile {
+			continue
+		}
+		rows = append(rows, []any{
+			p.Signature,
+			p.Name,
+			p.DateColumn,
+			// Keep the layout as text so the sheet doesn't turn it into a date
+			"'" + p.DateLayout,
+			p.AmountColumn,
+			p.NegativeExpenses,
+			p.DebitColumn,
+			p.CreditColumn,
+			p.NoteColumn,
+			p.CategoryColumn,
+			p.DecimalComma,
+		})
+	}
+
+	if err := rewriteTab(ctx, s.gsheetRepo, common.SheetCSVProfiles, previousRows, rows); err != nil {
+		return fmt.Errorf("err rewriteTab: %w", err)
+	}
+
+	return nil
+}
+
+// applyMapping records the answer to step in profile and returns the next step, or "" when the mapping is complete.
+func applyMapping(profile *model.CSVProfile, step, value string, header []string, sample [][]string) (string, error) {
+	if step == csvStepName {
+		if value != csvSave {
+			return "", fmt.Errorf("invalid name value: %s", value)
+		}
+		return "", nil
+	}
+
+	if step == csvStepSign {
+		profile.NegativeExpenses = value == "neg"
+		return csvStepNote, nil
+	}
+
+	if step == csvStepLayout {
+		idx, err := strconv.Atoi(value)
+		if err != nil || idx < 0 || idx >= len(csvDateLayouts) {
+			return "", fmt.Errorf("invalid date layout: %s", value)
+		}
+		profile.DateLayout = csvDateLayouts[idx]
+		return csvStepAmount, nil
+	}
+
+	if step == csvStepAmount && value == csvPair {
+		profile.AmountColumn = ""
+		return csvStepDebit, nil
+	}
+
+	// The remaining steps pick a column
+	column := ""
+	if value != csvNone {
+		idx, err := strconv.Atoi(value)
+		if err != nil || idx < 0 || idx >= len(header) {
+			return "", fmt.Errorf("invalid column: %s", value)
+		}
+		column = header[idx]
+	}
+
+	switch step {
+	case csvStepDate:
+		if column == "" {
+			return "", fmt.Errorf("date column is required")
+		}
+		layouts := matchingLayouts(columnValues(sample, columnIndex(header, column)))
+		if len(layouts) == 0 {
+			return "", errNoDateLayout
+		}
+		profile.DateColumn = column
+		if len(layouts) == 1 {
+			profile.DateLayout = csvDateLayouts[layouts[0]]
+			return csvStepAmount, nil
+		}
+		return csvStepLayout, nil
+	case csvStepAmount:
+		if column == "" {
+			return "", fmt.Errorf("amount column is required")
+		}
+		profile.AmountColumn = column
+		profile.DecimalComma = isDecimalComma(columnValues(sample, columnIndex(header, column)))
+		return csvStepSign, nil
+	case csvStepDebit:
+		if column == "" {
+			return "", fmt.Errorf("debit column is required")
+		}
+		profile.DebitColumn = column
+		return csvStepCredit, nil
+	case csvStepCredit:
+		profile.CreditColumn = column
+		values := columnValues(sample, columnIndex(header, profile.DebitColumn))
+		values = append(values, columnValues(sample, columnIndex(header, column))...)
+		profile.DecimalComma = isDecimalComma(values)
+		return csvStepNote, nil
+	case csvStepNote:
+		profile.NoteColumn = column
+		return csvStepCategory, nil
+	case csvStepCategory:
+		profile.CategoryColumn = column
+		return csvStepName, nil
+	default:
+		return "", fmt.Errorf("unknown mapping step: %s", step)
+	}
+}
+
+// csvStepPrompt builds the question and buttons of a wizard step.
+func csvStepPrompt(step string, header []string, sample [][]string, profile model.CSVProfile) (string, tgbotapi.InlineKeyboardMarkup) {
+	var text string
+	var buttons []tgbotapi.InlineKeyboardButton
+	optional := false
+
+	switch step {
+	case csvStepDate:
+		text = "Which column holds the date?"
+	case csvStepLayout:
+		text = fmt.Sprintf("Which date is the first row of column %s?", profile.DateColumn)
+		value := strings.TrimSpace(cell(firstRow(sample), columnIndex(header, profile.DateColumn)))
+		for _, i := range matchingLayouts(columnValues(sample, columnIndex(header, profile.DateColumn))) {
+			date, _ := time.Parse(csvDateLayouts[i], value)
+			buttons = append(buttons, callback.NewButton(date.Format("2 Jan 2006"), common.CallbackCSVMapping, step, strconv.Itoa(i)))
+		}
+	case csvStepAmount:
+		text = "Which column holds the amount? Pick Debit/credit when they are in separate columns."
+		buttons = append(buttons, callback.NewButton("Debit/credit", common.CallbackCSVMapping, step, csvPair))
+	case csvStepSign:
+		text = fmt.Sprintf("How are expenses written in column %s?", profile.AmountColumn)
+		buttons = append(buttons,
+			callback.NewButton("Negative, e.g. -150.00", common.CallbackCSVMapping, step, "neg"),
+			callback.NewButton("Positive, e.g. 150.00", common.CallbackCSVMapping, step, "pos"),
+		)
+	case csvStepDebit:
+		text = "Which column holds debits (money spent)?"
+	case csvStepCredit:
+		text = "Which column holds credits (money received)?"
+		optional = true
+	case csvStepNote:
+		text = "Which column holds the description?"
+		optional = true
+	case csvStepCategory:
+		text = "Which column holds the category?"
+		optional = true
+	case csvStepName:
+		text = fmt.Sprintf("Send a name for this profile, or press Save to name it %s.", defaultProfileName(profile))
+		buttons = append(buttons, callback.NewButton("Save", common.CallbackCSVMapping, step, csvSave))
+	}
+
+	// Column steps list every column with its first value
+	if step != csvStepLayout && step != csvStepSign && step != csvStepName {
+		row := firstRow(sample)
+		for i, name := range header {
+			label := truncate(name, 20)
+			if value := strings.TrimSpace(cell(row, i)); value != "" {
+				label = fmt.Sprintf("%s: %s", label, truncate(value, 16))
+			}
+			buttons = append(buttons, callback.NewButton(label, common.CallbackCSVMapping, step, strconv.Itoa(i)))
+		}
+	}
+	if optional {
+		buttons = append(buttons, callback.NewButton("None", common.CallbackCSVMapping, step, csvNone))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(buttons); i += 2 {
+		end := i + 2
+		if end > len(buttons) {
+			end = len(buttons)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(buttons[i:end]...))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(callback.NewButton("Cancel", common.CallbackCancel)))
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// parseCSVAmount parses a bank CSV amount such as "1,500.00", "1.500,00", "IDR 150,000" or "(25.00)".
+// Empty cells are zero.
+func parseCSVAmount(value string, decimalComma bool) (float64, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+
+	// Drop currency codes and symbols
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) || strings.ContainsRune("+-.,", r) {
+			return r
+		}
+		return -1
+	}, value)
+	if strings.HasSuffix(value, "-") {
+		negative = true
+		value = strings.TrimSuffix(value, "-")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	if decimalComma {
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.Replace(value, ",", ".", 1)
+	}
+	amount, err := common.ParseAmount(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// isDecimalComma reports whether the amounts use a comma as decimal separator, e.g. "1.234,56".
+func isDecimalComma(values []string) bool {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		i := strings.LastIndexAny(v, ".,")
+		if i < 0 || v[i] != ',' {
+			continue
+		}
+		if decimals := len(strings.TrimRight(v[i+1:], " )-")); decimals > 0 && decimals <= 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingLayouts returns the index of the date layouts parsing every non-empty value.
+func matchingLayouts(values []string) []int {
+	var layouts []int
+	for i, layout := range csvDateLayouts {
+		parsed := 0
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			if _, err := time.Parse(layout, v); err != nil {
+				parsed = -1
+				break
+			}
+			parsed++
+		}
+		if parsed > 0 {
+			layouts = append(layouts, i)
+		}
+	}
+	return layouts
+}
+
+// headerSignature identifies a CSV format by its header, ignoring case and surrounding spaces.
+func headerSignature(header []string) string {
+	normalized := make([]string, len(header))
+	for i, v := range header {
+		normalized[i] = strings.ToLower(strings.TrimSpace(v))
+	}
+	return common.Fingerprint(normalized...)
+}
+
+func defaultProfileName(profile model.CSVProfile) string {
+	return "csv-" + profile.Signature[:6]
+}
+
+// columnIndex returns the index of the column named name, or -1 when it is unmapped or missing.
+func columnIndex(header []string, name string) int {
+	if name == "" {
+		return -1
+	}
+	for i, v := range header {
+		if v == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func columnValues(rows [][]string, idx int) []string {
+	values := make([]string, 0, len(rows))
+	for _, row := range rows {
+		values = append(values, cell(row, idx))
+	}
+	return values
+}
+
+func cell(row []string, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return row[idx]
+}
+
+func firstRow(rows [][]string) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+	return rows[0]
+}
+
+func isBlankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func setSessionJSON(userID int, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("err json.Marshal: %w", err)
+	}
+	if err := session.SetData(userID, key, string(data)); err != nil {
+		return fmt.Errorf("err session.SetData: %w", err)
+	}
+	return nil
+}
+
+func getSessionJSON(userID int, key string, v any) error {
+	data, err := session.GetData(userID, key)
+	if err != nil {
+		return fmt.Errorf("err session.GetData: %w", err)
+	}
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return fmt.Errorf("err json.Unmarshal: %w", err)
+	}
+	return nil
+}
+
+// NewCSVImportService creates a new CSVImportService using the provided configuration, repositories, notification client, budget and suggestion services.
+func NewCSVImportService(cfg *config.Config, botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore, notificationClient *notification.NotificationClient, budgetSvc BudgetService, suggestSvc SuggestService) CSVImportService {
+	return &csvImportSvc{cfg: cfg, botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore, notificationClient: *notificationClient, budgetSvc: budgetSvc, suggestSvc: suggestSvc}
+}
@@ -0,0 +1,403 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/callback"
+	"github.com/frasnym/go-expense-telebot/repository"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	lastUsage = "Usage: /last [count], count up to 10"
+	// correctUsage explains how to correct an expense by replying to a message about it
+	correctUsage = "Reply with amount 30k, category <name> or note <text>"
+
+	lastDefaultCount = 5
+	lastMaxCount     = 10
+)
+
+// Expense fields editable from the chat
+const (
+	editFieldAmount   = "amount"
+	editFieldCategory = "category"
+	editFieldNote     = "note"
+)
+
+var (
+	editFields = []string{editFieldAmount, editFieldCategory, editFieldNote}
+
+	// expenseRefLine is the last line of the messages about a single expense, locating its row so replies can correct it,
+	// e.g. "ref 09!12 a1b2c3" or "ref 09!12 a1b2c3 amount" when the message asks for one field.
+	expenseRefLine = regexp.MustCompile(`(?m)^ref (\S+)!(\d+) ([0-9a-f]{6})(?: (amount|category|note))?$`)
+	// chatAmount matches amounts typed in the chat, e.g. "30k", "1.5jt" or "150.000"
+	chatAmount = regexp.MustCompile(`^([\d.,]+)\s*(k|rb|m|jt)?$`)
+)
+
+// ExpenseEditService is an interface for editing and deleting single expenses from the chat.
+type ExpenseEditService interface {
+	Last(ctx context.Context, chatID int64, args string) error
+	Show(ctx context.Context, chatID int64, messageID int, args []string) error
+	Field(ctx context.Context, chatID int64, messageID int, args []string) error
+	Delete(ctx context.Context, chatID int64, messageID int, args []string) error
+	Correct(ctx context.Context, chatID int64, replyTo string, text string) error
+}
+
+type expenseEditSvc struct {
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+	budgetSvc    BudgetService
+}
+
+// Last lists the most recent expenses of this month and the previous one, with a button per expense to edit it.
+func (s *expenseEditSvc) Last(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ExpenseEditLast", err)
+	}()
+
+	count := lastDefaultCount
+	if args = strings.TrimSpace(args); args != "" {
+		var errCount error
+		count, errCount = strconv.Atoi(args)
+		if errCount != nil || count < 1 || count > lastMaxCount {
+			_, err = s.botRepo.SendTextMessage(ctx, chatID, lastUsage)
+			if err != nil {
+				err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+			}
+			return err
+		}
+	}
+
+	now := time.Now()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	stored, err := s.expenseStore.Query(ctx, repository.ExpenseQuery{Periods: []time.Time{current.AddDate(0, -1, 0), current}})
+	if err != nil {
+		err = fmt.Errorf("err expenseStore.Query: %w", err)
+		return err
+	}
+	if len(stored) == 0 {
+		_, err = s.botRepo.SendTextMessage(ctx, chatID, "No expenses this month or the previous one")
+		if err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return err
+	}
+
+	// Latest date first, rows written later first within a day
+	sort.SliceStable(stored, func(i, j int) bool {
+		if !stored[i].Date.Equal(stored[j].Date) {
+			return stored[i].Date.After(stored[j].Date)
+		}
+		if stored[i].Sheet != stored[j].Sheet {
+			return stored[i].Sheet > stored[j].Sheet
+		}
+		return stored[i].Row > stored[j].Row
+	})
+	if len(stored) > count {
+		stored = stored[:count]
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Last expenses, pick one to edit or delete it:")
+	var buttons []tgbotapi.InlineKeyboardButton
+	for i, expense := range stored {
+		sb.WriteString(fmt.Sprintf("\n%d. %s", i+1, describeExpense(expense.Expense)))
+		buttons = append(buttons, callback.NewButton(strconv.Itoa(i+1), common.CallbackEdit, expenseArgs(expense)...))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for len(buttons) > 5 {
+		rows = append(rows, buttons[:5])
+		buttons = buttons[5:]
+	}
+	rows = append(rows, buttons)
+
+	_, err = s.botRepo.SendTextMessageWithKeyboard(ctx, chatID, sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...))
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessageWithKeyboard: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Show sends the expense picked from the list with the buttons editing it.
+// args holds the sheet, the row and the row check.
+func (s *expenseEditSvc) Show(ctx context.Context, chatID int64, messageID int, args []string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ExpenseEditShow", err)
+	}()
+
+	if len(args) != 3 {
+		err = fmt.Errorf("invalid edit args: %v", args)
+		return err
+	}
+
+	expense, err := checkedRow(ctx, s.expenseStore, args[0], args[1], args[2])
+	if err != nil {
+		s.botRepo.SendTextMessage(ctx, chatID, "The expense moved or changed in the sheet, send /"+common.CommandLast+" again")
+		err = fmt.Errorf("err checkedRow: %w", err)
+		return err
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			callback.NewButton("Amount", common.CallbackEditField, append(expenseArgs(expense), editFieldAmount)...),
+			callback.NewButton("Category", common.CallbackEditField, append(expenseArgs(expense), editFieldCategory)...),
+			callback.NewButton("Note", common.CallbackEditField, append(expenseArgs(expense), editFieldNote)...),
+		),
+		tgbotapi.NewInlineKeyboardRow(callback.NewButton("Delete", common.CallbackDelete, expenseArgs(expense)...)),
+	)
+	_, err = s.botRepo.SendTextMessageWithKeyboard(ctx, chatID, expenseMessage(expense, "", correctUsage), keyboard)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessageWithKeyboard: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Field asks for the new value of a field of an expense, as a reply to the message asking for it.
+// args holds the sheet, the row, the row check and the field.
+func (s *expenseEditSvc) Field(ctx context.Context, chatID int64, messageID int, args []string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ExpenseEditField", err)
+	}()
+
+	if len(args) != 4 || !common.Contains(editFields, args[3]) {
+		err = fmt.Errorf("invalid edit field args: %v", args)
+		return err
+	}
+
+	expense, err := checkedRow(ctx, s.expenseStore, args[0], args[1], args[2])
+	if err != nil {
+		s.botRepo.EditMessageText(ctx, chatID, messageID, "The expense moved or changed in the sheet, send /"+common.CommandLast+" again", nil)
+		err = fmt.Errorf("err checkedRow: %w", err)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, expenseMessage(expense, args[3], fmt.Sprintf("Reply with the new %s", args[3])))
+	msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+	_, err = s.botRepo.SendMessage(ctx, msg)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes an expense from the store.
+// args holds the sheet, the row and the row check.
+func (s *expenseEditSvc) Delete(ctx context.Context, chatID int64, messageID int, args []string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ExpenseEditDelete", err)
+	}()
+
+	if len(args) != 3 {
+		err = fmt.Errorf("invalid delete args: %v", args)
+		return err
+	}
+
+	expense, err := checkedRow(ctx, s.expenseStore, args[0], args[1], args[2])
+	if err != nil {
+		s.botRepo.EditMessageText(ctx, chatID, messageID, "The expense moved or changed in the sheet, send /"+common.CommandLast+" again", nil)
+		err = fmt.Errorf("err checkedRow: %w", err)
+		return err
+	}
+
+	if err = s.expenseStore.Delete(ctx, []model.StoredExpense{expense}); err != nil {
+		err = fmt.Errorf("err expenseStore.Delete: %w", err)
+		return err
+	}
+
+	_, err = s.botRepo.EditMessageText(ctx, chatID, messageID, "Deleted "+describeExpense(expense.Expense), nil)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.EditMessageText: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Correct applies the correction text to the expense of the message replied to, e.g. "amount 30k".
+// The whole text is the new value when the message asked for one field.
+// It returns common.ErrNoExpenseRef when the message replied to is not about a single expense, and common.ErrNoCorrection
+// when text doesn't start with a field, so the reply is handled as any other message.
+func (s *expenseEditSvc) Correct(ctx context.Context, chatID int64, replyTo string, text string) error {
+	match := expenseRefLine.FindStringSubmatch(replyTo)
+	if match == nil {
+		return common.ErrNoExpenseRef
+	}
+
+	field, value := match[4], strings.TrimSpace(text)
+	if field == "" {
+		name, rest, _ := strings.Cut(value, " ")
+		field, value = strings.ToLower(name), strings.TrimSpace(rest)
+	}
+	if !common.Contains(editFields, field) {
+		return common.ErrNoCorrection
+	}
+
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ExpenseEditCorrect", err)
+	}()
+
+	if value == "" && field != editFieldNote {
+		_, err = s.botRepo.SendTextMessage(ctx, chatID, correctUsage)
+		if err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return err
+	}
+
+	expense, err := checkedRow(ctx, s.expenseStore, match[1], match[2], match[3])
+	if err != nil {
+		s.botRepo.SendTextMessage(ctx, chatID, "The expense moved or changed in the sheet, send /"+common.CommandLast+" to find it again")
+		err = fmt.Errorf("err checkedRow: %w", err)
+		return err
+	}
+	previous := expense.Expense
+
+	switch field {
+	case editFieldAmount:
+		amount, errAmount := parseChatAmount(value)
+		if errAmount != nil {
+			_, err = s.botRepo.SendTextMessage(ctx, chatID, fmt.Sprintf("Invalid amount %s, e.g. 30000, 30k or 1.5jt", value))
+			if err != nil {
+				err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+			}
+			return err
+		}
+		// Keep the sign, credits stay credits
+		if expense.Amount < 0 {
+			amount = -amount
+		}
+		expense.Amount = amount
+	case editFieldCategory:
+		tree, errTree := getCategoryTree(ctx, s.gsheetRepo)
+		if errTree != nil {
+			logger.Warn(ctx, fmt.Sprintf("getCategoryTree: %s", errTree.Error()))
+		}
+		expense.Category = value
+		if errTree == nil {
+			expenses := []model.Expense{expense.Expense}
+			if missing := checkCategories(tree, expenses); len(missing) > 0 {
+				_, err = s.botRepo.SendTextMessage(ctx, chatID, fmt.Sprintf("Unknown category %s, see /%s", value, common.CommandCategories))
+				if err != nil {
+					err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+				}
+				return err
+			}
+			expense.Category = expenses[0].Category
+		}
+	case editFieldNote:
+		expense.Note = value
+	}
+
+	if err = s.expenseStore.Update(ctx, expense); err != nil {
+		err = fmt.Errorf("err expenseStore.Update: %w", err)
+		return err
+	}
+
+	if field != editFieldNote {
+		// The edit adds the difference to the spend of the month, the date is not editable so the month stays.
+		// Moving the amount to another category takes it off the previous one.
+		period := time.Date(expense.Date.Year(), expense.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		removed := previous
+		removed.Amount = -previous.Amount
+		if errBudget := s.budgetSvc.Check(ctx, chatID, period, []model.Expense{removed, expense.Expense}); errBudget != nil {
+			logger.Warn(ctx, fmt.Sprintf("budgetSvc.Check: %s", errBudget.Error()))
+		}
+	}
+
+	// The check changes with the amount and note, the new message is the one to reply to for further corrections
+	_, err = s.botRepo.SendTextMessage(ctx, chatID, expenseMessage(expense, "", "Updated"))
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// expenseArgs are the callback args locating expense: its sheet, row and row check.
+func expenseArgs(expense model.StoredExpense) []string {
+	return []string{expense.Sheet, strconv.Itoa(expense.Row), storedCheck(expense.Expense)}
+}
+
+// expenseMessage describes expense under title and ends with its ref line, so replies to the message can correct it.
+// field is the field the message asks for, if any.
+func expenseMessage(expense model.StoredExpense, field string, title string) string {
+	return fmt.Sprintf("%s\n%s\n\n%s", title, describeExpense(expense.Expense), expenseRef(expense, field))
+}
+
+// expenseRef returns the ref line of expense, matched by expenseRefLine.
+func expenseRef(expense model.StoredExpense, field string) string {
+	ref := fmt.Sprintf("ref %s!%d %s", expense.Sheet, expense.Row, storedCheck(expense.Expense))
+	if field != "" {
+		ref += " " + field
+	}
+	return ref
+}
+
+func describeExpense(expense model.Expense) string {
+	category := expense.Category
+	if category == "" {
+		category = "uncategorized"
+	}
+	return fmt.Sprintf("%s %s %s, %s on %s", expense.Currency, common.FormatAmount(expense.Amount),
+		category, expense.Note, expense.Date.Format("2006-01-02"))
+}
+
+// parseChatAmount parses an amount typed in the chat, accepting the k/rb (thousand) and m/jt (million) suffixes
+// and dot grouped thousands, e.g. "30k", "1.5jt" or "150.000".
+func parseChatAmount(s string) (float64, error) {
+	match := chatAmount.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if match == nil {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+
+	number := match[1]
+	switch {
+	case dotThousands.MatchString(number):
+		number = strings.ReplaceAll(number, ".", "")
+	case match[2] != "" && strings.Count(number, ",") == 1 && !strings.Contains(number, "."):
+		// Decimal comma before a suffix, e.g. "1,5jt"
+		number = strings.Replace(number, ",", ".", 1)
+	}
+	amount, err := common.ParseAmount(number)
+	if err != nil {
+		return 0, fmt.Errorf("err common.ParseAmount: %w", err)
+	}
+
+	switch match[2] {
+	case "k", "rb":
+		amount *= 1e3
+	case "m", "jt":
+		amount *= 1e6
+	}
+
+	return amount, nil
+}
+
+// NewExpenseEditService creates a new ExpenseEditService using the provided repositories and budget service.
+func NewExpenseEditService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore, budgetSvc BudgetService) ExpenseEditService {
+	return &expenseEditSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore, budgetSvc: budgetSvc}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+// readPeriodExpenses returns the expenses of the given period.
+func readPeriodExpenses(ctx context.Context, expenseStore repository.ExpenseStore, period time.Time) ([]model.Expense, error) {
+	stored, err := expenseStore.Query(ctx, repository.ExpenseQuery{Periods: []time.Time{period}})
+	if err != nil {
+		return nil, fmt.Errorf("err expenseStore.Query: %w", err)
+	}
+
+	expenses := make([]model.Expense, len(stored))
+	for i, expense := range stored {
+		expenses[i] = expense.Expense
+	}
+
+	return expenses, nil
+}
+
+// readPeriodsExpenses returns the expenses of the given periods, read in one query, by the first day of their month.
+func readPeriodsExpenses(ctx context.Context, expenseStore repository.ExpenseStore, periods []time.Time) (map[time.Time][]model.Expense, error) {
+	stored, err := expenseStore.Query(ctx, repository.ExpenseQuery{Periods: periods})
+	if err != nil {
+		return nil, fmt.Errorf("err expenseStore.Query: %w", err)
+	}
+
+	byPeriod := map[time.Time][]model.Expense{}
+	for _, expense := range stored {
+		period := time.Date(expense.Date.Year(), expense.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		byPeriod[period] = append(byPeriod[period], expense.Expense)
+	}
+
+	return byPeriod, nil
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/config"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/ledger"
+	"github.com/frasnym/go-expense-telebot/pkg/xlsx"
+	"github.com/frasnym/go-expense-telebot/repository"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Export formats
+const (
+	exportCSV  = "csv"
+	exportXLSX = "xlsx"
+	exportJSON = "json"
+)
+
+// maxExportMonths bounds how many month tabs a single export reads.
+const maxExportMonths = 24
+
+const (
+	exportUsage = "Usage: /export <YYYY-MM>[..<YYYY-MM>] [csv|xlsx|json] [category=<name>] [label=<name>] [wallet=<name>]"
+	ledgerUsage = "Usage: /ledger <YYYY-MM>[..<YYYY-MM>] [ledger|hledger|beancount] [category=<name>] [label=<name>] [wallet=<name>]"
+)
+
+var (
+	exportFormats = []string{exportCSV, exportXLSX, exportJSON}
+	ledgerFormats = []string{string(ledger.FormatLedger), string(ledger.FormatHledger), string(ledger.FormatBeancount)}
+)
+
+// ExportService is an interface for exporting stored expenses as files.
+type ExportService interface {
+	Export(ctx context.Context, chatID int64, args string) error
+	Ledger(ctx context.Context, chatID int64, args string) error
+	WriteLedger(ctx context.Context, w io.Writer, args string) error
+}
+
+type exportSvc struct {
+	cfg          *config.Config
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+}
+
+// exportRequest is a parsed /export command.
+type exportRequest struct {
+	From     time.Time
+	To       time.Time
+	Format   string
+	Category string
+	Label    string
+	Wallet   string
+}
+
+// exportRecord is the normalized shape of an expense in JSON exports.
+type exportRecord struct {
+	Date     string  `json:"date"`
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+	Note     string  `json:"note"`
+	Label    string  `json:"label"`
+	Wallet   string  `json:"wallet"`
+	Currency string  `json:"currency"`
+}
+
+// Export reads the month tabs of the requested range, applies the filters and replies with the file.
+func (s *exportSvc) Export(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ExportExport", err)
+	}()
+
+	req, errParse := parseExportRequest(args, exportFormats)
+	if errParse != nil {
+		_, err = s.botRepo.SendTextMessage(ctx, chatID, fmt.Sprintf("%s\n\n%s", errParse.Error(), exportUsage))
+		if err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return err
+	}
+
+	expenses, err := s.readRange(ctx, req)
+	if err != nil {
+		err = fmt.Errorf("err readRange: %w", err)
+		return err
+	}
+	if len(expenses) == 0 {
+		_, err = s.botRepo.SendTextMessage(ctx, chatID, "No expenses found")
+		if err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return err
+	}
+
+	content, err := encodeExpenses(expenses, req.Format)
+	if err != nil {
+		err = fmt.Errorf("err encodeExpenses: %w", err)
+		return err
+	}
+
+	fileName := fmt.Sprintf("expenses_%s_%s.%s", req.From.Format(common.PeriodLayout), req.To.Format(common.PeriodLayout), req.Format)
+	doc := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: content})
+	doc.Caption = fmt.Sprintf("%d expenses, total %s", len(expenses), common.FormatAmount(sumExpenses(expenses)))
+	_, err = s.botRepo.SendMessage(ctx, doc)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Ledger replies with a plain-text accounting journal of the requested range.
+func (s *exportSvc) Ledger(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ExportLedger", err)
+	}()
+
+	req, errParse := parseExportRequest(args, ledgerFormats)
+	if errParse != nil {
+		_, err = s.botRepo.SendTextMessage(ctx, chatID, fmt.Sprintf("%s\n\n%s", errParse.Error(), ledgerUsage))
+		if err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return err
+	}
+
+	var buf bytes.Buffer
+	count, err := s.writeLedger(ctx, &buf, req)
+	if err != nil {
+		err = fmt.Errorf("err writeLedger: %w", err)
+		return err
+	}
+	if count == 0 {
+		_, err = s.botRepo.SendTextMessage(ctx, chatID, "No expenses found")
+		if err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return err
+	}
+
+	format := ledger.Format(req.Format)
+	fileName := fmt.Sprintf("expenses_%s_%s.%s", req.From.Format(common.PeriodLayout), req.To.Format(common.PeriodLayout), format.Extension())
+	doc := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: buf.Bytes()})
+	doc.Caption = fmt.Sprintf("%d transactions", count)
+	_, err = s.botRepo.SendMessage(ctx, doc)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// WriteLedger writes the plain-text accounting journal of the requested range to w.
+// It takes the same arguments as the /ledger command and is used by the ledger-export CLI.
+func (s *exportSvc) WriteLedger(ctx context.Context, w io.Writer, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ExportWriteLedger", err)
+	}()
+
+	req, err := parseExportRequest(args, ledgerFormats)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.writeLedger(ctx, w, req)
+	if err != nil {
+		err = fmt.Errorf("err writeLedger: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// writeLedger renders the expenses of req as a journal and returns how many transactions were written.
+func (s *exportSvc) writeLedger(ctx context.Context, w io.Writer, req exportRequest) (int, error) {
+	accounts, err := s.getAccounts(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("err getAccounts: %w", err)
+	}
+
+	expenses, err := s.readRange(ctx, req)
+	if err != nil {
+		return 0, fmt.Errorf("err readRange: %w", err)
+	}
+	if err := ledger.Write(w, ledger.Format(req.Format), accounts, expenses); err != nil {
+		return 0, fmt.Errorf("err ledger.Write: %w", err)
+	}
+
+	return len(expenses), nil
+}
+
+// getAccounts reads the account mapping tab, rows of (category|wallet, name, account).
+func (s *exportSvc) getAccounts(ctx context.Context) (ledger.Accounts, error) {
+	accounts := ledger.Accounts{
+		Categories:      map[string]string{},
+		Wallets:         map[string]string{},
+		DefaultCurrency: s.cfg.DefaultCurrency,
+	}
+	if accounts.DefaultCurrency == "" {
+		accounts.DefaultCurrency = common.DefaultCurrency
+	}
+
+	if err := s.gsheetRepo.EnsureSheet(ctx, common.SheetAccounts); err != nil {
+		return accounts, fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+
+	gsheetValues, err := s.gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:C", common.SheetAccounts))
+	if err != nil {
+		return accounts, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	for _, row := range gsheetValues.Values {
+		if len(row) < 3 {
+			continue
+		}
+
+		name, account := fmt.Sprint(row[1]), fmt.Sprint(row[2])
+		switch strings.ToLower(fmt.Sprint(row[0])) {
+		case "category":
+			accounts.Categories[name] = account
+		case "wallet":
+			accounts.Wallets[name] = account
+		}
+	}
+
+	return accounts, nil
+}
+
+// readRange reads the month tabs of req's range in one query and returns the matching expenses ordered by date.
+func (s *exportSvc) readRange(ctx context.Context, req exportRequest) ([]model.Expense, error) {
+	var periods []time.Time
+	for period := req.From; !period.After(req.To); period = period.AddDate(0, 1, 0) {
+		periods = append(periods, period)
+	}
+
+	byPeriod, err := readPeriodsExpenses(ctx, s.expenseStore, periods)
+	if err != nil {
+		return nil, fmt.Errorf("err readPeriodsExpenses: %w", err)
+	}
+
+	var expenses []model.Expense
+	for _, period := range periods {
+		for _, expense := range byPeriod[period] {
+			if req.matches(expense) {
+				expenses = append(expenses, expense)
+			}
+		}
+	}
+
+	sort.SliceStable(expenses, func(i, j int) bool { return expenses[i].Date.Before(expenses[j].Date) })
+	return expenses, nil
+}
+
+// parseExportRequest parses "<from>[..<to>] [format] [key=value...]", the first of formats being the default.
+func parseExportRequest(args string, formats []string) (exportRequest, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return exportRequest{}, errors.New("missing period")
+	}
+
+	req := exportRequest{Format: formats[0]}
+
+	from, to, _ := strings.Cut(fields[0], "..")
+	if to == "" {
+		to = from
+	}
+
+	var err error
+	if req.From, err = time.Parse(common.PeriodLayout, from); err != nil {
+		return req, fmt.Errorf("invalid period: %s", from)
+	}
+	if req.To, err = time.Parse(common.PeriodLayout, to); err != nil {
+		return req, fmt.Errorf("invalid period: %s", to)
+	}
+	if req.To.Before(req.From) {
+		return req, errors.New("period end is before its start")
+	}
+	if !req.To.Before(req.From.AddDate(0, maxExportMonths, 0)) {
+		return req, fmt.Errorf("period is longer than %d months", maxExportMonths)
+	}
+
+	for _, field := range fields[1:] {
+		key, value, isFilter := strings.Cut(field, "=")
+		if !isFilter {
+			format := strings.ToLower(field)
+			if !common.Contains(formats, format) {
+				return req, fmt.Errorf("invalid format: %s", field)
+			}
+			req.Format = format
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "category":
+			req.Category = value
+		case "label":
+			req.Label = value
+		case "wallet":
+			req.Wallet = value
+		default:
+			return req, fmt.Errorf("invalid filter: %s", key)
+		}
+	}
+
+	return req, nil
+}
+
+// matches reports whether expense passes the request's filters.
+func (req exportRequest) matches(expense model.Expense) bool {
+	if req.Category != "" && !strings.EqualFold(expense.Category, req.Category) {
+		return false
+	}
+	if req.Wallet != "" && !strings.EqualFold(expense.Wallet, req.Wallet) {
+		return false
+	}
+	if req.Label != "" {
+		found := false
+		for _, label := range splitLabels(expense.Label) {
+			if strings.EqualFold(label, req.Label) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// encodeExpenses renders expenses in the given format.
+func encodeExpenses(expenses []model.Expense, format string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch format {
+	case exportJSON:
+		records := make([]exportRecord, len(expenses))
+		for i, expense := range expenses {
+			records[i] = exportRecord{
+				Date:     expense.Date.Format(time.RFC3339),
+				Category: expense.Category,
+				Amount:   expense.Amount,
+				Note:     expense.Note,
+				Label:    expense.Label,
+				Wallet:   expense.Wallet,
+				Currency: expense.Currency,
+			}
+		}
+
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(records); err != nil {
+			return nil, fmt.Errorf("err encoder.Encode: %w", err)
+		}
+
+	case exportXLSX:
+		rows := [][]any{repository.ExpenseSheetHeader}
+		for _, expense := range expenses {
+			rows = append(rows, repository.ExpenseRow(expense))
+		}
+		if err := xlsx.Write(&buf, "expenses", rows); err != nil {
+			return nil, fmt.Errorf("err xlsx.Write: %w", err)
+		}
+
+	default:
+		writer := csv.NewWriter(&buf)
+		header := make([]string, len(repository.ExpenseSheetHeader))
+		for i, h := range repository.ExpenseSheetHeader {
+			header[i] = fmt.Sprint(h)
+		}
+		writer.Write(header)
+		for _, expense := range expenses {
+			writer.Write([]string{
+				expense.Date.Format(common.SheetDateLayout),
+				expense.Category,
+				strconv.FormatFloat(expense.Amount, 'f', -1, 64),
+				expense.Note,
+				expense.Label,
+				expense.Wallet,
+				expense.Currency,
+			})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return nil, fmt.Errorf("err writer.Flush: %w", err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func sumExpenses(expenses []model.Expense) float64 {
+	total := 0.0
+	for _, expense := range expenses {
+		total += expense.Amount
+	}
+	return total
+}
+
+// NewExportService creates a new ExportService using the provided repositories.
+func NewExportService(cfg *config.Config, botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore) ExportService {
+	return &exportSvc{cfg: cfg, botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore}
+}
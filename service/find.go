@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/callback"
+	"github.com/frasnym/go-expense-telebot/repository"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	findUsage = "Usage: /find <text> [from..to] [min..max]\n" +
+		"Searches notes, categories and labels, e.g. /find coffee 2024-01..2024-03 20k..50k. " +
+		"Dates are YYYY-MM or YYYY-MM-DD, either end of a range can be left out."
+
+	findPageSize = 10
+	// findCacheAge is how old the month tabs read for a search may be, so paging and refining a search
+	// don't read the spreadsheet again
+	findCacheAge = 5 * time.Minute
+)
+
+// FindService is an interface for searching the stored expenses.
+type FindService interface {
+	Find(ctx context.Context, chatID int64, args string) error
+	Page(ctx context.Context, chatID int64, messageID int, text string, args []string) error
+}
+
+type findSvc struct {
+	botRepo      repository.BotRepository
+	expenseStore repository.ExpenseStore
+}
+
+// findRequest is a parsed /find. From and Until bound the dates, Until excluded, Min and Max the amounts;
+// the bounds are ignored when their has flag is false.
+type findRequest struct {
+	Args     string
+	Text     string
+	From     time.Time
+	Until    time.Time
+	Min, Max float64
+
+	HasFrom, HasUntil, HasMin, HasMax bool
+}
+
+// Find handles /find, replying with the first page of the matching expenses and their total.
+func (s *findSvc) Find(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "FindFind", err)
+	}()
+
+	req, errReq := parseFindRequest(args)
+	if errReq != nil {
+		_, err = s.botRepo.SendTextMessage(ctx, chatID, fmt.Sprintf("%s\n\n%s", errReq.Error(), findUsage))
+		if err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return err
+	}
+
+	text, keyboard, err := s.search(ctx, req, 1)
+	if err != nil {
+		err = fmt.Errorf("err search: %w", err)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	_, err = s.botRepo.SendMessage(ctx, msg)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Page shows another page of the results message text, whose first line is the search.
+// args holds the page number.
+func (s *findSvc) Page(ctx context.Context, chatID int64, messageID int, text string, args []string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "FindPage", err)
+	}()
+
+	if len(args) != 1 {
+		err = fmt.Errorf("invalid find page args: %v", args)
+		return err
+	}
+	page, err := strconv.Atoi(args[0])
+	if err != nil {
+		err = fmt.Errorf("err strconv.Atoi: %w", err)
+		return err
+	}
+
+	search, _, _ := strings.Cut(text, "\n")
+	req, err := parseFindRequest(strings.TrimPrefix(search, "/"+common.CommandFind))
+	if err != nil {
+		err = fmt.Errorf("err parseFindRequest: %w", err)
+		return err
+	}
+
+	text, keyboard, err := s.search(ctx, req, page)
+	if err != nil {
+		err = fmt.Errorf("err search: %w", err)
+		return err
+	}
+
+	_, err = s.botRepo.EditMessageText(ctx, chatID, messageID, text, keyboard)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.EditMessageText: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// search returns the text and buttons of the page of results of req, newest expenses first.
+func (s *findSvc) search(ctx context.Context, req findRequest, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	// Every month tab is read unless both ends of the dates are known
+	query := repository.ExpenseQuery{MaxAge: findCacheAge}
+	if req.HasFrom && req.HasUntil {
+		from := time.Date(req.From.Year(), req.From.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for period := from; period.Before(req.Until); period = period.AddDate(0, 1, 0) {
+			query.Periods = append(query.Periods, period)
+		}
+	}
+
+	stored, err := s.expenseStore.Query(ctx, query)
+	if err != nil {
+		return "", nil, fmt.Errorf("err expenseStore.Query: %w", err)
+	}
+
+	var found []model.StoredExpense
+	totals := map[string]float64{}
+	for _, expense := range stored {
+		if req.matches(expense.Expense) {
+			found = append(found, expense)
+			totals[expense.Currency] += expense.Amount
+		}
+	}
+
+	header := "/" + common.CommandFind + " " + req.Args
+	if len(found) == 0 {
+		return header + "\nNo expenses found", nil, nil
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if !found[i].Date.Equal(found[j].Date) {
+			return found[i].Date.After(found[j].Date)
+		}
+		return found[i].Row > found[j].Row
+	})
+
+	pages := (len(found) + findPageSize - 1) / findPageSize
+	if page < 1 || page > pages {
+		page = 1
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s\n%d expenses, total %s\nPage %d/%d\n", header, len(found), formatTotals(totals), page, pages))
+	end := page * findPageSize
+	if end > len(found) {
+		end = len(found)
+	}
+	for _, expense := range found[(page-1)*findPageSize : end] {
+		sb.WriteString("\n" + describeExpense(expense.Expense))
+	}
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	if page > 1 {
+		buttons = append(buttons, callback.NewButton("« Previous page", common.CallbackFindPage, strconv.Itoa(page-1)))
+	}
+	if page < pages {
+		buttons = append(buttons, callback.NewButton("Next page »", common.CallbackFindPage, strconv.Itoa(page+1)))
+	}
+	if len(buttons) == 0 {
+		return sb.String(), nil, nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons)
+
+	return sb.String(), &keyboard, nil
+}
+
+// parseFindRequest parses "<text> [from..to] [min..max]", the text being every argument that is not a range.
+func parseFindRequest(args string) (findRequest, error) {
+	req := findRequest{Args: strings.TrimSpace(args)}
+
+	var words []string
+	for _, arg := range common.SplitArgs(args) {
+		low, high, isRange := strings.Cut(arg, "..")
+		if !isRange {
+			words = append(words, arg)
+			continue
+		}
+
+		if from, until, ok := parseDateRange(low, high); ok {
+			req.From, req.HasFrom = from, low != ""
+			req.Until, req.HasUntil = until, high != ""
+			continue
+		}
+
+		var err error
+		if low != "" {
+			if req.Min, err = parseChatAmount(low); err != nil {
+				return req, fmt.Errorf("invalid range: %s", arg)
+			}
+			req.HasMin = true
+		}
+		if high != "" {
+			if req.Max, err = parseChatAmount(high); err != nil {
+				return req, fmt.Errorf("invalid range: %s", arg)
+			}
+			req.HasMax = true
+		}
+	}
+
+	if req.HasFrom && req.HasUntil && !req.From.Before(req.Until) {
+		return req, errors.New("date range end is before its start")
+	}
+
+	req.Text = strings.ToLower(strings.Join(words, " "))
+	if req.Text == "" {
+		return req, errors.New("missing text to search")
+	}
+
+	return req, nil
+}
+
+// parseDateRange parses the ends of a range of YYYY-MM or YYYY-MM-DD dates, either may be empty.
+// until is the day after the range, a month ends the range with its last day.
+func parseDateRange(low, high string) (time.Time, time.Time, bool) {
+	if low == "" && high == "" {
+		return time.Time{}, time.Time{}, false
+	}
+
+	var from, until time.Time
+	if low != "" {
+		date, _, ok := parseFindDate(low)
+		if !ok {
+			return time.Time{}, time.Time{}, false
+		}
+		from = date
+	}
+	if high != "" {
+		date, isMonth, ok := parseFindDate(high)
+		if !ok {
+			return time.Time{}, time.Time{}, false
+		}
+		if isMonth {
+			until = date.AddDate(0, 1, 0)
+		} else {
+			until = date.AddDate(0, 0, 1)
+		}
+	}
+
+	return from, until, true
+}
+
+// parseFindDate parses a YYYY-MM-DD date or a YYYY-MM month, reporting which it is.
+func parseFindDate(s string) (time.Time, bool, bool) {
+	if date, err := time.Parse("2006-01-02", s); err == nil {
+		return date, false, true
+	}
+	if date, err := time.Parse(common.PeriodLayout, s); err == nil {
+		return date, true, true
+	}
+	return time.Time{}, false, false
+}
+
+// matches reports whether expense holds the text in its note, category or labels and is within the ranges.
+func (req findRequest) matches(expense model.Expense) bool {
+	if req.HasFrom && expense.Date.Before(req.From) {
+		return false
+	}
+	if req.HasUntil && !expense.Date.Before(req.Until) {
+		return false
+	}
+	if req.HasMin && expense.Amount < req.Min {
+		return false
+	}
+	if req.HasMax && expense.Amount > req.Max {
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(expense.Note), req.Text) ||
+		strings.Contains(strings.ToLower(expense.Category), req.Text) ||
+		strings.Contains(strings.ToLower(expense.Label), req.Text)
+}
+
+// formatTotals formats the total of each currency, e.g. "IDR 450,000, USD 20".
+func formatTotals(totals map[string]float64) string {
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	parts := make([]string, len(currencies))
+	for i, currency := range currencies {
+		parts[i] = strings.TrimSpace(currency + " " + common.FormatAmount(totals[currency]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// NewFindService creates a new FindService using the provided repositories.
+func NewFindService(botRepo *repository.BotRepository, expenseStore *repository.ExpenseStore) FindService {
+	return &findSvc{botRepo: *botRepo, expenseStore: *expenseStore}
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/common/notification"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/callback"
+	"github.com/frasnym/go-expense-telebot/pkg/camt"
+	"github.com/frasnym/go-expense-telebot/pkg/ofx"
+	"github.com/frasnym/go-expense-telebot/pkg/session"
+	"github.com/frasnym/go-expense-telebot/repository"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// statementParser turns an uploaded statement into normalized expenses, and a result line for every entry it had to leave out.
+// Debits are positive amounts, credits (refunds, income) negative.
+type statementParser func(r io.Reader) ([]model.Expense, []string, error)
+
+// expenseAmount converts an amount signed the way accounts sign it, money out negative, to the sign of
+// the expenses: debits positive, credits negative. Every import converts its amounts here.
+func expenseAmount(signed float64) float64 {
+	return -signed
+}
+
+// statementImporters lists the supported statement formats by the command requesting them.
+var statementImporters = map[string]struct {
+	name  string
+	parse statementParser
+}{
+	common.CommandUploadOFX:  {"OFX/QFX", parseOFXStatement},
+	common.CommandUploadCamt: {"camt.053 XML", parseCamtStatement},
+}
+
+// ImportService is an interface for importing bank statements into the month tabs.
+type ImportService interface {
+	Request(ctx context.Context, userID int, chatID int64, action string, wallet string) error
+	Processor(ctx context.Context, userID int, action string, content []byte) error
+}
+
+type importSvc struct {
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+	budgetSvc    BudgetService
+	suggestSvc   SuggestService
+
+	notificationClient notification.NotificationClient
+}
+
+// Request starts an upload session for the statement format of action.
+// When wallet is set, every imported transaction is assigned to it instead of the statement's account.
+func (s *importSvc) Request(ctx context.Context, userID int, chatID int64, action string, wallet string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ImportRequest", err)
+	}()
+
+	importer, ok := statementImporters[action]
+	if !ok {
+		err = fmt.Errorf("unknown statement format: %s", action)
+		return err
+	}
+
+	// Start a new session for the user
+	session.NewSession(userID, chatID, action)
+	wallet = strings.TrimSpace(wallet)
+	if wallet != "" {
+		if err = session.SetData(userID, common.SessionKeyWallet, wallet); err != nil {
+			err = fmt.Errorf("err session.SetData: %w", err)
+			return err
+		}
+	}
+
+	// Send a request for the document
+	replyTxt := fmt.Sprintf("Please upload your %s statement", importer.name)
+	if wallet != "" {
+		replyTxt = fmt.Sprintf("%s, transactions will be assigned to wallet %s", replyTxt, wallet)
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(callback.NewButton("Cancel", common.CallbackCancel)),
+	)
+	msg, err := s.botRepo.SendTextMessageWithKeyboard(ctx, chatID, replyTxt, keyboard)
+	if err != nil {
+		err = fmt.Errorf("error sending text message: %w", err)
+		return err
+	}
+
+	// Set the message ID in the user's session
+	if err := session.SetMessageID(userID, msg.MessageID); err != nil {
+		err = fmt.Errorf("error setting message ID: %w", err)
+		return err
+	}
+	return nil
+}
+
+// Processor parses the uploaded statement in the format of action and writes its transactions to the month tabs, skipping known ones.
+func (s *importSvc) Processor(ctx context.Context, userID int, action string, content []byte) error {
+	var err error
+	var result []string
+	defer func() {
+		logger.LogService(ctx, "ImportProcessor", err)
+	}()
+
+	defer func() {
+		// Notify result
+		resultMsg := "Finished"
+		if err != nil {
+			resultMsg = "Import failed"
+		}
+		for _, v := range result {
+			resultMsg = fmt.Sprintf("%s\n- %s", resultMsg, v)
+		}
+
+		s.notificationClient.NotifySendToChat(ctx, userID, resultMsg)
+		session.DeleteUserSession(userID)
+	}()
+
+	importer, ok := statementImporters[action]
+	if !ok {
+		err = fmt.Errorf("unknown statement format: %s", action)
+		return err
+	}
+	wallet, _ := session.GetData(userID, common.SessionKeyWallet)
+	chatID, err := session.GetChatID(userID)
+	if err != nil {
+		err = fmt.Errorf("err session.GetChatID: %w", err)
+		return err
+	}
+
+	expenses, skipped, err := importer.parse(bytes.NewReader(content))
+	if err != nil {
+		result = append(result, fmt.Sprintf("unable to read %s statement", importer.name))
+		err = fmt.Errorf("err parse: %w", err)
+		return err
+	}
+	result = append(result, skipped...)
+	if wallet != "" {
+		for i := range expenses {
+			expenses[i].Wallet = wallet
+		}
+	}
+
+	source := uploadSource(userID, importer.name+" statement")
+	imported, err := importExpenses(ctx, s.gsheetRepo, s.expenseStore, s.budgetSvc, s.suggestSvc, chatID, source, expenses)
+	result = append(result, imported...)
+	if err != nil {
+		err = fmt.Errorf("err importExpenses: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// importExpenses categorizes expenses with the rules and writes them to their month tabs, skipping those already stored,
+// checks the budgets of every touched month, offers category suggestions for what is left uncategorized
+// and returns a result line per month. Stored rows are matched by ref, or by content when they were written without one.
+// The written rows are recorded in the import journal under source, unless it has no name.
+func importExpenses(ctx context.Context, gsheetRepo repository.GSheetRepository, expenseStore repository.ExpenseStore, budgetSvc BudgetService, suggestSvc SuggestService, chatID int64, source importSource, expenses []model.Expense) ([]string, error) {
+	expenses, unmapped := mapImportedCategories(ctx, gsheetRepo, expenseStore, expenses)
+	expenses = categorize(ctx, gsheetRepo, expenses)
+	result := validateCategories(ctx, gsheetRepo, expenses, unmapped)
+
+	byPeriod := map[time.Time][]model.Expense{}
+	for _, expense := range expenses {
+		period := time.Date(expense.Date.Year(), expense.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		byPeriod[period] = append(byPeriod[period], expense)
+	}
+
+	periods := make([]time.Time, 0, len(byPeriod))
+	for period := range byPeriod {
+		periods = append(periods, period)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Before(periods[j]) })
+
+	// All the months are read, then written, at once
+	stored, err := expenseStore.Query(ctx, repository.ExpenseQuery{Periods: periods})
+	if err != nil {
+		return result, fmt.Errorf("err expenseStore.Query: %w", err)
+	}
+	// Rows written before their source had a ref are told apart by their content, as many times as they are stored
+	known := map[string]bool{}
+	unreferenced := map[string]int{}
+	for _, expense := range stored {
+		if expense.Ref != "" {
+			known[expense.Ref] = true
+		} else {
+			unreferenced[expenseFingerprint(expense.Expense)]++
+		}
+	}
+
+	var added []model.Expense
+	addedByPeriod := map[time.Time][]model.Expense{}
+	for _, period := range periods {
+		for _, expense := range byPeriod[period] {
+			if expense.Ref != "" && known[expense.Ref] {
+				continue
+			}
+			unreferencedExpense := expense
+			unreferencedExpense.Ref = ""
+			if content := expenseFingerprint(unreferencedExpense); unreferenced[content] > 0 {
+				unreferenced[content]--
+				continue
+			}
+			known[expense.Ref] = true
+			addedByPeriod[period] = append(addedByPeriod[period], expense)
+		}
+		added = append(added, addedByPeriod[period]...)
+
+		skipped := len(byPeriod[period]) - len(addedByPeriod[period])
+		result = append(result, fmt.Sprintf("%s: %d added, %d duplicates skipped", period.Format(common.PeriodLayout), len(addedByPeriod[period]), skipped))
+	}
+	if len(added) == 0 {
+		return result, nil
+	}
+
+	written, err := expenseStore.Add(ctx, added)
+	if err != nil {
+		return result, fmt.Errorf("err expenseStore.Add: %w", err)
+	}
+
+	if source.Name != "" {
+		id, errJournal := journalImport(ctx, gsheetRepo, source, chatID, written)
+		if errJournal != nil {
+			logger.Warn(ctx, fmt.Sprintf("journalImport: %s", errJournal.Error()))
+		} else {
+			result = append(result, fmt.Sprintf("import %s, undo it with /%s %s", id, common.CommandUndoImport, id))
+		}
+	}
+
+	for _, period := range periods {
+		if len(addedByPeriod[period]) == 0 {
+			continue
+		}
+		if err := budgetSvc.Check(ctx, chatID, period, addedByPeriod[period]); err != nil {
+			logger.Warn(ctx, fmt.Sprintf("budgetSvc.Check: %s", err.Error()))
+		}
+	}
+
+	if err := suggestSvc.Suggest(ctx, chatID, written); err != nil {
+		logger.Warn(ctx, fmt.Sprintf("suggestSvc.Suggest: %s", err.Error()))
+	}
+
+	return result, nil
+}
+
+// parseOFXStatement maps OFX STMTTRN entries to expenses, using the FITID of the account as ref,
+// or the content of the transaction when it has none.
+func parseOFXStatement(r io.Reader) ([]model.Expense, []string, error) {
+	transactions, err := ofx.Parse(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("err ofx.Parse: %w", err)
+	}
+
+	expenses := make([]model.Expense, 0, len(transactions))
+	for _, t := range transactions {
+		reference := t.FITID
+		if reference == "" {
+			reference = common.Fingerprint(t.Posted.String(), t.Type, fmt.Sprint(t.Amount), t.Name, t.Memo)
+		}
+
+		wallet := strings.TrimSpace(t.BankID + " " + t.Account)
+		expenses = append(expenses, model.Expense{
+			Date:     t.Posted,
+			Amount:   expenseAmount(t.Amount),
+			Note:     strings.TrimSpace(t.Name + " " + t.Memo),
+			Wallet:   wallet,
+			Currency: t.Currency,
+			Ref:      fmt.Sprintf("ofx:%s:%s", t.Account, reference),
+		})
+	}
+
+	return expenses, nil, nil
+}
+
+// parseCamtStatement maps booked camt.053 entries to expenses, dated by booking date
+// and using the servicer's reference of the account as ref. Entries with neither a booking nor a value date are left out.
+func parseCamtStatement(r io.Reader) ([]model.Expense, []string, error) {
+	entries, err := camt.Parse(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("err camt.Parse: %w", err)
+	}
+
+	expenses := make([]model.Expense, 0, len(entries))
+	var skipped []string
+	for _, e := range entries {
+		if e.BookingDate.IsZero() {
+			skipped = append(skipped, fmt.Sprintf("skipped undated entry of statement %s: %s %s %s",
+				e.StatementID, e.Currency, common.FormatAmount(e.SignedAmount()), strings.TrimSpace(e.Counterpart+" "+e.Remittance)))
+			continue
+		}
+
+		reference := e.Reference
+		if reference == "" {
+			reference = common.Fingerprint(e.StatementID, e.BookingDate.String(), e.Indicator, fmt.Sprint(e.Amount), e.Remittance)
+		}
+
+		expenses = append(expenses, model.Expense{
+			Date:     e.BookingDate,
+			Amount:   expenseAmount(e.SignedAmount()),
+			Note:     strings.TrimSpace(e.Counterpart + " " + e.Remittance),
+			Wallet:   e.Account,
+			Currency: e.Currency,
+			Ref:      fmt.Sprintf("camt:%s:%s", e.Account, reference),
+		})
+	}
+
+	return expenses, skipped, nil
+}
+
+// NewImportService creates a new ImportService using the provided repositories, notification client, budget and suggestion services.
+func NewImportService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore, notificationClient *notification.NotificationClient, budgetSvc BudgetService, suggestSvc SuggestService) ImportService {
+	return &importSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore, notificationClient: *notificationClient, budgetSvc: budgetSvc, suggestSvc: suggestSvc}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/session"
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+// testNotifier records the messages sent to users.
+type testNotifier struct {
+	messages []string
+}
+
+func (n *testNotifier) NotifySendToChat(ctx context.Context, userID int, msg string) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func (n *testNotifier) NotifyChat(ctx context.Context, chatID int64, msg string) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+type testBudgetSvc struct{}
+
+func (testBudgetSvc) Command(ctx context.Context, chatID int64, args string) error { return nil }
+
+func (testBudgetSvc) Check(ctx context.Context, chatID int64, period time.Time, added []model.Expense) error {
+	return nil
+}
+
+type testSuggestSvc struct{}
+
+func (testSuggestSvc) Suggest(ctx context.Context, chatID int64, written []model.StoredExpense) error {
+	return nil
+}
+
+func (testSuggestSvc) Pick(ctx context.Context, chatID int64, messageID int, args []string) error {
+	return nil
+}
+
+func (testSuggestSvc) More(ctx context.Context, chatID int64, messageID int, args []string) error {
+	return nil
+}
+
+const undatedCamt = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Id>STMT-1</Id>
+      <Acct><Id><IBAN>NL91ABNA0417164300</IBAN></Id></Acct>
+      <Ntry>
+        <Amt Ccy="EUR">12.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2024-09-05</Dt></BookgDt>
+        <AcctSvcrRef>REF-1</AcctSvcrRef>
+        <NtryDtls><TxDtls><RmtInf><Ustrd>coffee</Ustrd></RmtInf></TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">40.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <AcctSvcrRef>REF-2</AcctSvcrRef>
+        <NtryDtls><TxDtls><RmtInf><Ustrd>groceries</Ustrd></RmtInf></TxDtls></NtryDtls>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestImportProcessorReportsUndatedCamtEntries(t *testing.T) {
+	ctx := context.Background()
+	gsheetRepo := repository.NewLocalGSheetRepository(filepath.Join(t.TempDir(), "sheets.json"))
+	expenseStore := repository.NewGSheetExpenseStore(gsheetRepo)
+	notifier := &testNotifier{}
+	svc := &importSvc{
+		gsheetRepo:         gsheetRepo,
+		expenseStore:       expenseStore,
+		budgetSvc:          testBudgetSvc{},
+		suggestSvc:         testSuggestSvc{},
+		notificationClient: notifier,
+	}
+
+	const userID = 1
+	session.NewSession(userID, 10, common.CommandUploadCamt)
+	if err := svc.Processor(ctx, userID, common.CommandUploadCamt, []byte(undatedCamt)); err != nil {
+		t.Fatalf("Processor: %v", err)
+	}
+
+	if len(notifier.messages) != 1 {
+		t.Fatalf("notified %d messages, want 1", len(notifier.messages))
+	}
+	msg := notifier.messages[0]
+	for _, want := range []string{"skipped undated entry of statement STMT-1", "groceries", "2024-09: 1 added"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("result %q does not report %q", msg, want)
+		}
+	}
+}
+
+func TestParseOFXStatementRefs(t *testing.T) {
+	statement := `<OFX><STMTRS><BANKACCTFROM><ACCTID>123</BANKACCTFROM><BANKTRANLIST>
+<STMTTRN><DTPOSTED>20230912<TRNAMT>-100<FITID>F1<NAME>bus</STMTTRN>
+<STMTTRN><DTPOSTED>20230912<TRNAMT>-200<NAME>coffee</STMTTRN>
+<STMTTRN><DTPOSTED>20230913<TRNAMT>-200<NAME>coffee</STMTTRN>
+</BANKTRANLIST></STMTRS></OFX>`
+
+	expenses, _, err := parseOFXStatement(strings.NewReader(statement))
+	if err != nil {
+		t.Fatalf("parseOFXStatement: %v", err)
+	}
+	if len(expenses) != 3 {
+		t.Fatalf("parseOFXStatement returned %d expenses, want 3", len(expenses))
+	}
+	if want := "ofx:123:F1"; expenses[0].Ref != want {
+		t.Errorf("ref with a FITID = %q, want %q", expenses[0].Ref, want)
+	}
+	for _, expense := range expenses[1:] {
+		if expense.Ref == "ofx:123:" {
+			t.Errorf("ref without a FITID = %q, want a fingerprint", expense.Ref)
+		}
+	}
+	if expenses[1].Ref == expenses[2].Ref {
+		t.Errorf("transactions without a FITID share the ref %q", expenses[1].Ref)
+	}
+}
+
+func TestSpendeeProcessRecords(t *testing.T) {
+	ctx := context.Background()
+	gsheetRepo := repository.NewLocalGSheetRepository(filepath.Join(t.TempDir(), "sheets.json"))
+	expenseStore := repository.NewGSheetExpenseStore(gsheetRepo)
+	svc := &spendeeSvc{
+		gsheetRepo:         gsheetRepo,
+		expenseStore:       expenseStore,
+		budgetSvc:          testBudgetSvc{},
+		suggestSvc:         testSuggestSvc{},
+		notificationClient: &testNotifier{},
+	}
+
+	header := []string{"Date", "Wallet", "Type", "Category name", "Amount", "Currency", "Note", "Labels", "Author"}
+	// The same month of two years shares its tab
+	records := [][]string{
+		header,
+		{"2023-09-05T10:00:00+07:00", "Cash", "Expense", "Food", "-25000", "IDR", "coffee", "", "me"},
+		{"2024-09-05T10:00:00+07:00", "Cash", "Expense", "Food", "-30000", "IDR", "coffee", "", "me"},
+		{"2024-09-05T10:00:00+07:00", "Cash", "Expense", "Food", "-30000", "IDR", "coffee", "", "me"},
+	}
+
+	const userID = 2
+	session.NewSession(userID, 20, common.CommandUploadSpendee)
+	result, err := svc.processRecords(ctx, userID, records)
+	if err != nil {
+		t.Fatalf("processRecords: %v", err)
+	}
+	for _, want := range []string{"2023-09: 1 added, 0 duplicates skipped", "2024-09: 2 added, 0 duplicates skipped"} {
+		if !common.Contains(result, want) {
+			t.Errorf("result %q does not contain %q", result, want)
+		}
+	}
+
+	// Importing the export again skips every row, whatever its year
+	result, err = svc.processRecords(ctx, userID, append(records, []string{"2024-08-01T10:00:00+07:00", "Cash", "Income", "Salary", "1000000", "IDR", "", "", "me"}))
+	if err != nil {
+		t.Fatalf("processRecords again: %v", err)
+	}
+	for _, want := range []string{"2023-09: 0 added, 1 duplicates skipped", "2024-08: 1 added, 0 duplicates skipped", "2024-09: 0 added, 2 duplicates skipped"} {
+		if !common.Contains(result, want) {
+			t.Errorf("result again %q does not contain %q", result, want)
+		}
+	}
+
+	stored, err := expenseStore.Query(ctx, repository.ExpenseQuery{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(stored) != 4 {
+		t.Errorf("stored %d expenses, want 4", len(stored))
+	}
+}
@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/session"
+	"github.com/frasnym/go-expense-telebot/repository"
+	"github.com/google/uuid"
+)
+
+// journalListSize is the number of imports listed by /undo_import without arguments.
+const journalListSize = 10
+
+var importsHeader = []any{"id", "source", "user_id", "chat_id", "imported_at", "periods", "ranges", "fingerprints", "undone_at"}
+
+// journalRangePattern splits a range of the journal such as "09!A12:H14" into its tab, first and last row.
+var journalRangePattern = regexp.MustCompile(`^(.+)![A-Z]+(\d+):[A-Z]+(\d+)$`)
+
+// importSource describes where imported expenses come from, for the import journal.
+// Imports without a name, e.g. single bank notifications, are not journaled.
+type importSource struct {
+	Name   string
+	UserID int
+}
+
+// uploadSource returns the source of the file uploaded in the session of userID, named fallback when its name is unknown.
+func uploadSource(userID int, fallback string) importSource {
+	name, err := session.GetData(userID, common.SessionKeyFileName)
+	if err != nil || name == "" {
+		name = fallback
+	}
+	return importSource{Name: name, UserID: userID}
+}
+
+// ImportJournalService is an interface for undoing the imports recorded in the import journal.
+type ImportJournalService interface {
+	Undo(ctx context.Context, chatID int64, args string) error
+}
+
+type importJournalSvc struct {
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+}
+
+// Undo handles /undo_import <id>, deleting the rows written by the import, and lists the last imports of the chat without an id.
+// Rows moved since the import are found again by their content, rows changed since are left in place.
+func (s *importJournalSvc) Undo(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ImportJournalUndo", err)
+	}()
+
+	records, rowCount, err := getImports(ctx, s.gsheetRepo)
+	if err != nil {
+		err = fmt.Errorf("err getImports: %w", err)
+		return err
+	}
+
+	id := strings.TrimSpace(args)
+	replyTxt := formatImports(records, chatID)
+	if id != "" {
+		replyTxt, err = s.undo(ctx, records, rowCount, chatID, id)
+		if err != nil {
+			err = fmt.Errorf("err undo: %w", err)
+			return err
+		}
+	}
+
+	_, err = s.botRepo.SendTextMessage(ctx, chatID, replyTxt)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// undo deletes the rows of the import id of chatID still holding what it wrote, marks it undone and returns the reply.
+func (s *importJournalSvc) undo(ctx context.Context, records []model.ImportRecord, rowCount int, chatID int64, id string) (string, error) {
+	index := -1
+	for i, record := range records {
+		if record.ID == id && record.ChatID == chatID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Sprintf("Unknown import %s", id), nil
+	}
+	record := records[index]
+	if !record.Undone.IsZero() {
+		return fmt.Sprintf("Import %s was already undone on %s", id, record.Undone.Format(common.SheetDateLayout)), nil
+	}
+
+	written, err := expandRanges(record.Ranges)
+	if err != nil {
+		return "", fmt.Errorf("err expandRanges: %w", err)
+	}
+
+	stored, err := s.expenseStore.Query(ctx, repository.ExpenseQuery{Periods: record.Periods})
+	if err != nil {
+		return "", fmt.Errorf("err expenseStore.Query: %w", err)
+	}
+	deleted := findImported(stored, written, record.Fingerprints)
+
+	if err := s.expenseStore.Delete(ctx, deleted); err != nil {
+		return "", fmt.Errorf("err expenseStore.Delete: %w", err)
+	}
+
+	records[index].Undone = time.Now()
+	if err := saveImports(ctx, s.gsheetRepo, rowCount, records); err != nil {
+		return "", fmt.Errorf("err saveImports: %w", err)
+	}
+
+	replyTxt := fmt.Sprintf("Import %s undone, %d rows deleted", id, len(deleted))
+	if missing := len(record.Fingerprints) - len(deleted); missing > 0 {
+		replyTxt = fmt.Sprintf("%s\n%d rows could not be found, they were changed or deleted since the import and were left alone", replyTxt, missing)
+	}
+	return replyTxt, nil
+}
+
+// findImported returns the stored expenses holding the rows written by an import, given their fingerprints and the
+// locations they were written to. Rows still at their location are taken first, rows moved since, e.g. when a row
+// above them was deleted, are found by their fingerprint, in the same tab when possible. Each row is taken once.
+func findImported(stored, written []model.StoredExpense, fingerprints []string) []model.StoredExpense {
+	type location struct {
+		sheet string
+		row   int
+	}
+	current := map[location]int{}
+	byFingerprint := map[string][]int{}
+	for i, expense := range stored {
+		current[location{expense.Sheet, expense.Row}] = i
+		fingerprint := rowFingerprint(expense.Expense)
+		byFingerprint[fingerprint] = append(byFingerprint[fingerprint], i)
+	}
+
+	taken := make([]bool, len(stored))
+	found := make([]int, len(fingerprints))
+	for i := range found {
+		found[i] = -1
+	}
+
+	// The locations are a hint, kept by the rows nothing moved
+	for i, fingerprint := range fingerprints {
+		if i >= len(written) {
+			break
+		}
+		k, ok := current[location{written[i].Sheet, written[i].Row}]
+		if ok && !taken[k] && rowFingerprint(stored[k].Expense) == fingerprint {
+			taken[k] = true
+			found[i] = k
+		}
+	}
+
+	for i, fingerprint := range fingerprints {
+		if found[i] >= 0 {
+			continue
+		}
+		k := -1
+		for _, candidate := range byFingerprint[fingerprint] {
+			if taken[candidate] {
+				continue
+			}
+			if k < 0 {
+				k = candidate
+			}
+			if i < len(written) && stored[candidate].Sheet == written[i].Sheet {
+				k = candidate
+				break
+			}
+		}
+		if k >= 0 {
+			taken[k] = true
+			found[i] = k
+		}
+	}
+
+	var imported []model.StoredExpense
+	for _, k := range found {
+		if k >= 0 {
+			imported = append(imported, stored[k])
+		}
+	}
+	return imported
+}
+
+// journalImport records the expenses written by an import in the imports tab and returns the id of the import.
+func journalImport(ctx context.Context, gsheetRepo repository.GSheetRepository, source importSource, chatID int64, written []model.StoredExpense) (string, error) {
+	ranges, fingerprints := writtenRanges(written)
+
+	seen := map[time.Time]bool{}
+	var periods []time.Time
+	for _, expense := range written {
+		period := time.Date(expense.Date.Year(), expense.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if !seen[period] {
+			seen[period] = true
+			periods = append(periods, period)
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Before(periods[j]) })
+
+	record := model.ImportRecord{
+		ID:           strings.Split(uuid.NewString(), "-")[0],
+		Source:       source.Name,
+		UserID:       source.UserID,
+		ChatID:       chatID,
+		Imported:     time.Now(),
+		Periods:      periods,
+		Ranges:       ranges,
+		Fingerprints: fingerprints,
+	}
+
+	if err := gsheetRepo.EnsureSheet(ctx, common.SheetImports); err != nil {
+		return "", fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+	gsheetValues, err := gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A1", common.SheetImports))
+	if err != nil {
+		return "", fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	var rows [][]any
+	if len(gsheetValues.Values) == 0 {
+		rows = append(rows, importsHeader)
+	}
+	rows = append(rows, importRow(record))
+	if _, err := gsheetRepo.AppendRow(ctx, common.SheetImports, rows); err != nil {
+		return "", fmt.Errorf("err gsheetRepo.AppendRow: %w", err)
+	}
+
+	return record.ID, nil
+}
+
+// writtenRanges returns the ranges of consecutive rows of written, and the fingerprint of every row in the order of the ranges.
+// The rows are those the store reports, the sheet store reads them from the range its append returned.
+func writtenRanges(written []model.StoredExpense) ([]string, []string) {
+	sorted := append([]model.StoredExpense(nil), written...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Sheet != sorted[j].Sheet {
+			return sorted[i].Sheet < sorted[j].Sheet
+		}
+		return sorted[i].Row < sorted[j].Row
+	})
+
+	var ranges, fingerprints []string
+	for i := 0; i < len(sorted); {
+		j := i + 1
+		for j < len(sorted) && sorted[j].Sheet == sorted[i].Sheet && sorted[j].Row == sorted[j-1].Row+1 {
+			j++
+		}
+		ranges = append(ranges, fmt.Sprintf("%s!A%d:H%d", sorted[i].Sheet, sorted[i].Row, sorted[j-1].Row))
+		for _, expense := range sorted[i:j] {
+			fingerprints = append(fingerprints, rowFingerprint(expense.Expense))
+		}
+		i = j
+	}
+
+	return ranges, fingerprints
+}
+
+// expandRanges returns the locations of every row of ranges, in order.
+func expandRanges(ranges []string) ([]model.StoredExpense, error) {
+	var locations []model.StoredExpense
+	for _, a1Range := range ranges {
+		match := journalRangePattern.FindStringSubmatch(a1Range)
+		if match == nil {
+			return nil, fmt.Errorf("invalid range: %s", a1Range)
+		}
+		first, _ := strconv.Atoi(match[2])
+		last, _ := strconv.Atoi(match[3])
+		sheetName := match[1]
+		if strings.HasPrefix(sheetName, "'") {
+			sheetName = strings.ReplaceAll(strings.Trim(sheetName, "'"), "''", "'")
+		}
+		for row := first; row <= last; row++ {
+			locations = append(locations, model.StoredExpense{Sheet: sheetName, Row: row})
+		}
+	}
+	return locations, nil
+}
+
+// rowFingerprint is a short expenseFingerprint, enough to tell whether a row still holds what was written.
+func rowFingerprint(expense model.Expense) string {
+	return expenseFingerprint(expense)[:8]
+}
+
+func importRow(record model.ImportRecord) []any {
+	periods := make([]string, len(record.Periods))
+	for i, period := range record.Periods {
+		periods[i] = period.Format(common.PeriodLayout)
+	}
+	undone := ""
+	if !record.Undone.IsZero() {
+		undone = record.Undone.Format(common.SheetDateLayout)
+	}
+
+	return []any{
+		record.ID,
+		record.Source,
+		strconv.Itoa(record.UserID),
+		strconv.FormatInt(record.ChatID, 10),
+		record.Imported.Format(common.SheetDateLayout),
+		// Keep the lists as text so the sheet doesn't turn a single month or fingerprint into a date or number
+		"'" + strings.Join(periods, " "),
+		"'" + strings.Join(record.Ranges, " "),
+		"'" + strings.Join(record.Fingerprints, " "),
+		undone,
+	}
+}
+
+// getImports reads the imports tab, oldest import first, and its number of rows.
+func getImports(ctx context.Context, gsheetRepo repository.GSheetRepository) ([]model.ImportRecord, int, error) {
+	if err := gsheetRepo.EnsureSheet(ctx, common.SheetImports); err != nil {
+		return nil, 0, fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+
+	gsheetValues, err := gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:I", common.SheetImports))
+	if err != nil {
+		return nil, 0, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	var records []model.ImportRecord
+	for i, row := range gsheetValues.Values {
+		// Skip header
+		if i == 0 || len(row) < 7 {
+			continue
+		}
+
+		cells := make([]string, 9)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = strings.TrimPrefix(fmt.Sprint(row[j]), "'")
+			}
+		}
+
+		userID, _ := strconv.Atoi(cells[2])
+		chatID, _ := strconv.ParseInt(cells[3], 10, 64)
+		imported, _ := common.ParseSheetDate(cells[4])
+		undone, _ := common.ParseSheetDate(cells[8])
+		var periods []time.Time
+		for _, field := range strings.Fields(cells[5]) {
+			if period, err := time.Parse(common.PeriodLayout, field); err == nil {
+				periods = append(periods, period)
+			}
+		}
+
+		records = append(records, model.ImportRecord{
+			ID:           cells[0],
+			Source:       cells[1],
+			UserID:       userID,
+			ChatID:       chatID,
+			Imported:     imported,
+			Periods:      periods,
+			Ranges:       strings.Fields(cells[6]),
+			Fingerprints: strings.Fields(cells[7]),
+			Undone:       undone,
+		})
+	}
+
+	return records, len(gsheetValues.Values), nil
+}
+
+// saveImports rewrites the imports tab, which had previousRows rows.
+func saveImports(ctx context.Context, gsheetRepo repository.GSheetRepository, previousRows int, records []model.ImportRecord) error {
+	rows := [][]any{importsHeader}
+	for _, record := range records {
+		rows = append(rows, importRow(record))
+	}
+
+	if err := rewriteTab(ctx, gsheetRepo, common.SheetImports, previousRows, rows); err != nil {
+		return fmt.Errorf("err rewriteTab: %w", err)
+	}
+
+	return nil
+}
+
+// formatImports lists the last imports of chatID, newest first.
+func formatImports(records []model.ImportRecord, chatID int64) string {
+	var lines []string
+	for i := len(records) - 1; i >= 0 && len(lines) < journalListSize; i-- {
+		record := records[i]
+		if record.ChatID != chatID {
+			continue
+		}
+		line := fmt.Sprintf("- %s: %s on %s, %d rows", record.ID, record.Source, record.Imported.Format(common.SheetDateLayout), len(record.Fingerprints))
+		if !record.Undone.IsZero() {
+			line += " (undone)"
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return "No imports yet"
+	}
+	return "Last imports, undo one with /" + common.CommandUndoImport + " <id>\n" + strings.Join(lines, "\n")
+}
+
+// NewImportJournalService creates a new ImportJournalService using the provided repositories and expense store.
+func NewImportJournalService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore) ImportJournalService {
+	return &importJournalSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore}
+}
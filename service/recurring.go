@@ -0,0 +1,405 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/common/notification"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	recurringDateLayout = "2006-01-02"
+	recurringLabel      = "recurring"
+	recurringUsage      = "Usage:\n/recurring - list recurring expenses\n" +
+		"/recurring add monthly <day> <amount> <category> [note]\n" +
+		"/recurring add weekly <mon..sun> <amount> <category> [note]\n" +
+		"/recurring add yearly <MM-DD> <amount> <category> [note]\n" +
+		"/recurring del <id>"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// RecurringService is an interface for managing scheduled expenses.
+type RecurringService interface {
+	Command(ctx context.Context, chatID int64, args string) error
+	RunDue(ctx context.Context, now time.Time) error
+}
+
+type recurringSvc struct {
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+	budgetSvc    BudgetService
+
+	notificationClient notification.NotificationClient
+}
+
+// Command handles the /recurring subcommands: list, add and del.
+func (s *recurringSvc) Command(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "RecurringCommand", err)
+	}()
+
+	fields := strings.Fields(args)
+	replyTxt := recurringUsage
+
+	switch {
+	case len(fields) == 0 || fields[0] == "list":
+		entries, errRead := s.getRecurring(ctx)
+		if errRead != nil {
+			err = fmt.Errorf("err getRecurring: %w", errRead)
+			return err
+		}
+		replyTxt = formatRecurring(entries, chatID)
+
+	case fields[0] == "add" && len(fields) >= 5:
+		entry, errParse := parseRecurring(fields[1:])
+		if errParse != nil {
+			replyTxt = fmt.Sprintf("%s\n\n%s", errParse.Error(), recurringUsage)
+			break
+		}
+		entry.ID = strings.Split(uuid.NewString(), "-")[0]
+		entry.ChatID = chatID
+		entry.Created = time.Now()
+
+		if err = s.updateRecurring(ctx, func(entries []model.Recurring) []model.Recurring {
+			return append(entries, entry)
+		}); err != nil {
+			err = fmt.Errorf("err updateRecurring: %w", err)
+			return err
+		}
+		replyTxt = fmt.Sprintf("Recurring expense %s added: %s", entry.ID, describeRecurring(entry))
+
+	case fields[0] == "del" && len(fields) == 2:
+		if err = s.updateRecurring(ctx, func(entries []model.Recurring) []model.Recurring {
+			var kept []model.Recurring
+			for _, entry := range entries {
+				if entry.ID != fields[1] || entry.ChatID != chatID {
+					kept = append(kept, entry)
+				}
+			}
+			return kept
+		}); err != nil {
+			err = fmt.Errorf("err updateRecurring: %w", err)
+			return err
+		}
+		replyTxt = fmt.Sprintf("Recurring expense %s removed", fields[1])
+	}
+
+	_, err = s.botRepo.SendTextMessage(ctx, chatID, replyTxt)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// RunDue writes every occurrence that became due since the previous run into its month tab
+// and notifies the owner of the entry. Each entry remembers its last run, and occurrences whose ref is already
+// stored are skipped, so overlapping runs don't write an occurrence twice.
+func (s *recurringSvc) RunDue(ctx context.Context, now time.Time) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "RecurringRunDue", err)
+	}()
+
+	// Recurring entries have a category, the rules may still add labels
+	ruleList, errRules := getRules(ctx, s.gsheetRepo)
+	if errRules != nil {
+		logger.Warn(ctx, fmt.Sprintf("getRules: %s", errRules.Error()))
+	}
+
+	err = s.updateRecurring(ctx, func(entries []model.Recurring) []model.Recurring {
+		known, errKnown := s.storedRefs(ctx, entries, now)
+		if errKnown != nil {
+			logger.Warn(ctx, fmt.Sprintf("storedRefs: %s", errKnown.Error()))
+			return entries
+		}
+
+		for i, entry := range entries {
+			for _, date := range dueOccurrences(entry, now) {
+				expense := model.Expense{
+					Date:     date,
+					Category: entry.Category,
+					Amount:   entry.Amount,
+					Note:     entry.Note,
+					Label:    recurringLabel,
+					Ref:      recurringRef(entry, date),
+				}
+				if known[expense.Ref] {
+					// Written by an overlapping run
+					entries[i].LastRun = date
+					continue
+				}
+				if _, errWrite := s.expenseStore.Add(ctx, applyRules(ruleList, []model.Expense{expense})); errWrite != nil {
+					logger.Warn(ctx, fmt.Sprintf("expenseStore.Add recurring %s: %s", entry.ID, errWrite.Error()))
+					break
+				}
+				entries[i].LastRun = date
+
+				msg := fmt.Sprintf("Recurring expense added on %s: %s", date.Format(recurringDateLayout), describeRecurring(entry))
+				if errNotify := s.notificationClient.NotifyChat(ctx, entry.ChatID, msg); errNotify != nil {
+					logger.Warn(ctx, fmt.Sprintf("notificationClient.NotifyChat: %s", errNotify.Error()))
+				}
+				if errBudget := s.budgetSvc.Check(ctx, entry.ChatID, date, []model.Expense{expense}); errBudget != nil {
+					logger.Warn(ctx, fmt.Sprintf("budgetSvc.Check: %s", errBudget.Error()))
+				}
+			}
+		}
+		return entries
+	})
+	if err != nil {
+		err = fmt.Errorf("err updateRecurring: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// storedRefs returns the refs stored in the months of the occurrences of entries due by now.
+func (s *recurringSvc) storedRefs(ctx context.Context, entries []model.Recurring, now time.Time) (map[string]bool, error) {
+	seen := map[time.Time]bool{}
+	var periods []time.Time
+	for _, entry := range entries {
+		for _, date := range dueOccurrences(entry, now) {
+			period := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+			if !seen[period] {
+				seen[period] = true
+				periods = append(periods, period)
+			}
+		}
+	}
+
+	known := map[string]bool{}
+	if len(periods) == 0 {
+		return known, nil
+	}
+
+	stored, err := s.expenseStore.Query(ctx, repository.ExpenseQuery{Periods: periods})
+	if err != nil {
+		return nil, fmt.Errorf("err expenseStore.Query: %w", err)
+	}
+	for _, expense := range stored {
+		if strings.HasPrefix(expense.Ref, "recurring:") {
+			known[expense.Ref] = true
+		}
+	}
+
+	return known, nil
+}
+
+// recurringRef is the ref of the occurrence of entry on date, identifying it among the stored expenses.
+func recurringRef(entry model.Recurring, date time.Time) string {
+	return fmt.Sprintf("recurring:%s:%s", entry.ID, date.Format(recurringDateLayout))
+}
+
+// dueOccurrences lists the dates entry falls on after its last run (or creation) up to and including now.
+func dueOccurrences(entry model.Recurring, now time.Time) []time.Time {
+	start := truncateDay(entry.Created)
+	if !entry.LastRun.IsZero() {
+		start = truncateDay(entry.LastRun).AddDate(0, 0, 1)
+	}
+
+	var dates []time.Time
+	for day := start; !day.After(truncateDay(now)); day = day.AddDate(0, 0, 1) {
+		if occursOn(entry, day) {
+			dates = append(dates, day)
+		}
+	}
+
+	return dates
+}
+
+// occursOn reports whether entry is scheduled on day. Days past the end of a month fall on its last day.
+func occursOn(entry model.Recurring, day time.Time) bool {
+	switch entry.Schedule {
+	case model.ScheduleMonthly:
+		n, _ := strconv.Atoi(entry.Day)
+		return day.Day() == clampDay(day, n)
+	case model.ScheduleWeekly:
+		return day.Weekday() == weekdays[entry.Day]
+	case model.ScheduleYearly:
+		date, err := time.Parse("01-02", entry.Day)
+		if err != nil {
+			return false
+		}
+		return day.Month() == date.Month() && day.Day() == clampDay(day, date.Day())
+	}
+
+	return false
+}
+
+// parseRecurring parses "<schedule> <day> <amount> <category> [note]".
+func parseRecurring(fields []string) (model.Recurring, error) {
+	entry := model.Recurring{Schedule: strings.ToLower(fields[0]), Day: strings.ToLower(fields[1])}
+
+	switch entry.Schedule {
+	case model.ScheduleMonthly:
+		if n, err := strconv.Atoi(entry.Day); err != nil || n < 1 || n > 31 {
+			return entry, fmt.Errorf("invalid day of month: %s", fields[1])
+		}
+	case model.ScheduleWeekly:
+		if _, ok := weekdays[entry.Day]; !ok {
+			return entry, fmt.Errorf("invalid weekday: %s", fields[1])
+		}
+	case model.ScheduleYearly:
+		if _, err := time.Parse("01-02", entry.Day); err != nil {
+			return entry, fmt.Errorf("invalid date, use MM-DD: %s", fields[1])
+		}
+	default:
+		return entry, fmt.Errorf("invalid schedule: %s", fields[0])
+	}
+
+	amount, err := common.ParseAmount(fields[2])
+	if err != nil || amount <= 0 {
+		return entry, fmt.Errorf("invalid amount: %s", fields[2])
+	}
+	entry.Amount = amount
+	entry.Category = fields[3]
+	entry.Note = strings.Join(fields[4:], " ")
+
+	return entry, nil
+}
+
+// getRecurring reads the recurring tab. There are no entries until the tab is created.
+func (s *recurringSvc) getRecurring(ctx context.Context) ([]model.Recurring, error) {
+	entries, _, err := s.readRecurring(ctx)
+	return entries, err
+}
+
+// readRecurring is getRecurring also returning the number of rows of the tab.
+func (s *recurringSvc) readRecurring(ctx context.Context) ([]model.Recurring, int, error) {
+	gsheetValues, err := s.gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:I", common.SheetRecurring))
+	if errors.Is(err, common.ErrNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	var entries []model.Recurring
+	for i, row := range gsheetValues.Values {
+		// Skip header
+		if i == 0 || len(row) < 8 {
+			continue
+		}
+
+		cells := make([]string, 9)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = fmt.Sprint(row[j])
+			}
+		}
+
+		amount, _ := common.ParseAmount(cells[3])
+		chatID, _ := strconv.ParseInt(cells[6], 10, 64)
+		// The dates were written in the local time zone (TZ), like the now they are compared with
+		created, _ := time.ParseInLocation(recurringDateLayout, cells[7], time.Local)
+		lastRun, _ := time.ParseInLocation(recurringDateLayout, cells[8], time.Local)
+		entries = append(entries, model.Recurring{
+			ID:       cells[0],
+			Schedule: cells[1],
+			Day:      cells[2],
+			Amount:   amount,
+			Category: cells[4],
+			Note:     cells[5],
+			ChatID:   chatID,
+			Created:  created,
+			LastRun:  lastRun,
+		})
+	}
+
+	return entries, len(gsheetValues.Values), nil
+}
+
+// updateRecurring applies fn to the stored entries and rewrites the recurring tab, creating it when missing.
+func (s *recurringSvc) updateRecurring(ctx context.Context, fn func(entries []model.Recurring) []model.Recurring) error {
+	entries, previousRows, err := s.readRecurring(ctx)
+	if err != nil {
+		return err
+	}
+
+	entries = fn(entries)
+
+	rows := [][]any{{"id", "schedule", "day", "amount", "category", "note", "chat_id", "created", "last_run"}}
+	for _, entry := range entries {
+		lastRun := ""
+		if !entry.LastRun.IsZero() {
+			lastRun = entry.LastRun.Format(recurringDateLayout)
+		}
+		rows = append(rows, []any{
+			entry.ID,
+			entry.Schedule,
+			// Keep the day as text so the sheet doesn't turn "12-25" into a date
+			"'" + entry.Day,
+			entry.Amount,
+			entry.Category,
+			entry.Note,
+			strconv.FormatInt(entry.ChatID, 10),
+			entry.Created.Format(recurringDateLayout),
+			lastRun,
+		})
+	}
+
+	if err := s.gsheetRepo.EnsureSheet(ctx, common.SheetRecurring); err != nil {
+		return fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+	if err := rewriteTab(ctx, s.gsheetRepo, common.SheetRecurring, previousRows, rows); err != nil {
+		return fmt.Errorf("err rewriteTab: %w", err)
+	}
+
+	return nil
+}
+
+func describeRecurring(entry model.Recurring) string {
+	desc := fmt.Sprintf("%s %s on %s, %s %s", entry.Schedule, entry.Category, entry.Day, common.FormatAmount(entry.Amount), entry.Note)
+	return strings.TrimSpace(desc)
+}
+
+func formatRecurring(entries []model.Recurring, chatID int64) string {
+	msg := "Recurring expenses"
+	for _, entry := range entries {
+		if entry.ChatID != chatID {
+			continue
+		}
+		msg = fmt.Sprintf("%s\n- %s: %s", msg, entry.ID, describeRecurring(entry))
+	}
+
+	if msg == "Recurring expenses" {
+		return "No recurring expenses\n\n" + recurringUsage
+	}
+
+	return msg
+}
+
+// clampDay limits a day of month to the number of days in the month of t.
+func clampDay(t time.Time, day int) int {
+	if days := common.DaysInMonth(t); day > days {
+		return days
+	}
+	return day
+}
+
+// truncateDay drops the time of day of t.
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// NewRecurringService creates a new RecurringService using the provided repositories, budget service and notification client.
+func NewRecurringService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore, notificationClient *notification.NotificationClient, budgetSvc BudgetService) RecurringService {
+	return &recurringSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore, notificationClient: *notificationClient, budgetSvc: budgetSvc}
+}
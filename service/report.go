@@ -0,0 +1,429 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/category"
+	"github.com/frasnym/go-expense-telebot/pkg/chart"
+	"github.com/frasnym/go-expense-telebot/repository"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// reportPeriodPattern tells a period argument of /report from a category.
+var reportPeriodPattern = regexp.MustCompile(`^\d{4}-\d{2}$`)
+
+const (
+	reportTopCategories = 5
+	reportTopLabels     = 5
+	reportTopExpenses   = 5
+	noLabel             = "(none)"
+)
+
+// ReportService is an interface for building spending reports from the sheet.
+type ReportService interface {
+	Monthly(ctx context.Context, chatID int64, args string) error
+	Trend(ctx context.Context, chatID int64, year string) error
+}
+
+type reportSvc struct {
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+}
+
+// amountGroup is a named total, e.g. the spending of a category.
+type amountGroup struct {
+	Name     string
+	Amount   float64
+	Previous float64
+}
+
+// monthlySummary holds the aggregated figures of a single month.
+type monthlySummary struct {
+	Period        time.Time
+	Parent        string
+	Total         float64
+	PreviousTotal float64
+	DailyAverage  float64
+	Categories    []amountGroup
+	Labels        []amountGroup
+	Biggest       []model.Expense
+}
+
+// Monthly replies with a spending summary of the "[YYYY-MM] [category]" in args, defaulting to the current month.
+// Categories are rolled up to the top of the category hierarchy, or to the children of the given category to drill down.
+func (s *reportSvc) Monthly(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ReportMonthly", err)
+	}()
+
+	fields := common.SplitArgs(args)
+	period := ""
+	if len(fields) > 0 && reportPeriodPattern.MatchString(fields[0]) {
+		period, fields = fields[0], fields[1:]
+	}
+	month, errPeriod := common.ParsePeriod(period)
+	if errPeriod != nil {
+		_, err = s.botRepo.SendTextMessage(ctx, chatID, "Invalid period, please use /report [YYYY-MM] [category]")
+		if err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return err
+	}
+
+	// A missing hierarchy only disables the roll-up
+	tree, errTree := getCategoryTree(ctx, s.gsheetRepo)
+	if errTree != nil {
+		logger.Warn(ctx, fmt.Sprintf("getCategoryTree: %s", errTree.Error()))
+	}
+	parent := strings.Join(fields, " ")
+	if name, ok := tree.Lookup(parent); ok {
+		parent = name
+	}
+
+	current, errRead := readPeriodExpenses(ctx, s.expenseStore, month)
+	if errRead != nil {
+		err = fmt.Errorf("err readPeriodExpenses: %w", errRead)
+		s.botRepo.SendTextMessage(ctx, chatID, fmt.Sprintf("Unable to read data of %s", month.Format(common.PeriodLayout)))
+		return err
+	}
+
+	// A missing previous month only disables the comparison
+	previousMonth := month.AddDate(0, -1, 0)
+	previous, errRead := readPeriodExpenses(ctx, s.expenseStore, previousMonth)
+	if errRead != nil {
+		logger.Warn(ctx, fmt.Sprintf("readPeriodExpenses %s: %s", previousMonth.Format(common.PeriodLayout), errRead.Error()))
+	}
+
+	summary := summarizeMonth(month, current, previous, time.Now(), tree, parent)
+
+	msg := tgbotapi.NewMessage(chatID, formatMonthlySummary(summary))
+	msg.ParseMode = tgbotapi.ModeHTML
+	_, err = s.botRepo.SendMessage(ctx, msg)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendMessage: %w", err)
+		return err
+	}
+
+	if summary.Total == 0 {
+		return nil
+	}
+
+	// Charts
+	categoryPoints := make([]chart.Point, len(summary.Categories))
+	for i, g := range summary.Categories {
+		categoryPoints[i] = chart.Point{Label: g.Name, Value: g.Amount}
+	}
+	pie, err := chart.Pie(strings.TrimSpace(fmt.Sprintf("Spending by category %s %s", month.Format(common.PeriodLayout), parent)), categoryPoints)
+	if err != nil {
+		err = fmt.Errorf("err chart.Pie: %w", err)
+		return err
+	}
+	if err = s.sendChart(ctx, chatID, "categories.png", pie); err != nil {
+		return err
+	}
+
+	line, err := chart.Line(
+		fmt.Sprintf("Cumulative spending %s", month.Format(common.PeriodLayout)),
+		chart.Series{Name: month.Format(common.PeriodLayout), Points: cumulativeDaily(month, underCategory(tree, parent, current), time.Now())},
+		chart.Series{Name: previousMonth.Format(common.PeriodLayout), Points: cumulativeDaily(previousMonth, underCategory(tree, parent, previous), time.Now())},
+	)
+	if err != nil {
+		err = fmt.Errorf("err chart.Line: %w", err)
+		return err
+	}
+	if err = s.sendChart(ctx, chatID, "cumulative.png", line); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Trend replies with a bar chart of the monthly totals of the given year, defaulting to the current year.
+func (s *reportSvc) Trend(ctx context.Context, chatID int64, year string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "ReportTrend", err)
+	}()
+
+	now := time.Now()
+	targetYear := now.Year()
+	if year = strings.TrimSpace(year); year != "" {
+		parsed, errYear := time.Parse("2006", year)
+		if errYear != nil {
+			_, err = s.botRepo.SendTextMessage(ctx, chatID, "Invalid year, please use /trend YYYY")
+			if err != nil {
+				err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+			}
+			return err
+		}
+		targetYear = parsed.Year()
+	}
+
+	var periods []time.Time
+	for month := time.January; month <= time.December; month++ {
+		period := time.Date(targetYear, month, 1, 0, 0, 0, 0, time.UTC)
+		if period.After(now) {
+			break
+		}
+		periods = append(periods, period)
+	}
+
+	byPeriod, err := readPeriodsExpenses(ctx, s.expenseStore, periods)
+	if err != nil {
+		err = fmt.Errorf("err readPeriodsExpenses: %w", err)
+		return err
+	}
+
+	var points []chart.Point
+	total := 0.0
+	for _, period := range periods {
+		point := chart.Point{Label: period.Format("Jan")}
+		for _, expense := range byPeriod[period] {
+			point.Value += expense.Amount
+		}
+		total += point.Value
+		points = append(points, point)
+	}
+
+	bar, errChart := chart.Bar(fmt.Sprintf("Monthly totals %d", targetYear), points)
+	if errors.Is(errChart, chart.ErrNoData) {
+		_, err = s.botRepo.SendTextMessage(ctx, chatID, fmt.Sprintf("No expenses recorded in %d", targetYear))
+		if err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		}
+		return err
+	}
+	if errChart != nil {
+		err = fmt.Errorf("err chart.Bar: %w", errChart)
+		return err
+	}
+
+	photo := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{Name: "trend.png", Bytes: bar})
+	photo.Caption = fmt.Sprintf("Total %d: %s\nMonthly average: %s", targetYear, common.FormatAmount(total), common.FormatAmount(total/float64(len(points))))
+	_, err = s.botRepo.SendMessage(ctx, photo)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// sendChart sends a rendered PNG chart as a photo.
+func (s *reportSvc) sendChart(ctx context.Context, chatID int64, name string, png []byte) error {
+	photo := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{Name: name, Bytes: png})
+	if _, err := s.botRepo.SendMessage(ctx, photo); err != nil {
+		return fmt.Errorf("err botRepo.SendMessage: %w", err)
+	}
+
+	return nil
+}
+
+// cumulativeDaily returns the running total of each day of the period, up to today for the running month.
+func cumulativeDaily(period time.Time, expenses []model.Expense, now time.Time) []chart.Point {
+	days := common.DaysInMonth(period)
+	if now.Year() == period.Year() && now.Month() == period.Month() {
+		days = now.Day()
+	}
+
+	daily := make([]float64, days)
+	for _, expense := range expenses {
+		if day := expense.Date.Day(); day <= days {
+			daily[day-1] += expense.Amount
+		}
+	}
+
+	points := make([]chart.Point, days)
+	running := 0.0
+	for i, amount := range daily {
+		running += amount
+		points[i] = chart.Point{Label: strconv.Itoa(i + 1), Value: running}
+	}
+
+	return points
+}
+
+// underCategory returns the expenses of parent and its descendants, all expenses for an empty parent.
+func underCategory(tree *category.Tree, parent string, expenses []model.Expense) []model.Expense {
+	if parent == "" {
+		return expenses
+	}
+
+	var filtered []model.Expense
+	for _, expense := range expenses {
+		if _, ok := tree.Under(expense.Category, parent); ok {
+			filtered = append(filtered, expense)
+		}
+	}
+	return filtered
+}
+
+// summarizeMonth aggregates the expenses of a month under parent and compares them against the previous month.
+// Categories are rolled up to the direct children of parent in tree, the top-level categories for an empty parent.
+func summarizeMonth(period time.Time, current, previous []model.Expense, now time.Time, tree *category.Tree, parent string) monthlySummary {
+	summary := monthlySummary{Period: period, Parent: parent}
+	current = underCategory(tree, parent, current)
+	previous = underCategory(tree, parent, previous)
+	rollUp := func(name string) string {
+		rolled, _ := tree.Under(name, parent)
+		return rolled
+	}
+
+	categories := map[string]*amountGroup{}
+	labels := map[string]*amountGroup{}
+	group := func(groups map[string]*amountGroup, name string) *amountGroup {
+		if _, ok := groups[name]; !ok {
+			groups[name] = &amountGroup{Name: name}
+		}
+		return groups[name]
+	}
+
+	for _, expense := range current {
+		summary.Total += expense.Amount
+		group(categories, rollUp(expense.Category)).Amount += expense.Amount
+		for _, label := range splitLabels(expense.Label) {
+			group(labels, label).Amount += expense.Amount
+		}
+	}
+	for _, expense := range previous {
+		summary.PreviousTotal += expense.Amount
+		group(categories, rollUp(expense.Category)).Previous += expense.Amount
+		for _, label := range splitLabels(expense.Label) {
+			group(labels, label).Previous += expense.Amount
+		}
+	}
+
+	// Only count elapsed days when reporting the running month
+	days := common.DaysInMonth(period)
+	if now.Year() == period.Year() && now.Month() == period.Month() {
+		days = now.Day()
+	}
+	summary.DailyAverage = summary.Total / float64(days)
+
+	summary.Categories = sortedGroups(categories)
+	summary.Labels = sortedGroups(labels)
+
+	summary.Biggest = append([]model.Expense{}, current...)
+	sort.SliceStable(summary.Biggest, func(i, j int) bool {
+		return summary.Biggest[i].Amount > summary.Biggest[j].Amount
+	})
+	if len(summary.Biggest) > reportTopExpenses {
+		summary.Biggest = summary.Biggest[:reportTopExpenses]
+	}
+
+	return summary
+}
+
+// formatMonthlySummary renders a summary as an HTML message with monospace tables.
+func formatMonthlySummary(summary monthlySummary) string {
+	var b strings.Builder
+	period := summary.Period.Format(common.PeriodLayout)
+	previousPeriod := summary.Period.AddDate(0, -1, 0).Format(common.PeriodLayout)
+
+	fmt.Fprintf(&b, "<b>Report %s</b>\n", strings.TrimSpace(period+" "+html.EscapeString(summary.Parent)))
+	if summary.Total == 0 {
+		b.WriteString("No expenses recorded")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "Total: %s (%s vs %s: %s)\n",
+		common.FormatAmount(summary.Total),
+		common.FormatPercentChange(summary.Total, summary.PreviousTotal),
+		previousPeriod,
+		common.FormatAmount(summary.PreviousTotal),
+	)
+	fmt.Fprintf(&b, "Daily average: %s\n", common.FormatAmount(summary.DailyAverage))
+
+	writeGroups := func(title string, groups []amountGroup, limit int) {
+		if len(groups) > limit {
+			groups = groups[:limit]
+		}
+
+		fmt.Fprintf(&b, "\n<b>%s</b>\n<pre>", title)
+		fmt.Fprintf(&b, "%-14s %12s %6s %8s\n", "Name", "Amount", "%", "Prev")
+		for _, g := range groups {
+			if g.Amount == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "%-14s %12s %6.1f %8s\n",
+				html.EscapeString(truncate(g.Name, 14)),
+				common.FormatAmount(g.Amount),
+				g.Amount/summary.Total*100,
+				common.FormatPercentChange(g.Amount, g.Previous),
+			)
+		}
+		b.WriteString("</pre>")
+	}
+	writeGroups("Top categories", summary.Categories, reportTopCategories)
+	writeGroups("Top labels", summary.Labels, reportTopLabels)
+
+	b.WriteString("\n<b>Biggest expenses</b>\n<pre>")
+	for _, expense := range summary.Biggest {
+		fmt.Fprintf(&b, "%s %-12s %12s %s\n",
+			expense.Date.Format("01-02"),
+			html.EscapeString(truncate(expense.Category, 12)),
+			common.FormatAmount(expense.Amount),
+			html.EscapeString(truncate(expense.Note, 20)),
+		)
+	}
+	b.WriteString("</pre>")
+
+	return b.String()
+}
+
+// sortedGroups returns the groups ordered by amount, biggest first.
+func sortedGroups(groups map[string]*amountGroup) []amountGroup {
+	result := make([]amountGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Amount == result[j].Amount {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Amount > result[j].Amount
+	})
+
+	return result
+}
+
+// splitLabels splits a comma separated label cell, returning a placeholder when empty.
+func splitLabels(label string) []string {
+	var labels []string
+	for _, l := range strings.Split(label, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, l)
+		}
+	}
+
+	if len(labels) == 0 {
+		return []string{noLabel}
+	}
+
+	return labels
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	return common.Truncate(s, n, "…")
+}
+
+// NewReportService creates a new ReportService using the provided repositories.
+func NewReportService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore) ReportService {
+	return &reportSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore}
+}
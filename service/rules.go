@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/rules"
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+const rulesUsage = "Usage:\n/rules - list rules\n/rules test \"<text>\" [amount] [wallet]\n\n" +
+	"Rules live in the " + common.SheetRules + " tab as name | match | min amount | max amount | wallet | category | labels. " +
+	"Match is a text found in the note, or a regular expression between slashes. The first matching rule fires."
+
+// RuleService is an interface for managing the auto-categorization rules.
+type RuleService interface {
+	Command(ctx context.Context, chatID int64, args string) error
+}
+
+type ruleSvc struct {
+	botRepo    repository.BotRepository
+	gsheetRepo repository.GSheetRepository
+}
+
+// Command handles the /rules subcommands: list and test.
+func (s *ruleSvc) Command(ctx context.Context, chatID int64, args string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "RuleCommand", err)
+	}()
+
+	fields := common.SplitArgs(args)
+	replyTxt := rulesUsage
+
+	switch {
+	case len(fields) == 0 || fields[0] == "list":
+		ruleList, errRead := getRules(ctx, s.gsheetRepo)
+		if errRead != nil {
+			err = fmt.Errorf("err getRules: %w", errRead)
+			return err
+		}
+		replyTxt = formatRules(ruleList)
+
+	case fields[0] == "test" && len(fields) >= 2:
+		amount := 0.0
+		if len(fields) >= 3 {
+			parsed, errAmount := common.ParseAmount(fields[2])
+			if errAmount != nil {
+				replyTxt = fmt.Sprintf("Invalid amount: %s\n\n%s", fields[2], rulesUsage)
+				break
+			}
+			amount = parsed
+		}
+		wallet := ""
+		if len(fields) >= 4 {
+			wallet = strings.Join(fields[3:], " ")
+		}
+
+		ruleList, errRead := getRules(ctx, s.gsheetRepo)
+		if errRead != nil {
+			err = fmt.Errorf("err getRules: %w", errRead)
+			return err
+		}
+
+		rule, ok := rules.First(ruleList, fields[1], amount, wallet)
+		if !ok {
+			replyTxt = "No rule matches"
+			break
+		}
+		replyTxt = fmt.Sprintf("Rule %s fires: %s", rule.Name, describeRule(rule))
+	}
+
+	_, err = s.botRepo.SendTextMessage(ctx, chatID, replyTxt)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// getRules reads the rules tab in order. Invalid rules are skipped.
+func getRules(ctx context.Context, gsheetRepo repository.GSheetRepository) ([]rules.Rule, error) {
+	if err := gsheetRepo.EnsureSheet(ctx, common.SheetRules); err != nil {
+		return nil, fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+
+	gsheetValues, err := gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:G", common.SheetRules))
+	if err != nil {
+		return nil, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	var ruleList []rules.Rule
+	for i, row := range gsheetValues.Values {
+		// Skip header
+		if i == 0 || len(row) < 6 {
+			continue
+		}
+
+		cells := make([]string, 7)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = fmt.Sprint(row[j])
+			}
+		}
+
+		rule, err := rules.New(cells[0], cells[1], cells[2], cells[3], cells[4], cells[5], cells[6])
+		if err != nil {
+			logger.Warn(ctx, fmt.Sprintf("invalid rule %s: %s", cells[0], err.Error()))
+			continue
+		}
+		ruleList = append(ruleList, rule)
+	}
+
+	return ruleList, nil
+}
+
+// categorize applies the rules tab to expenses. Failing to read the rules leaves the expenses untouched.
+func categorize(ctx context.Context, gsheetRepo repository.GSheetRepository, expenses []model.Expense) []model.Expense {
+	ruleList, err := getRules(ctx, gsheetRepo)
+	if err != nil {
+		logger.Warn(ctx, fmt.Sprintf("getRules: %s", err.Error()))
+		return expenses
+	}
+
+	return applyRules(ruleList, expenses)
+}
+
+// applyRules fills the empty category of each expense from the first matching rule and adds the rule's labels.
+// Categories already set, e.g. by the source app, are kept.
+func applyRules(ruleList []rules.Rule, expenses []model.Expense) []model.Expense {
+	if len(ruleList) == 0 {
+		return expenses
+	}
+
+	for i, expense := range expenses {
+		rule, ok := rules.First(ruleList, expense.Note, expense.Amount, expense.Wallet)
+		if !ok {
+			continue
+		}
+
+		if expense.Category == "" {
+			expenses[i].Category = rule.Category
+		}
+		expenses[i].Label = mergeLabels(expense.Label, rule.Labels)
+	}
+
+	return expenses
+}
+
+// mergeLabels adds labels missing from the comma separated label.
+func mergeLabels(label string, labels []string) string {
+	var merged []string
+	for _, l := range strings.Split(label, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			merged = append(merged, l)
+		}
+	}
+	for _, l := range labels {
+		if !common.Contains(merged, l) {
+			merged = append(merged, l)
+		}
+	}
+
+	return strings.Join(merged, ", ")
+}
+
+func describeRule(rule rules.Rule) string {
+	var conditions []string
+	if rule.Match != "" {
+		conditions = append(conditions, fmt.Sprintf("note %s", rule.Match))
+	}
+	if !math.IsInf(rule.MinAmount, -1) || !math.IsInf(rule.MaxAmount, 1) {
+		conditions = append(conditions, fmt.Sprintf("amount %s..%s", formatBound(rule.MinAmount), formatBound(rule.MaxAmount)))
+	}
+	if rule.Wallet != "" {
+		conditions = append(conditions, fmt.Sprintf("wallet %s", rule.Wallet))
+	}
+	if len(conditions) == 0 {
+		conditions = append(conditions, "any")
+	}
+
+	assigns := rule.Category
+	if assigns == "" {
+		assigns = "-"
+	}
+	if len(rule.Labels) > 0 {
+		assigns = fmt.Sprintf("%s [%s]", assigns, strings.Join(rule.Labels, ", "))
+	}
+
+	return fmt.Sprintf("%s -> %s", strings.Join(conditions, ", "), assigns)
+}
+
+func formatBound(bound float64) string {
+	if math.IsInf(bound, 0) {
+		return ""
+	}
+	return common.FormatAmount(bound)
+}
+
+func formatRules(ruleList []rules.Rule) string {
+	if len(ruleList) == 0 {
+		return "No rules yet\n\n" + rulesUsage
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Rules, the first match fires:")
+	for i, rule := range ruleList {
+		sb.WriteString(fmt.Sprintf("\n%d. %s: %s", i+1, rule.Name, describeRule(rule)))
+	}
+
+	return sb.String()
+}
+
+// NewRuleService creates a new RuleService using the provided repositories.
+func NewRuleService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository) RuleService {
+	return &ruleSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo}
+}
@@ -2,28 +2,38 @@ package service
 
 import (
 	"context"
-	"encoding/csv"
 	"fmt"
-	"net/http"
-	"strings"
 	"time"
 
 	"github.com/frasnym/go-expense-telebot/common"
 	"github.com/frasnym/go-expense-telebot/common/logger"
 	"github.com/frasnym/go-expense-telebot/common/notification"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/callback"
 	"github.com/frasnym/go-expense-telebot/pkg/session"
 	"github.com/frasnym/go-expense-telebot/repository"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
 // SpendeeService is an interface for managing Spendee-related actions.
 type SpendeeService interface {
 	Request(ctx context.Context, userID int, chatID int64) error
-	Processor(ctx context.Context, userID int, input string) error
+	Processor(ctx context.Context, userID int, records [][]string) error
+	Cancel(ctx context.Context, userID int, chatID int64, messageID int) error
 }
 
+// spendeeColumns is the number of columns read from a Spendee export:
+// Date, Wallet, Type, Category name, Amount, Currency, Note, Labels.
+// The Author column that follows is not read, rows without it are accepted.
+const spendeeColumns = 8
+
 type spendeeSvc struct {
-	botRepo    repository.BotRepository
-	gsheetRepo repository.GSheetRepository
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+	budgetSvc    BudgetService
+	suggestSvc   SuggestService
 
 	notificationClient notification.NotificationClient
 }
@@ -38,8 +48,11 @@ func (s *spendeeSvc) Request(ctx context.Context, userID int, chatID int64) erro
 	session.NewSession(userID, chatID, common.CommandUploadSpendee)
 
 	// Send a request for the document
-	replyTxt := "Please upload your Spendee CSV document"
-	msg, err := s.botRepo.SendTextMessage(ctx, chatID, replyTxt)
+	replyTxt := "Please upload your Spendee CSV or XLSX document"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(callback.NewButton("Cancel", common.CallbackCancel)),
+	)
+	msg, err := s.botRepo.SendTextMessageWithKeyboard(ctx, chatID, replyTxt, keyboard)
 	if err != nil {
 		err = fmt.Errorf("error sending text message: %w", err)
 		return err
@@ -53,72 +66,68 @@ func (s *spendeeSvc) Request(ctx context.Context, userID int, chatID int64) erro
 	return nil
 }
 
-// Processor processes the user's input (document) for expense report.
-func (s *spendeeSvc) Processor(ctx context.Context, userID int, fileID string) error {
+// Cancel aborts the user's pending upload request and marks the request message as cancelled.
+func (s *spendeeSvc) Cancel(ctx context.Context, userID int, chatID int64, messageID int) error {
 	var err error
-	var result []string
 	defer func() {
-		logger.LogService(ctx, "SpendeeProcessor", err)
+		logger.LogService(ctx, "SpendeeCancel", err)
 	}()
 
-	if session.IsInteractionTimedOut(userID) {
-		s.notificationClient.NotifySendToChat(ctx, userID, "Request timeout")
-		session.DeleteUserSession(userID)
+	session.DeleteUserSession(userID)
+
+	_, err = s.botRepo.EditMessageText(ctx, chatID, messageID, "Upload cancelled", nil)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.EditMessageText: %w", err)
 		return err
 	}
 
+	return nil
+}
+
+// Processor writes the records of a Spendee export (CSV or worksheet) to the month tabs and reports the result.
+func (s *spendeeSvc) Processor(ctx context.Context, userID int, records [][]string) error {
+	var err error
+	var result []string
 	defer func() {
-		// Notify result
-		resultMsg := "Finished"
-		for _, v := range result {
-			resultMsg = fmt.Sprintf("%s\n- %s", resultMsg, v)
-		}
-		resultMsg = fmt.Sprintf("%s\n\nURL: %s", resultMsg, "TBA")
+		logger.LogService(ctx, "SpendeeProcessor", err)
+	}()
 
-		s.notificationClient.NotifySendToChat(ctx, userID, resultMsg)
-		session.DeleteUserSession(userID)
+	defer func() {
+		s.notifyResult(ctx, userID, result)
 	}()
 
-	// Get file url
-	fileUrl, errDoc := s.botRepo.GetFileURL(ctx, fileID)
-	if errDoc != nil {
-		err = fmt.Errorf("err botRepo.GetFileURL: %w", errDoc)
-		return err
+	result, err = s.processRecords(ctx, userID, records)
+	return err
+}
+
+// notifyResult sends the import result to the user and ends their session.
+func (s *spendeeSvc) notifyResult(ctx context.Context, userID int, result []string) {
+	resultMsg := "Finished"
+	for _, v := range result {
+		resultMsg = fmt.Sprintf("%s\n- %s", resultMsg, v)
 	}
+	resultMsg = fmt.Sprintf("%s\n\nURL: %s", resultMsg, "TBA")
 
-	// Reject if not csv
-	if !strings.HasSuffix(strings.ToLower(fileUrl), ".csv") {
-		s.notificationClient.NotifySendToChat(ctx, userID, "File must be csv, please upload again")
+	s.notificationClient.NotifySendToChat(ctx, userID, resultMsg)
+	session.DeleteUserSession(userID)
+}
 
-		err = session.ResetTimer(userID)
-		if err != nil {
-			err = fmt.Errorf("err session.ResetTimer: %w", err)
-		}
-		return err
-	}
+// isSpendeeHeader reports whether header is the header row of a Spendee export.
+func isSpendeeHeader(header []string) bool {
+	return len(header) >= spendeeColumns && header[0] == "Date" && header[3] == "Category name"
+}
 
-	// Get file content
-	resp, errDoc := http.Get(fileUrl)
-	if errDoc != nil {
-		err = fmt.Errorf("err http.Get: %w", errDoc)
-		return err
-	}
-	defer resp.Body.Close()
-
-	// Parse CSV content line by line
-	gsheetInputMap := map[string][][]any{}
-	reader := csv.NewReader(resp.Body)
-	for {
-		// Read one line
-		record, errDoc := reader.Read()
-		if errDoc != nil {
-			err = fmt.Errorf("err reader.Read: %w", errDoc)
-			logger.Warn(ctx, err.Error())
-			break
-		}
+// processRecords writes Spendee export records (from CSV or a worksheet) to the month tabs
+// and returns a result line for every notable event.
+func (s *spendeeSvc) processRecords(ctx context.Context, userID int, records [][]string) ([]string, error) {
+	var result []string
 
-		// Skip Header
-		if record[0] == "Date" {
+	// Parse content line by line
+	var expenses []model.Expense
+	occurrences := map[string]int{}
+	for _, record := range records {
+		// Skip Header and rows too short to be a Spendee record
+		if len(record) < spendeeColumns || record[0] == "Date" {
 			continue
 		}
 
@@ -134,54 +143,47 @@ func (s *spendeeSvc) Processor(ctx context.Context, userID int, fileID string) e
 			break
 		}
 
-		dateMonthFormat := expenseDate.Format("01")
-		gsheetInputMap[dateMonthFormat] = append(
-			gsheetInputMap[dateMonthFormat],
-			[]any{
-				expenseDate.Format("2006-01-02 15:04:05"), // Date
-				record[3],                              // Category
-				strings.Replace(record[4], "-", "", 1), // Amount
-				record[6],                              // Note
-				record[7],                              // Label
-			},
-		)
-	}
-
-	// Insert header
-	for k, v := range gsheetInputMap {
-		gsheetInputMap[k] = common.InsertAndShift[[]any](v, []any{"date", "category", "amount", "note", "label"})
-	}
-
-	// Write to gsheet
-	for period, v := range gsheetInputMap {
-		// Check, don't write if already available
-		targetRange := fmt.Sprintf("%s!A1", period)
-		gsheetValues, errDoc := s.gsheetRepo.GetValues(ctx, targetRange)
-		if errDoc != nil {
-			err = fmt.Errorf("err gsheetRepo.GetValues: %w", errDoc)
-			return err
-		}
-
-		if len(gsheetValues.Values) > 0 {
-			msg := fmt.Sprintf("data already written: %s, skipping...", targetRange)
-			result = append(result, msg)
-			logger.Warn(ctx, msg)
+		// Spendee signs expenses negative and income positive
+		signed, errAmount := common.ParseAmount(record[4])
+		if errAmount != nil {
+			logger.Warn(ctx, fmt.Sprintf("skipping invalid amount %q: %s", record[4], errAmount.Error()))
 			continue
 		}
 
-		// Write
-		err = s.gsheetRepo.AppendRow(ctx, period, v)
-		if err != nil {
-			err = fmt.Errorf("err gsheetRepo.AppendRow: %w", err)
-			return err
+		expense := model.Expense{
+			Date:     expenseDate,
+			Category: record[3],
+			Amount:   expenseAmount(signed),
+			Note:     record[6],
+			Label:    record[7],
+			Wallet:   record[1],
+			Currency: record[5],
+			Ref:      "spendee:" + common.Fingerprint(record...),
+		}
+		// Identical records are distinct transactions, the repeats are told apart by their occurrence
+		occurrences[expense.Ref]++
+		if n := occurrences[expense.Ref]; n > 1 {
+			expense.Ref = fmt.Sprintf("%s:%d", expense.Ref, n)
 		}
+
+		expenses = append(expenses, expense)
 	}
 
-	err = nil
-	return nil
+	chatID, err := session.GetChatID(userID)
+	if err != nil {
+		return result, fmt.Errorf("err session.GetChatID: %w", err)
+	}
+
+	imported, err := importExpenses(ctx, s.gsheetRepo, s.expenseStore, s.budgetSvc, s.suggestSvc, chatID, uploadSource(userID, "Spendee export"), expenses)
+	result = append(result, imported...)
+	if err != nil {
+		return result, fmt.Errorf("err importExpenses: %w", err)
+	}
+
+	return result, nil
 }
 
-// NewSpendeeService creates a new SpendeeService using the provided bot repository.
-func NewSpendeeService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, notificationClient *notification.NotificationClient) SpendeeService {
-	return &spendeeSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo, notificationClient: *notificationClient}
+// NewSpendeeService creates a new SpendeeService using the provided repositories, notification client, budget and suggestion services.
+func NewSpendeeService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore, notificationClient *notification.NotificationClient, budgetSvc BudgetService, suggestSvc SuggestService) SpendeeService {
+	return &spendeeSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore, notificationClient: *notificationClient, budgetSvc: budgetSvc, suggestSvc: suggestSvc}
 }
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/pkg/callback"
+	"github.com/frasnym/go-expense-telebot/pkg/classifier"
+	"github.com/frasnym/go-expense-telebot/repository"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	// suggestHistoryMonths is how many months of categorized rows the suggestions learn from
+	suggestHistoryMonths = 12
+	// suggestLimit bounds the suggestion messages sent for a single import
+	suggestLimit = 10
+	suggestCount = 3
+	// suggestMoreCount is how many categories the More button lists
+	suggestMoreCount = 12
+	// suggestModelAge is how long a trained model is reused, categories written in the sheet meanwhile are learned after it
+	suggestModelAge = 10 * time.Minute
+)
+
+// trainedModel is the last model trained, shared by the services of every request of the process.
+// It is only read once trained, a new model replaces it.
+var trainedModel = struct {
+	mu        sync.Mutex
+	model     *classifier.Model
+	period    time.Time
+	trainedAt time.Time
+}{}
+
+// SuggestService is an interface for suggesting categories of uncategorized expenses,
+// learned from the rows already categorized in the spreadsheet.
+type SuggestService interface {
+	Suggest(ctx context.Context, chatID int64, written []model.StoredExpense) error
+	Pick(ctx context.Context, chatID int64, messageID int, args []string) error
+	More(ctx context.Context, chatID int64, messageID int, args []string) error
+}
+
+type suggestSvc struct {
+	botRepo      repository.BotRepository
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+	budgetSvc    BudgetService
+}
+
+// Suggest sends the most likely categories of each written expense without category as buttons.
+// Picked categories are written to the sheet, so the model learns from them on the next import.
+func (s *suggestSvc) Suggest(ctx context.Context, chatID int64, written []model.StoredExpense) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "SuggestSuggest", err)
+	}()
+
+	var uncategorized []model.StoredExpense
+	for _, expense := range written {
+		if expense.Category == "" && expense.Note != "" {
+			uncategorized = append(uncategorized, expense)
+		}
+	}
+	if len(uncategorized) == 0 {
+		return nil
+	}
+
+	classifierModel := s.model(ctx, time.Now())
+
+	sent := 0
+	for _, expense := range uncategorized {
+		if sent == suggestLimit {
+			break
+		}
+
+		suggestions := classifierModel.Predict(expense.Note, suggestCount)
+		if len(suggestions) == 0 {
+			continue
+		}
+
+		var buttons []tgbotapi.InlineKeyboardButton
+		for _, suggestion := range suggestions {
+			label := fmt.Sprintf("%s %.0f%%", suggestion.Category, suggestion.Probability*100)
+			if button, ok := suggestButton(label, expense, suggestion.Category); ok {
+				buttons = append(buttons, button)
+			}
+		}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(buttons...),
+			tgbotapi.NewInlineKeyboardRow(
+				callback.NewButton("More", common.CallbackSuggestMore, expense.Sheet, strconv.Itoa(expense.Row), storedCheck(expense.Expense)),
+				callback.NewButton("Skip", common.CallbackSuggest, expense.Sheet, strconv.Itoa(expense.Row), storedCheck(expense.Expense), ""),
+			),
+		)
+
+		if _, err = s.botRepo.SendTextMessageWithKeyboard(ctx, chatID, describeUncategorized(expense.Expense), keyboard); err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessageWithKeyboard: %w", err)
+			return err
+		}
+		sent++
+	}
+
+	if rest := len(uncategorized) - sent; sent == suggestLimit && rest > 0 {
+		msg := fmt.Sprintf("%d more expenses are uncategorized, see /rules to categorize them automatically", rest)
+		if _, err = s.botRepo.SendTextMessage(ctx, chatID, msg); err != nil {
+			err = fmt.Errorf("err botRepo.SendTextMessage: %w", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Pick writes the category chosen for an expense to its row. args holds the sheet, the row, the row check
+// and the category, an empty category leaves the expense uncategorized.
+func (s *suggestSvc) Pick(ctx context.Context, chatID int64, messageID int, args []string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "SuggestPick", err)
+	}()
+
+	if len(args) != 4 {
+		err = fmt.Errorf("invalid suggestion args: %v", args)
+		return err
+	}
+	category := args[3]
+
+	expense, err := checkedRow(ctx, s.expenseStore, args[0], args[1], args[2])
+	if err != nil {
+		s.botRepo.EditMessageText(ctx, chatID, messageID, "The expense moved or changed in the sheet, please categorize it there", nil)
+		err = fmt.Errorf("err checkedRow: %w", err)
+		return err
+	}
+
+	if category == "" {
+		_, err = s.botRepo.EditMessageText(ctx, chatID, messageID, describeUncategorized(expense.Expense)+"\n\nLeft uncategorized", nil)
+		if err != nil {
+			err = fmt.Errorf("err botRepo.EditMessageText: %w", err)
+		}
+		return err
+	}
+
+	expense.Category = category
+	if err = s.expenseStore.Update(ctx, expense); err != nil {
+		err = fmt.Errorf("err expenseStore.Update: %w", err)
+		return err
+	}
+	forgetModel()
+
+	period := time.Date(expense.Date.Year(), expense.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if errBudget := s.budgetSvc.Check(ctx, chatID, period, []model.Expense{expense.Expense}); errBudget != nil {
+		logger.Warn(ctx, fmt.Sprintf("budgetSvc.Check: %s", errBudget.Error()))
+	}
+
+	_, err = s.botRepo.EditMessageText(ctx, chatID, messageID, fmt.Sprintf("%s\n\nCategorized as %s", describeUncategorized(expense.Expense), category), nil)
+	if err != nil {
+		err = fmt.Errorf("err botRepo.EditMessageText: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// More replaces the suggestions of an expense with the most used categories, to correct a wrong suggestion.
+// args holds the sheet, the row and the row check.
+func (s *suggestSvc) More(ctx context.Context, chatID int64, messageID int, args []string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "SuggestMore", err)
+	}()
+
+	if len(args) != 3 {
+		err = fmt.Errorf("invalid suggestion args: %v", args)
+		return err
+	}
+
+	expense, err := checkedRow(ctx, s.expenseStore, args[0], args[1], args[2])
+	if err != nil {
+		s.botRepo.EditMessageText(ctx, chatID, messageID, "The expense moved or changed in the sheet, please categorize it there", nil)
+		err = fmt.Errorf("err checkedRow: %w", err)
+		return err
+	}
+
+	classifierModel := s.model(ctx, time.Now())
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	categories := classifierModel.Categories()
+	if len(categories) > suggestMoreCount {
+		categories = categories[:suggestMoreCount]
+	}
+	for _, category := range categories {
+		button, ok := suggestButton(category, expense, category)
+		if !ok {
+			continue
+		}
+		if row = append(row, button); len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		callback.NewButton("Skip", common.CallbackSuggest, expense.Sheet, strconv.Itoa(expense.Row), args[2], ""),
+	))
+
+	_, err = s.botRepo.EditMessageReplyMarkup(ctx, chatID, messageID, tgbotapi.NewInlineKeyboardMarkup(rows...))
+	if err != nil {
+		err = fmt.Errorf("err botRepo.EditMessageReplyMarkup: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// model returns the classifier trained on the months before now, reusing the one trained for the same month
+// less than suggestModelAge ago.
+func (s *suggestSvc) model(ctx context.Context, now time.Time) *classifier.Model {
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	trainedModel.mu.Lock()
+	if trainedModel.model != nil && trainedModel.period.Equal(current) && now.Sub(trainedModel.trainedAt) < suggestModelAge {
+		classifierModel := trainedModel.model
+		trainedModel.mu.Unlock()
+		return classifierModel
+	}
+	trainedModel.mu.Unlock()
+
+	// Trained without the lock, concurrent requests may both train, the last one is kept
+	classifierModel := s.train(ctx, now)
+
+	trainedModel.mu.Lock()
+	trainedModel.model, trainedModel.period, trainedModel.trainedAt = classifierModel, current, now
+	trainedModel.mu.Unlock()
+
+	return classifierModel
+}
+
+// forgetModel drops the trained model, so the next suggestions learn the category just picked.
+func forgetModel() {
+	trainedModel.mu.Lock()
+	defer trainedModel.mu.Unlock()
+
+	trainedModel.model = nil
+}
+
+// train builds the classifier from the categorized rows of the months before now, read in one query.
+func (s *suggestSvc) train(ctx context.Context, now time.Time) *classifier.Model {
+	classifierModel := &classifier.Model{}
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	periods := make([]time.Time, suggestHistoryMonths)
+	for i := range periods {
+		periods[i] = current.AddDate(0, -i, 0)
+	}
+
+	byPeriod, err := readPeriodsExpenses(ctx, s.expenseStore, periods)
+	if err != nil {
+		// Without history there is nothing to learn from
+		logger.Warn(ctx, fmt.Sprintf("readPeriodsExpenses: %s", err.Error()))
+		return classifierModel
+	}
+	for _, period := range periods {
+		for _, expense := range byPeriod[period] {
+			classifierModel.Train(expense.Note, expense.Category)
+		}
+	}
+
+	return classifierModel
+}
+
+// checkedRow reads the expense at sheet and row, making sure it is still the one the buttons were sent for.
+func checkedRow(ctx context.Context, expenseStore repository.ExpenseStore, sheet, row, check string) (model.StoredExpense, error) {
+	rowNumber, err := strconv.Atoi(row)
+	if err != nil {
+		return model.StoredExpense{}, fmt.Errorf("err strconv.Atoi: %w", err)
+	}
+
+	expense, err := expenseStore.Get(ctx, sheet, rowNumber)
+	if err != nil {
+		return model.StoredExpense{}, fmt.Errorf("err expenseStore.Get: %w", err)
+	}
+	if storedCheck(expense.Expense) != check {
+		return model.StoredExpense{}, fmt.Errorf("row %s!%d holds another expense", sheet, rowNumber)
+	}
+
+	return expense, nil
+}
+
+// suggestButton creates the button picking category for expense, unless the category is too long to fit the callback data.
+func suggestButton(label string, expense model.StoredExpense, category string) (tgbotapi.InlineKeyboardButton, bool) {
+	args := []string{expense.Sheet, strconv.Itoa(expense.Row), storedCheck(expense.Expense), category}
+	if len(callback.Encode(common.CallbackSuggest, args...)) > callback.MaxDataLength {
+		return tgbotapi.InlineKeyboardButton{}, false
+	}
+	return callback.NewButton(label, common.CallbackSuggest, args...), true
+}
+
+// storedCheck is a short fingerprint of an expense, telling whether a row still holds it.
+func storedCheck(expense model.Expense) string {
+	return common.Fingerprint(expense.Ref, expense.Note, common.FormatAmount(expense.Amount))[:6]
+}
+
+func describeUncategorized(expense model.Expense) string {
+	return fmt.Sprintf("Uncategorized: %s %s, %s on %s", expense.Currency, common.FormatAmount(expense.Amount),
+		expense.Note, expense.Date.Format("2006-01-02"))
+}
+
+// NewSuggestService creates a new SuggestService using the provided repositories and budget service.
+func NewSuggestService(botRepo *repository.BotRepository, gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore, budgetSvc BudgetService) SuggestService {
+	return &suggestSvc{botRepo: *botRepo, gsheetRepo: *gsheetRepo, expenseStore: *expenseStore, budgetSvc: budgetSvc}
+}
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/common/notification"
+	"github.com/frasnym/go-expense-telebot/model"
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+// syncMonths is the number of month tabs synced, one per month of the year as tabs are named after the month only.
+const syncMonths = 12
+
+// SyncService is an interface for bringing the changes made directly in the spreadsheet back to the primary expense store.
+type SyncService interface {
+	Sync(ctx context.Context, chatID int64, now time.Time) error
+}
+
+type syncSvc struct {
+	gsheetRepo   repository.GSheetRepository
+	expenseStore repository.ExpenseStore
+
+	notificationClient notification.NotificationClient
+}
+
+// syncEntry is the bot's record of a month tab row: the id of its expense in the primary store and the fingerprint
+// of the row when both sides last agreed. Conflict is the last conflict reported for the row, so it's reported once.
+type syncEntry struct {
+	Sheet       string
+	ID          int
+	Fingerprint string
+	Conflict    string
+}
+
+// sheetRow is an expense read from a month tab.
+type sheetRow struct {
+	row         int
+	expense     model.Expense
+	fingerprint string
+}
+
+// syncPair links a recorded entry to a month tab row, either being -1 when it has no counterpart.
+type syncPair struct {
+	entry int
+	row   int
+}
+
+// tabSync is the outcome of syncing a month tab.
+type tabSync struct {
+	entries   []syncEntry
+	fromSheet int
+	toSheet   int
+	conflicts []string
+}
+
+// Sync compares the month tabs of the last syncMonths months with the primary store. Rows edited, deleted or added
+// in the spreadsheet are applied to the primary store, writes the spreadsheet missed are applied to it, and rows
+// changed on both sides are reported to chatID as conflicts and left alone. Nothing is synced when expenses are not
+// mirrored to the spreadsheet, which is then the primary store itself.
+func (s *syncSvc) Sync(ctx context.Context, chatID int64, now time.Time) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "Sync", err)
+	}()
+
+	mirrored, ok := s.expenseStore.(repository.MirroredExpenseStore)
+	if !ok || mirrored.Mirror() == nil {
+		logger.Info(ctx, "expenses are not mirrored to the spreadsheet, nothing to sync")
+		return nil
+	}
+
+	recorded, previousRows, err := getSyncEntries(ctx, s.gsheetRepo)
+	if err != nil {
+		err = fmt.Errorf("err getSyncEntries: %w", err)
+		return err
+	}
+
+	sheetNames, err := s.gsheetRepo.SheetNames(ctx)
+	if err != nil {
+		err = fmt.Errorf("err gsheetRepo.SheetNames: %w", err)
+		return err
+	}
+
+	var entries []syncEntry
+	var fromSheet, toSheet int
+	var conflicts []string
+	for i := syncMonths - 1; i >= 0; i-- {
+		period := time.Date(now.Year(), now.Month()-time.Month(i), 1, 0, 0, 0, 0, time.UTC)
+		sheetName := common.SheetNameForPeriod(period)
+
+		var tabEntries []syncEntry
+		for _, entry := range recorded {
+			if entry.Sheet == sheetName {
+				tabEntries = append(tabEntries, entry)
+			}
+		}
+
+		result, errTab := syncTab(ctx, s.gsheetRepo, mirrored.Unmirrored(), mirrored.Mirror(), period, common.Contains(sheetNames, sheetName), tabEntries)
+		if errTab != nil {
+			err = fmt.Errorf("err syncTab %s: %w", sheetName, errTab)
+			return err
+		}
+		entries = append(entries, result.entries...)
+		fromSheet += result.fromSheet
+		toSheet += result.toSheet
+		conflicts = append(conflicts, result.conflicts...)
+	}
+
+	if err = saveSyncEntries(ctx, s.gsheetRepo, previousRows, entries); err != nil {
+		err = fmt.Errorf("err saveSyncEntries: %w", err)
+		return err
+	}
+
+	logger.Info(ctx, fmt.Sprintf("synced %d changes from the spreadsheet, %d to it, %d new conflicts", fromSheet, toSheet, len(conflicts)))
+	if len(conflicts) == 0 || chatID == 0 {
+		return nil
+	}
+
+	msg := "Changed both in the spreadsheet and in the bot, fix one side to match the other:\n- " + strings.Join(conflicts, "\n- ")
+	if err = s.notificationClient.NotifyChat(ctx, chatID, msg); err != nil {
+		err = fmt.Errorf("err notificationClient.NotifyChat: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// syncTab syncs the month tab of period with the primary store and returns the new record of the tab.
+// Writes to the spreadsheet go through mirror, the primary store is written directly so they are not copied back.
+func syncTab(ctx context.Context, gsheetRepo repository.GSheetRepository, primary, mirror repository.ExpenseStore, period time.Time, tabExists bool, entries []syncEntry) (tabSync, error) {
+	sheetName := common.SheetNameForPeriod(period)
+
+	var rows []sheetRow
+	if tabExists {
+		gsheetValues, err := gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:H", sheetName))
+		if err != nil {
+			return tabSync{}, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+		}
+		for i, row := range gsheetValues.Values {
+			expense, err := repository.ExpenseFromRow(row)
+			// The header, blank rows and the same month of other years, older data sharing the tab, are not synced
+			if err != nil || (expense.Date.Month() == period.Month() && expense.Date.Year() != period.Year()) {
+				continue
+			}
+			rows = append(rows, sheetRow{row: i + 1, expense: expense, fingerprint: expenseFingerprint(expense)})
+		}
+	}
+
+	stored, err := primary.Query(ctx, repository.ExpenseQuery{Periods: []time.Time{period}})
+	if err != nil {
+		return tabSync{}, fmt.Errorf("err primary.Query: %w", err)
+	}
+	byID := map[int]model.StoredExpense{}
+	for _, expense := range stored {
+		byID[expense.Row] = expense
+	}
+
+	// Entries of expenses no longer in the period, e.g. of the same month last year, are only kept
+	// to delete their row when it is still there unchanged
+	rowFingerprints := map[string]bool{}
+	for _, row := range rows {
+		rowFingerprints[row.fingerprint] = true
+	}
+	var current []syncEntry
+	for _, entry := range entries {
+		if _, ok := byID[entry.ID]; ok || rowFingerprints[entry.Fingerprint] {
+			current = append(current, entry)
+		}
+	}
+	entries = current
+
+	var result tabSync
+	var deletedRows []int
+	links := make([]*syncEntry, len(rows))
+	link := func(row, id int, fingerprint string) {
+		links[row] = &syncEntry{Sheet: sheetName, ID: id, Fingerprint: fingerprint}
+	}
+	conflict := func(entry syncEntry, key, msg string) *syncEntry {
+		if entry.Conflict != key {
+			result.conflicts = append(result.conflicts, msg)
+		}
+		entry.Conflict = key
+		return &entry
+	}
+
+	linked := map[int]bool{}
+	for _, entry := range entries {
+		linked[entry.ID] = true
+	}
+
+	pairs := alignRows(entries, rows)
+	orphans := make([]*syncEntry, len(pairs))
+	var newRows []int
+	for k, pair := range pairs {
+		if pair.entry < 0 {
+			newRows = append(newRows, pair.row)
+			continue
+		}
+
+		entry := entries[pair.entry]
+		current, ok := byID[entry.ID]
+		currentFingerprint := expenseFingerprint(current.Expense)
+
+		if pair.row < 0 {
+			switch {
+			case !ok:
+				// Deleted on both sides
+			case currentFingerprint == entry.Fingerprint:
+				if err := primary.Delete(ctx, []model.StoredExpense{current}); err != nil {
+					return tabSync{}, fmt.Errorf("err primary.Delete: %w", err)
+				}
+				result.fromSheet++
+			default:
+				orphans[k] = conflict(entry, "deleted:"+currentFingerprint,
+					fmt.Sprintf("%s: %s deleted in the spreadsheet but edited in the bot", sheetName, describeSyncExpense(current.Expense)))
+			}
+			continue
+		}
+
+		row := rows[pair.row]
+		switch {
+		case entry.Fingerprint == row.fingerprint && !ok:
+			// Deleted by the bot, the spreadsheet missed it
+			deletedRows = append(deletedRows, row.row)
+		case entry.Fingerprint == row.fingerprint && currentFingerprint != row.fingerprint:
+			// Edited by the bot, the spreadsheet missed it
+			if err := mirror.Update(ctx, model.StoredExpense{Expense: current.Expense, Sheet: sheetName, Row: row.row}); err != nil {
+				return tabSync{}, fmt.Errorf("err mirror.Update: %w", err)
+			}
+			result.toSheet++
+			link(pair.row, entry.ID, currentFingerprint)
+		case entry.Fingerprint == row.fingerprint || (ok && currentFingerprint == row.fingerprint):
+			link(pair.row, entry.ID, row.fingerprint)
+		case ok && currentFingerprint == entry.Fingerprint:
+			// Edited in the spreadsheet
+			current.Expense = row.expense
+			if err := primary.Update(ctx, current); err != nil {
+				return tabSync{}, fmt.Errorf("err primary.Update: %w", err)
+			}
+			result.fromSheet++
+			link(pair.row, entry.ID, row.fingerprint)
+		case !ok:
+			links[pair.row] = conflict(entry, row.fingerprint+":deleted",
+				fmt.Sprintf("%s row %d: %s edited in the spreadsheet but deleted in the bot", sheetName, row.row, describeSyncExpense(row.expense)))
+		default:
+			links[pair.row] = conflict(entry, row.fingerprint+":"+currentFingerprint,
+				fmt.Sprintf("%s row %d: %s in the spreadsheet, %s in the bot", sheetName, row.row, describeSyncExpense(row.expense), describeSyncExpense(current.Expense)))
+		}
+	}
+
+	// Rows the record doesn't know are either expenses added by the bot since the last sync or rows added in the spreadsheet
+	unlinked := map[string][]int{}
+	for _, expense := range stored {
+		if !linked[expense.Row] {
+			fingerprint := expenseFingerprint(expense.Expense)
+			unlinked[fingerprint] = append(unlinked[fingerprint], expense.Row)
+		}
+	}
+	var added []int
+	for _, j := range newRows {
+		if ids := unlinked[rows[j].fingerprint]; len(ids) > 0 {
+			link(j, ids[0], rows[j].fingerprint)
+			linked[ids[0]] = true
+			unlinked[rows[j].fingerprint] = ids[1:]
+			continue
+		}
+		added = append(added, j)
+	}
+
+	if len(added) > 0 {
+		expenses := make([]model.Expense, len(added))
+		for i, j := range added {
+			expenses[i] = rows[j].expense
+		}
+		addedExpenses, err := primary.Add(ctx, expenses)
+		if err != nil {
+			return tabSync{}, fmt.Errorf("err primary.Add: %w", err)
+		}
+		for i, j := range added {
+			link(j, addedExpenses[i].Row, rows[j].fingerprint)
+		}
+		result.fromSheet += len(added)
+	}
+
+	if len(deletedRows) > 0 {
+		deleted := make([]model.StoredExpense, len(deletedRows))
+		for i, row := range deletedRows {
+			deleted[i] = model.StoredExpense{Sheet: sheetName, Row: row}
+		}
+		if err := mirror.Delete(ctx, deleted); err != nil {
+			return tabSync{}, fmt.Errorf("err mirror.Delete: %w", err)
+		}
+		result.toSheet += len(deletedRows)
+	}
+
+	for k, pair := range pairs {
+		switch {
+		case pair.row >= 0 && links[pair.row] != nil:
+			result.entries = append(result.entries, *links[pair.row])
+		case pair.row < 0 && orphans[k] != nil:
+			result.entries = append(result.entries, *orphans[k])
+		}
+	}
+
+	// Expenses of the bot the spreadsheet missed
+	var missing []model.StoredExpense
+	for _, expense := range stored {
+		if !linked[expense.Row] {
+			missing = append(missing, expense)
+		}
+	}
+	if len(missing) > 0 {
+		expenses := make([]model.Expense, len(missing))
+		for i, expense := range missing {
+			expenses[i] = expense.Expense
+		}
+		if _, err := mirror.Add(ctx, expenses); err != nil {
+			return tabSync{}, fmt.Errorf("err mirror.Add: %w", err)
+		}
+		for _, expense := range missing {
+			result.entries = append(result.entries, syncEntry{Sheet: sheetName, ID: expense.Row, Fingerprint: expenseFingerprint(expense.Expense)})
+		}
+		result.toSheet += len(missing)
+	}
+
+	return result, nil
+}
+
+// alignRows pairs the recorded entries of a tab with its rows. Both are in sheet order, so the longest common
+// subsequence of their fingerprints gives the unchanged rows. Between two unchanged rows, the remaining entries
+// and rows are paired in order as edited rows, the rest being rows deleted from or added to the spreadsheet.
+func alignRows(entries []syncEntry, rows []sheetRow) []syncPair {
+	n, m := len(entries), len(rows)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case entries[i].Fingerprint == rows[j].fingerprint:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var pairs []syncPair
+	var pendingEntries, pendingRows []int
+	flush := func() {
+		for k := 0; k < len(pendingEntries) || k < len(pendingRows); k++ {
+			pair := syncPair{entry: -1, row: -1}
+			if k < len(pendingEntries) {
+				pair.entry = pendingEntries[k]
+			}
+			if k < len(pendingRows) {
+				pair.row = pendingRows[k]
+			}
+			pairs = append(pairs, pair)
+		}
+		pendingEntries, pendingRows = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && entries[i].Fingerprint == rows[j].fingerprint:
+			flush()
+			pairs = append(pairs, syncPair{entry: i, row: j})
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			pendingEntries = append(pendingEntries, i)
+			i++
+		default:
+			pendingRows = append(pendingRows, j)
+			j++
+		}
+	}
+	flush()
+
+	return pairs
+}
+
+// expenseFingerprint identifies the content of an expense, whichever store it was read from.
+func expenseFingerprint(expense model.Expense) string {
+	return common.Fingerprint(
+		expense.Date.Format(common.SheetDateLayout),
+		strings.TrimSpace(expense.Category),
+		strconv.FormatFloat(expense.Amount, 'f', -1, 64),
+		strings.TrimSpace(expense.Note),
+		strings.TrimSpace(expense.Label),
+		strings.TrimSpace(expense.Wallet),
+		strings.TrimSpace(expense.Currency),
+		strings.TrimSpace(expense.Ref),
+	)
+}
+
+func describeSyncExpense(expense model.Expense) string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s %s", expense.Date.Format("01-02"), expense.Category, common.FormatAmount(expense.Amount), expense.Note))
+}
+
+// getSyncEntries reads the sync tab and its number of rows.
+func getSyncEntries(ctx context.Context, gsheetRepo repository.GSheetRepository) ([]syncEntry, int, error) {
+	if err := gsheetRepo.EnsureSheet(ctx, common.SheetSync); err != nil {
+		return nil, 0, fmt.Errorf("err gsheetRepo.EnsureSheet: %w", err)
+	}
+
+	gsheetValues, err := gsheetRepo.GetValues(ctx, fmt.Sprintf("%s!A:D", common.SheetSync))
+	if err != nil {
+		return nil, 0, fmt.Errorf("err gsheetRepo.GetValues: %w", err)
+	}
+
+	var entries []syncEntry
+	for i, row := range gsheetValues.Values {
+		// Skip header
+		if i == 0 || len(row) < 3 {
+			continue
+		}
+
+		cells := make([]string, 4)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = strings.TrimPrefix(fmt.Sprint(row[j]), "'")
+			}
+		}
+
+		id, err := strconv.Atoi(cells[1])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, syncEntry{Sheet: cells[0], ID: id, Fingerprint: cells[2], Conflict: cells[3]})
+	}
+
+	return entries, len(gsheetValues.Values), nil
+}
+
+// saveSyncEntries rewrites the sync tab, which had previousRows rows.
+func saveSyncEntries(ctx context.Context, gsheetRepo repository.GSheetRepository, previousRows int, entries []syncEntry) error {
+	rows := [][]any{{"sheet", "id", "fingerprint", "conflict"}}
+	for _, entry := range entries {
+		// Keep the tab and fingerprints as text so the sheet doesn't turn "09" into 9
+		rows = append(rows, []any{"'" + entry.Sheet, strconv.Itoa(entry.ID), "'" + entry.Fingerprint, entry.Conflict})
+	}
+
+	if err := rewriteTab(ctx, gsheetRepo, common.SheetSync, previousRows, rows); err != nil {
+		return fmt.Errorf("err rewriteTab: %w", err)
+	}
+
+	return nil
+}
+
+// NewSyncService creates a new SyncService using the provided repositories and notification client.
+func NewSyncService(gsheetRepo *repository.GSheetRepository, expenseStore *repository.ExpenseStore, notificationClient *notification.NotificationClient) SyncService {
+	return &syncSvc{gsheetRepo: *gsheetRepo, expenseStore: *expenseStore, notificationClient: *notificationClient}
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/frasnym/go-expense-telebot/repository"
+)
+
+// rewriteTab replaces the rows of the tab sheetName with rows, the header first, in one call, so a failure leaves
+// either the old rows or the new ones. previousRows is the number of rows read from the tab, those past the new
+// rows are blanked. Rows are as wide as the header.
+func rewriteTab(ctx context.Context, gsheetRepo repository.GSheetRepository, sheetName string, previousRows int, rows [][]any) error {
+	width := len(rows[0])
+	for len(rows) < previousRows {
+		blank := make([]any, width)
+		for i := range blank {
+			blank[i] = ""
+		}
+		rows = append(rows, blank)
+	}
+
+	targetRange := fmt.Sprintf("%s!A1:%c%d", sheetName, 'A'+width-1, len(rows))
+	if err := gsheetRepo.UpdateValues(ctx, targetRange, rows); err != nil {
+		return fmt.Errorf("err gsheetRepo.UpdateValues: %w", err)
+	}
+
+	return nil
+}
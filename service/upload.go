@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/frasnym/go-expense-telebot/common"
+	"github.com/frasnym/go-expense-telebot/common/logger"
+	"github.com/frasnym/go-expense-telebot/common/notification"
+	"github.com/frasnym/go-expense-telebot/config"
+	"github.com/frasnym/go-expense-telebot/pkg/callback"
+	"github.com/frasnym/go-expense-telebot/pkg/session"
+	"github.com/frasnym/go-expense-telebot/pkg/sniff"
+	"github.com/frasnym/go-expense-telebot/pkg/xlsx"
+	"github.com/frasnym/go-expense-telebot/repository"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// UploadService is an interface for receiving uploaded documents and routing them to the importer matching their content.
+type UploadService interface {
+	Request(ctx context.Context, userID int, chatID int64) error
+	Processor(ctx context.Context, userID int, document *tgbotapi.Document) error
+	SelectSheet(ctx context.Context, userID int, chatID int64, messageID int, sheet string) error
+}
+
+type uploadSvc struct {
+	cfg          *config.Config
+	botRepo      repository.BotRepository
+	spendeeSvc   SpendeeService
+	importSvc    ImportService
+	csvImportSvc CSVImportService
+
+	notificationClient notification.NotificationClient
+}
+
+// Request starts an upload session accepting any supported document.
+func (s *uploadSvc) Request(ctx context.Context, userID int, chatID int64) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "UploadRequest", err)
+	}()
+
+	// Start a new session for the user
+	session.NewSession(userID, chatID, common.CommandUpload)
+
+	// Send a request for the document
+	replyTxt := "Please upload your document: Spendee or bank CSV/XLSX export, OFX/QFX or camt.053 XML statement"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(callback.NewButton("Cancel", common.CallbackCancel)),
+	)
+	msg, err := s.botRepo.SendTextMessageWithKeyboard(ctx, chatID, replyTxt, keyboard)
+	if err != nil {
+		err = fmt.Errorf("error sending text message: %w", err)
+		return err
+	}
+
+	// Set the message ID in the user's session
+	if err := session.SetMessageID(userID, msg.MessageID); err != nil {
+		err = fmt.Errorf("error setting message ID: %w", err)
+		return err
+	}
+	return nil
+}
+
+// Processor checks the size of the uploaded document, downloads it, detects its format from the content
+// and hands it to the matching importer. Unsupported documents are rejected and the user may upload again.
+func (s *uploadSvc) Processor(ctx context.Context, userID int, document *tgbotapi.Document) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "UploadProcessor", err)
+	}()
+
+	if session.IsInteractionTimedOut(userID) {
+		s.notificationClient.NotifySendToChat(ctx, userID, "Request timeout")
+		session.DeleteUserSession(userID)
+		return err
+	}
+
+	// The importers record the file name in the import journal
+	if errData := session.SetData(userID, common.SessionKeyFileName, document.FileName); errData != nil {
+		logger.Warn(ctx, fmt.Sprintf("session.SetData: %s", errData.Error()))
+	}
+
+	// Reject before downloading when Telegram already tells the document is too large
+	limit := maxUploadSize(ctx, s.cfg)
+	if int64(document.FileSize) > limit {
+		err = s.reject(ctx, userID, fmt.Sprintf("File is too large (%s), the limit is %s, please upload again", formatSize(int64(document.FileSize)), formatSize(limit)))
+		return err
+	}
+
+	content, err := downloadFile(ctx, s.botRepo, document.FileID, limit)
+	if errors.Is(err, common.ErrTooLarge) {
+		err = s.reject(ctx, userID, fmt.Sprintf("File is too large, the limit is %s, please upload again", formatSize(limit)))
+		return err
+	}
+	if err != nil {
+		s.notificationClient.NotifySendToChat(ctx, userID, "Unable to download the file")
+		session.DeleteUserSession(userID)
+		err = fmt.Errorf("err downloadFile: %w", err)
+		return err
+	}
+
+	kind := sniff.Detect(content)
+	switch kind {
+	case sniff.CSV, sniff.XLSX:
+		if kind == sniff.XLSX {
+			workbook, errOpen := xlsx.Open(content)
+			if errOpen != nil {
+				err = s.reject(ctx, userID, "Unable to read the XLSX workbook, please upload again")
+				return err
+			}
+
+			// Ask which worksheet to import when there are several
+			if sheets := workbook.Sheets(); len(sheets) > 1 {
+				if err = s.promptSheet(ctx, userID, document.FileID, sheets); err != nil {
+					err = fmt.Errorf("err promptSheet: %w", err)
+				}
+				return err
+			}
+		}
+
+		records, errRead := readRecords(ctx, content, 0)
+		if errRead != nil {
+			err = s.reject(ctx, userID, "Unable to read the file, please upload again")
+			return err
+		}
+		if err = s.processRecords(ctx, userID, document.FileID, 0, records); err != nil {
+			err = fmt.Errorf("err processRecords: %w", err)
+		}
+		return err
+	case sniff.OFX:
+		if err = s.importSvc.Processor(ctx, userID, common.CommandUploadOFX, content); err != nil {
+			err = fmt.Errorf("err importSvc.Processor: %w", err)
+		}
+		return err
+	case sniff.Camt:
+		if err = s.importSvc.Processor(ctx, userID, common.CommandUploadCamt, content); err != nil {
+			err = fmt.Errorf("err importSvc.Processor: %w", err)
+		}
+		return err
+	case sniff.XLS:
+		err = s.reject(ctx, userID, "Legacy XLS workbooks are not supported, please save it as XLSX and upload again")
+		return err
+	case sniff.XML:
+		err = s.reject(ctx, userID, "XML document is not a camt.053 statement, please upload again")
+		return err
+	default:
+		err = s.reject(ctx, userID, fmt.Sprintf("Unsupported %s file, please upload a CSV, XLSX, OFX/QFX or camt.053 document", kind))
+		return err
+	}
+}
+
+// SelectSheet imports the worksheet chosen by the user from the workbook uploaded in their session.
+func (s *uploadSvc) SelectSheet(ctx context.Context, userID int, chatID int64, messageID int, sheet string) error {
+	var err error
+	defer func() {
+		logger.LogService(ctx, "UploadSelectSheet", err)
+	}()
+
+	if session.IsInteractionTimedOut(userID) {
+		s.botRepo.EditMessageText(ctx, chatID, messageID, "Request timeout", nil)
+		session.DeleteUserSession(userID)
+		return err
+	}
+
+	index, err := strconv.Atoi(sheet)
+	if err != nil {
+		err = fmt.Errorf("err strconv.Atoi: %w", err)
+		return err
+	}
+	fileID, err := session.GetData(userID, common.SessionKeyFileID)
+	if err != nil {
+		err = fmt.Errorf("err session.GetData: %w", err)
+		return err
+	}
+
+	// The workbook was accepted on upload, fetch it again
+	content, err := downloadFile(ctx, s.botRepo, fileID, maxUploadSize(ctx, s.cfg))
+	if err != nil {
+		s.notificationClient.NotifySendToChat(ctx, userID, "Unable to download the file")
+		session.DeleteUserSession(userID)
+		err = fmt.Errorf("err downloadFile: %w", err)
+		return err
+	}
+	records, err := readRecords(ctx, content, index)
+	if err != nil {
+		s.notificationClient.NotifySendToChat(ctx, userID, "Unable to read the worksheet")
+		session.DeleteUserSession(userID)
+		err = fmt.Errorf("err readRecords: %w", err)
+		return err
+	}
+
+	s.botRepo.EditMessageText(ctx, chatID, messageID, "Importing the selected worksheet", nil)
+
+	if err = s.processRecords(ctx, userID, fileID, index, records); err != nil {
+		err = fmt.Errorf("err processRecords: %w", err)
+	}
+	return err
+}
+
+// processRecords hands the records of a CSV file or worksheet to the Spendee importer when they come
+// from a Spendee export, and to the generic CSV importer otherwise.
+func (s *uploadSvc) processRecords(ctx context.Context, userID int, fileID string, sheet int, records [][]string) error {
+	if len(records) > 0 && isSpendeeHeader(records[0]) {
+		if err := s.spendeeSvc.Processor(ctx, userID, records); err != nil {
+			return fmt.Errorf("err spendeeSvc.Processor: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.csvImportSvc.Processor(ctx, userID, fileID, sheet, records); err != nil {
+		return fmt.Errorf("err csvImportSvc.Processor: %w", err)
+	}
+	return nil
+}
+
+// promptSheet asks the user which worksheet of the uploaded workbook to import.
+func (s *uploadSvc) promptSheet(ctx context.Context, userID int, fileID string, sheets []string) error {
+	if err := session.SetData(userID, common.SessionKeyFileID, fileID); err != nil {
+		return fmt.Errorf("err session.SetData: %w", err)
+	}
+	if err := session.ExtendTimer(userID, common.PromptSessionTimeout); err != nil {
+		return fmt.Errorf("err session.ExtendTimer: %w", err)
+	}
+
+	chatID, err := session.GetChatID(userID)
+	if err != nil {
+		return fmt.Errorf("err session.GetChatID: %w", err)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, name := range sheets {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(callback.NewButton(name, common.CallbackSelectSheet, strconv.Itoa(i))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(callback.NewButton("Cancel", common.CallbackCancel)))
+
+	_, err = s.botRepo.SendTextMessageWithKeyboard(ctx, chatID, "Which worksheet should be imported?", tgbotapi.NewInlineKeyboardMarkup(rows...))
+	if err != nil {
+		return fmt.Errorf("err botRepo.SendTextMessageWithKeyboard: %w", err)
+	}
+
+	return nil
+}
+
+// reject tells the user why the document is refused and keeps the session waiting for another upload.
+func (s *uploadSvc) reject(ctx context.Context, userID int, msg string) error {
+	s.notificationClient.NotifySendToChat(ctx, userID, msg)
+
+	if err := session.ResetTimer(userID); err != nil {
+		return fmt.Errorf("err session.ResetTimer: %w", err)
+	}
+	return nil
+}
+
+// maxUploadSize returns the upload limit in bytes configured in cfg.
+func maxUploadSize(ctx context.Context, cfg *config.Config) int64 {
+	if cfg.MaxUploadSize == "" {
+		return common.DefaultMaxUploadSize
+	}
+
+	size, err := strconv.ParseInt(cfg.MaxUploadSize, 10, 64)
+	if err != nil || size <= 0 {
+		logger.Warn(ctx, fmt.Sprintf("invalid MAX_UPLOAD_SIZE: %s", cfg.MaxUploadSize))
+		return common.DefaultMaxUploadSize
+	}
+	return size
+}
+
+// downloadFile reads the content of a Telegram file, failing with common.ErrTooLarge past limit bytes.
+func downloadFile(ctx context.Context, botRepo repository.BotRepository, fileID string, limit int64) ([]byte, error) {
+	fileUrl, err := botRepo.GetFileURL(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("err botRepo.GetFileURL: %w", err)
+	}
+
+	resp, err := http.Get(fileUrl)
+	if err != nil {
+		return nil, fmt.Errorf("err http.Get: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status downloading file: %s", resp.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("err io.ReadAll: %w", err)
+	}
+	if int64(len(content)) > limit {
+		return nil, common.ErrTooLarge
+	}
+
+	return content, nil
+}
+
+// readRecords reads the rows of a CSV document, or of the worksheet at index sheet of an XLSX workbook.
+func readRecords(ctx context.Context, content []byte, sheet int) ([][]string, error) {
+	if sniff.Detect(content) != sniff.XLSX {
+		return readCSVRecords(ctx, content), nil
+	}
+
+	workbook, err := xlsx.Open(content)
+	if err != nil {
+		return nil, fmt.Errorf("err xlsx.Open: %w", err)
+	}
+	records, err := workbook.Rows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("err workbook.Rows: %w", err)
+	}
+	return records, nil
+}
+
+// readCSVRecords reads CSV records until the end of the document or the first malformed line.
+// The encoding and delimiter are detected from the content.
+func readCSVRecords(ctx context.Context, content []byte) [][]string {
+	var records [][]string
+	text := sniff.Text(content)
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = sniff.Delimiter(text)
+	for {
+		// Read one line
+		record, err := reader.Read()
+		if err != nil {
+			if err != io.EOF {
+				logger.Warn(ctx, fmt.Sprintf("err reader.Read: %s", err.Error()))
+			}
+			break
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// formatSize formats a byte count for humans, e.g. 1.5 MB.
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// NewUploadService creates a new UploadService routing documents to the provided Spendee, statement and CSV importers.
+func NewUploadService(cfg *config.Config, botRepo *repository.BotRepository, notificationClient *notification.NotificationClient, spendeeSvc SpendeeService, importSvc ImportService, csvImportSvc CSVImportService) UploadService {
+	return &uploadSvc{cfg: cfg, botRepo: *botRepo, notificationClient: *notificationClient, spendeeSvc: spendeeSvc, importSvc: importSvc, csvImportSvc: csvImportSvc}
+}